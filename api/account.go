@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/mailer"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// issueUserToken signs a token for the user, records its hash so it can only
+// be used once and returns the link to put in the email.
+func issueUserToken(ctx context.Context, uuidString string, userId int64, purpose string, ttl time.Duration, path string) (string, error) {
+	token, expiresAt, err := utils.SignToken([]byte(core.Config.AuthSecretKey), purpose, userId, ttl)
+	if err != nil {
+		return "", err
+	}
+	if _, err := store.Default.Tokens.CreateUserToken(ctx, uuidString, userId, purpose, utils.HashToken(token), expiresAt); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s?token=%s", core.Config.AppBaseURL, path, url.QueryEscape(token)), nil
+}
+
+// sendUserTokenEmail runs in the background so that request endpoints answer
+// in the same time whether or not the account exists. It keeps the request id
+// of ctx but outlives the request.
+func sendUserTokenEmail(ctx context.Context, uuidString string, userId int64, email string, purpose string) {
+	ctx = context.WithoutCancel(ctx)
+	var (
+		ttl     time.Duration
+		path    string
+		subject string
+		body    string
+	)
+	switch purpose {
+	case models.TOKENPURPOSEEMAILVERIFICATION:
+		ttl = time.Duration(core.Config.EmailVerificationTTLHours) * time.Hour
+		path = "/verify-email"
+		subject = "Verify your email address"
+		body = "Please confirm your email address by opening the link below.\n\n%s\n\nThe link expires in %s."
+	case models.TOKENPURPOSEPASSWORDRESET:
+		ttl = time.Duration(core.Config.PasswordResetTTLMinutes) * time.Minute
+		path = "/reset-password"
+		subject = "Reset your password"
+		body = "A password reset was requested for your account. Open the link below to choose a new password.\n\n%s\n\nThe link expires in %s. If you did not request this, you can ignore this email."
+	default:
+		return
+	}
+
+	link, err := issueUserToken(ctx, uuidString, userId, purpose, ttl, path)
+	if err != nil {
+		logger.Logger.Error("API :: Error while issuing user token", zap.String("requestId", uuidString), zap.String("purpose", purpose), zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err = mailer.DefaultMailer.Send(ctx, mailer.Message{
+		To:      []string{email},
+		Subject: subject,
+		Body:    fmt.Sprintf(body, link, ttl),
+	})
+	if err != nil {
+		logger.Logger.Error("API :: Error while sending email", zap.String("requestId", uuidString), zap.String("purpose", purpose), zap.Error(err))
+	}
+}
+
+func VerifyEmail(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var tokenData models.TokenSchema
+	if err := c.Bind(&tokenData); err != nil || tokenData.Token == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	if _, err := utils.VerifyToken([]byte(core.Config.AuthSecretKey), models.TOKENPURPOSEEMAILVERIFICATION, tokenData.Token); err != nil {
+		c.JSON(400, gin.H{
+			"message": "invalid or expired token",
+		})
+		return
+	}
+
+	id, err := store.Default.Tokens.VerifyEmailWithToken(ctx, uuidString, utils.HashToken(tokenData.Token))
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if id == 0 {
+		c.JSON(400, gin.H{
+			"message": "invalid or expired token",
+		})
+		return
+	}
+	store.Default.Users.InvalidateUser(ctx, uuidString, id)
+
+	c.JSON(200, gin.H{
+		"message": true,
+	})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var emailData models.EmailSchema
+	if err := c.Bind(&emailData); err != nil || emailData.Email == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := store.Default.Users.FetchUserForAuth(ctx, emailData.Email)
+	if userDataFromDb.Id != 0 && !userDataFromDb.EmailVerified {
+		go sendUserTokenEmail(ctx, uuidString, userDataFromDb.Id, userDataFromDb.Email, models.TOKENPURPOSEEMAILVERIFICATION)
+	}
+
+	c.JSON(200, gin.H{
+		"message": true,
+	})
+}
+
+func RequestPasswordReset(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var emailData models.EmailSchema
+	if err := c.Bind(&emailData); err != nil || emailData.Email == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := store.Default.Users.FetchUserForAuth(ctx, emailData.Email)
+	if userDataFromDb.Id != 0 {
+		go sendUserTokenEmail(ctx, uuidString, userDataFromDb.Id, userDataFromDb.Email, models.TOKENPURPOSEPASSWORDRESET)
+	}
+
+	c.JSON(200, gin.H{
+		"message": true,
+	})
+}
+
+func ConfirmPasswordReset(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var resetData models.PasswordResetConfirmSchema
+	if err := c.Bind(&resetData); err != nil || resetData.Token == "" || resetData.Password == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	if _, err := utils.VerifyToken([]byte(core.Config.AuthSecretKey), models.TOKENPURPOSEPASSWORDRESET, resetData.Token); err != nil {
+		c.JSON(400, gin.H{
+			"message": "invalid or expired token",
+		})
+		return
+	}
+
+	passwordHashBytes, err := bcrypt.GenerateFromPassword([]byte(resetData.Password), core.Config.PasswordHashCost)
+	if err != nil {
+		logger.Logger.Error("API :: Error while generating password hash", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	// The password and the revocation of the old sessions commit together, so
+	// a failure cannot leave sessions signed in with the old password.
+	var id int64
+	err = store.Default.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = store.Default.Tokens.ResetPasswordWithToken(ctx, uuidString, utils.HashToken(resetData.Token), string(passwordHashBytes))
+		if err != nil || id == 0 {
+			return err
+		}
+		store.Default.Users.InvalidateUser(ctx, uuidString, id)
+		_, err = store.Default.Sessions.RevokeUserSessions(ctx, uuidString, id, models.SESSIONREVOKEDPASSWORDCHANGE)
+		return err
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if id == 0 {
+		c.JSON(400, gin.H{
+			"message": "invalid or expired token",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": true,
+	})
+}
+
+func ChangeMyPassword(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var passwordData models.PasswordChangeSchema
+	if err := c.Bind(&passwordData); err != nil || passwordData.NewPassword == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	// The authenticated user carries no password hash, load it for the check.
+	userDataFromDb := store.Default.Users.FetchUserForAuth(ctx, middleware.CurrentUser(c).Email)
+	if userDataFromDb.Id == 0 {
+		c.JSON(400, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(userDataFromDb.Password), []byte(passwordData.CurrentPassword)); err != nil {
+		c.JSON(400, gin.H{
+			"message": "current password is incorrect",
+		})
+		return
+	}
+
+	passwordHashBytes, err := bcrypt.GenerateFromPassword([]byte(passwordData.NewPassword), core.Config.PasswordHashCost)
+	if err != nil {
+		logger.Logger.Error("API :: Error while generating password hash", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	var id int64
+	err = store.Default.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = store.Default.Users.UpdateUserPassword(ctx, uuidString, userDataFromDb.Id, string(passwordHashBytes))
+		if err != nil {
+			return err
+		}
+		_, err = store.Default.Sessions.RevokeUserSessions(ctx, uuidString, userDataFromDb.Id, models.SESSIONREVOKEDPASSWORDCHANGE)
+		return err
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+// fetchAdminTargetUser loads the user an admin operation acts on and writes the
+// error response when it cannot be used. Admins may not act on their own
+// account so that the last admin cannot lock everybody out.
+func fetchAdminTargetUser(c *gin.Context, uuidString string, userId int64) (models.AdminUserSchema, bool) {
+	ctx := middleware.RequestContext(c, uuidString)
+	if userId == middleware.CurrentUser(c).Id {
+		c.JSON(400, gin.H{
+			"message": "you can't perform this operation on your own account",
+		})
+		return models.AdminUserSchema{}, false
+	}
+
+	userData, err := store.Default.Users.FetchUserById(ctx, uuidString, userId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return userData, false
+	}
+	if userData.Id == 0 {
+		c.JSON(404, gin.H{
+			"message": "user not found",
+		})
+		return userData, false
+	}
+	return userData, true
+}
+
+func AdminFetchUsers(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	limitQuery := c.DefaultQuery("limit", "0")
+	offsetQuery := c.DefaultQuery("offset", "0")
+	limit, _ := strconv.Atoi(limitQuery)
+	offset, _ := strconv.Atoi(offsetQuery)
+
+	if limit > 50 {
+		c.JSON(400, gin.H{
+			"message": "please check query params - param should not greater than 50",
+		})
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+
+	var userType int
+	if typeQuery := c.Query("type"); typeQuery != "" {
+		if userType = models.GetUserType(typeQuery); userType == 0 {
+			c.JSON(400, gin.H{
+				"message": "please check query params - unknown user type",
+			})
+			return
+		}
+	}
+
+	status := c.Query("status")
+	if !models.ValidateUserStatus(status) {
+		c.JSON(400, gin.H{
+			"message": "please check query params - status should be active or deactivated",
+		})
+		return
+	}
+
+	data, count, err := store.Default.Users.SearchUsers(ctx, uuidString, c.Query("search"), userType, status, limit, offset)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	if count == 0 {
+		emptyArray := make([]string, 0)
+		c.JSON(200, gin.H{
+			"message": emptyArray,
+			"count":   count,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": data,
+		"count":   count,
+	})
+}
+
+func AdminFetchUser(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	userData, err := store.Default.Users.FetchUserById(ctx, uuidString, uri.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if userData.Id == 0 {
+		c.JSON(404, gin.H{
+			"message": "user not found",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": userData,
+	})
+}
+
+// changeUserRole updates a user's role and revokes their sessions and API
+// keys together, so nothing issued under the old role keeps its permissions.
+// Sessions and keys are checked against the database on every request, which
+// also covers instances still caching the old role.
+func changeUserRole(ctx context.Context, uuidString string, userId int64, userType int) (int64, error) {
+	var id int64
+	err := store.Default.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = store.Default.Users.UpdateUserType(ctx, uuidString, userId, userType)
+		if err != nil {
+			return err
+		}
+		if _, err = store.Default.Sessions.RevokeUserSessions(ctx, uuidString, userId, models.SESSIONREVOKEDROLECHANGE); err != nil {
+			return err
+		}
+		_, err = store.Default.APIKeys.RevokeUserAPIKeys(ctx, uuidString, userId)
+		return err
+	})
+	if err != nil {
+		return id, err
+	}
+	store.Default.Users.InvalidateUser(ctx, uuidString, userId)
+	return id, nil
+}
+
+func AdminUpdateUserRole(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	var roleData models.UserRoleUpdateSchema
+	if err := c.Bind(&roleData); err != nil {
+		logger.Logger.Error("API :: Error while binding request data with user role update schema.",
+			zap.String("requestId", uuidString),
+			zap.Error(err),
+		)
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userType := models.GetUserType(roleData.Type)
+	if userType == 0 {
+		c.JSON(400, gin.H{
+			"message": "please check request body",
+		})
+		return
+	}
+
+	if _, ok := fetchAdminTargetUser(c, uuidString, uri.UserId); !ok {
+		return
+	}
+
+	id, err := changeUserRole(ctx, uuidString, uri.UserId, userType)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
+
+func AdminDeactivateUser(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	if _, ok := fetchAdminTargetUser(c, uuidString, uri.UserId); !ok {
+		return
+	}
+
+	id, err := deactivateUser(ctx, uuidString, uri.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
+
+func AdminReactivateUser(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	if _, ok := fetchAdminTargetUser(c, uuidString, uri.UserId); !ok {
+		return
+	}
+
+	id, err := store.Default.Users.ReactivateUser(ctx, uuidString, uri.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
+
+func AdminForcePasswordReset(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	userData, ok := fetchAdminTargetUser(c, uuidString, uri.UserId)
+	if !ok {
+		return
+	}
+
+	id, err := store.Default.Users.SetPasswordResetRequired(ctx, uuidString, uri.UserId, true)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if _, err := store.Default.Sessions.RevokeUserSessions(ctx, uuidString, uri.UserId, models.SESSIONREVOKEDPASSWORDCHANGE); err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	go sendUserTokenEmail(ctx, uuidString, userData.Id, userData.Email, models.TOKENPURPOSEPASSWORDRESET)
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+// newAPIKey returns a key of the form olms_<prefix>_<secret> and its prefix.
+// The hex prefix identifies the key in listings without revealing it.
+func newAPIKey() (string, string, error) {
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+	secret, err := utils.RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+	return models.APIKEYPREFIX + "_" + prefix + "_" + secret, prefix, nil
+}
+
+func apiKeysResponse(c *gin.Context, uuidString string, userId int64) {
+	ctx := middleware.RequestContext(c, uuidString)
+	data, err := store.Default.APIKeys.FetchUserAPIKeys(ctx, uuidString, userId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if len(data) == 0 {
+		data = make([]models.APIKeySchema, 0)
+	}
+
+	c.JSON(200, gin.H{
+		"message": data,
+		"count":   len(data),
+	})
+}
+
+// CreateMyAPIKey creates a key acting as the current user. The key can only
+// be scoped to permissions the user's role has, and the key is returned once.
+func CreateMyAPIKey(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var apiKeyData models.APIKeyCreateSchema
+	if err := c.Bind(&apiKeyData); err != nil {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+	apiKeyData.Name = strings.TrimSpace(apiKeyData.Name)
+	if apiKeyData.Name == "" || len(apiKeyData.Name) > 100 || len(apiKeyData.Scopes) == 0 {
+		c.JSON(400, gin.H{
+			"message": "name and at least one scope are required",
+		})
+		return
+	}
+	if apiKeyData.ExpiresInDays == 0 {
+		apiKeyData.ExpiresInDays = core.Config.APIKeyMaxTTLDays
+	}
+	if apiKeyData.ExpiresInDays < 0 || apiKeyData.ExpiresInDays > core.Config.APIKeyMaxTTLDays {
+		c.JSON(400, gin.H{
+			"message": "expires_in_days must be between 1 and the configured maximum",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	for _, scope := range apiKeyData.Scopes {
+		if !models.ValidatePermission(scope) {
+			c.JSON(400, gin.H{
+				"message": "unknown scope: " + string(scope),
+			})
+			return
+		}
+		if !userDataFromDb.HasPermission(scope) {
+			c.JSON(403, gin.H{
+				"message": "your role does not grant scope: " + string(scope),
+			})
+			return
+		}
+	}
+
+	key, prefix, err := newAPIKey()
+	if err != nil {
+		logger.Logger.Error("API :: Error while generating api key", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(apiKeyData.ExpiresInDays) * 24 * time.Hour)
+
+	id, err := store.Default.APIKeys.CreateAPIKey(ctx, uuidString, userDataFromDb.Id, apiKeyData.Name, prefix, utils.HashToken(key), apiKeyData.Scopes, expiresAt)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(201, gin.H{
+		"message": gin.H{
+			"id":         id,
+			"name":       apiKeyData.Name,
+			"prefix":     prefix,
+			"scopes":     apiKeyData.Scopes,
+			"expires_at": expiresAt.Format(time.RFC3339),
+			"key":        key,
+		},
+	})
+}
+
+func FetchMyAPIKeys(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	userDataFromDb := middleware.CurrentUser(c)
+	apiKeysResponse(c, uuidString, userDataFromDb.Id)
+}
+
+func revokeAPIKey(c *gin.Context, uuidString string, userId int64, apiKeyId int64) {
+	ctx := middleware.RequestContext(c, uuidString)
+	id, err := store.Default.APIKeys.RevokeAPIKey(ctx, uuidString, userId, apiKeyId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if id == 0 {
+		c.JSON(404, gin.H{
+			"message": "api key not found",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "api key revoked",
+	})
+}
+
+func RevokeMyAPIKey(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	revokeAPIKey(c, uuidString, userDataFromDb.Id, uri.APIKeyId)
+}
+
+func AdminFetchUserAPIKeys(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	apiKeysResponse(c, uuidString, uri.UserId)
+}
+
+func AdminRevokeUserAPIKey(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	revokeAPIKey(c, uuidString, uri.UserId, uri.APIKeyId)
+}
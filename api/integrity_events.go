@@ -0,0 +1,104 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+func RecordIntegrityEvent(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	var eventData models.IntegrityEventCreateSchema
+	if err := c.Bind(&eventData); err != nil {
+		logger.Logger.Error("API :: Error while binding request data with integrity event schema.",
+			zap.String("requestId", uuidString),
+			zap.Error(err),
+		)
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	if eventType := models.ValidateIntegrityEventType(eventData.Type); eventType == "" {
+		c.JSON(400, gin.H{
+			"message": "please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+
+	id, err := store.Default.Events.RecordIntegrityEvent(ctx, uuidString, uri.TestId, userDataFromDb.Id, eventData, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
+
+func FetchIntegrityReport(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	// Thresholds default to the configured values and can be tuned per report.
+	maxEvents, err := strconv.ParseInt(c.DefaultQuery("max_events", strconv.FormatInt(core.Config.IntegrityMaxEvents, 10)), 10, 64)
+	if err != nil || maxEvents < 0 {
+		c.JSON(400, gin.H{
+			"message": "please check query params - max_events should be a positive number",
+		})
+		return
+	}
+	maxIPChanges, err := strconv.ParseInt(c.DefaultQuery("max_ip_changes", strconv.FormatInt(core.Config.IntegrityMaxIPChanges, 10)), 10, 64)
+	if err != nil || maxIPChanges < 0 {
+		c.JSON(400, gin.H{
+			"message": "please check query params - max_ip_changes should be a positive number",
+		})
+		return
+	}
+
+	data, err := store.Default.Reports.FetchIntegrityReport(ctx, uuidString, uri.TestId, maxEvents, maxIPChanges)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":        data,
+		"max_events":     maxEvents,
+		"max_ip_changes": maxIPChanges,
+	})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+func AdminFetchLoginLockouts(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	limitQuery := c.DefaultQuery("limit", "0")
+	offsetQuery := c.DefaultQuery("offset", "0")
+	limit, _ := strconv.Atoi(limitQuery)
+	offset, _ := strconv.Atoi(offsetQuery)
+
+	if limit > 50 {
+		c.JSON(400, gin.H{
+			"message": "please check query params - param should not greater than 50",
+		})
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	userId, _ := strconv.ParseInt(c.DefaultQuery("user_id", "0"), 10, 64)
+
+	data, count, err := store.Default.LoginThrottles.FetchLoginLockouts(ctx, uuidString, userId, limit, offset)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	if count == 0 {
+		emptyArray := make([]string, 0)
+		c.JSON(200, gin.H{
+			"message": emptyArray,
+			"count":   count,
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": data,
+		"count":   count,
+	})
+}
+
+// AdminUnlockUserLogin lifts a lockout or backoff on a user's account before
+// it expires. Blocks on client IPs are left alone.
+func AdminUnlockUserLogin(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	userData, ok := fetchAdminTargetUser(c, uuidString, uri.UserId)
+	if !ok {
+		return
+	}
+
+	blocked, err := store.Default.LoginThrottles.UnlockAccountLogin(ctx, uuidString, userData.Id, middleware.LoginAccountKey(userData.Email), middleware.CurrentUser(c).Id)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !blocked {
+		c.JSON(200, gin.H{
+			"message": "account was not locked",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "account unlocked",
+	})
+}
@@ -0,0 +1,395 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/lti"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ltiStateCookie = "lti_login"
+	ltiStateTTL    = 10 * time.Minute
+)
+
+// setLTIStateCookie binds the login state to the browser that started the
+// login. The launch is a cross-site form post from the platform, so over https
+// the cookie has to be SameSite=None to be sent with it.
+func setLTIStateCookie(c *gin.Context, tool *lti.Tool, value string, maxAge int) {
+	secure := strings.HasPrefix(tool.LaunchURL, "https://")
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(ltiStateCookie, value, maxAge, "/lti", "", secure, true)
+}
+
+// ltiUserType maps the launch roles to a user type for provisioning.
+func ltiUserType(launch lti.Launch) int {
+	if launch.IsInstructor() {
+		return models.TEACHER
+	} else if launch.IsTeachingAssistant() {
+		return models.TEACHINGASSISTANT
+	}
+	return models.STUDENT
+}
+
+// ltiEmail returns the launching user's email. Platforms may withhold it, in
+// which case a stable placeholder address is derived from issuer and subject.
+func ltiEmail(launch lti.Launch) string {
+	if launch.Email != "" {
+		return launch.Email
+	}
+	sum := sha256.Sum256([]byte(launch.Platform.Issuer + "|" + launch.Subject))
+	return "lti-" + hex.EncodeToString(sum[:8]) + "@lti.invalid"
+}
+
+func LTIJWKS(c *gin.Context) {
+	tool, err := lti.GetDefaultTool()
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "lti is not enabled",
+		})
+		return
+	}
+	c.JSON(200, tool.JWKS())
+}
+
+func LTILogin(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	tool, err := lti.GetDefaultTool()
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "lti is not enabled",
+		})
+		return
+	}
+
+	var initiation lti.LoginInitiation
+	if err := c.ShouldBind(&initiation); err != nil {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request",
+		})
+		return
+	}
+
+	redirectURL, state, err := tool.LoginRedirectURL([]byte(core.Config.AuthSecretKey), initiation)
+	if err != nil {
+		logger.Logger.Info("API :: Rejected lti login initiation", zap.String("requestId", uuidString), zap.String("issuer", initiation.Issuer), zap.Error(err))
+		c.JSON(400, gin.H{
+			"message": "invalid login initiation",
+		})
+		return
+	}
+	setLTIStateCookie(c, tool, state, int(ltiStateTTL.Seconds()))
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// LTILaunch validates a launch, provisions the user, course and resource link,
+// and then either shows the deep linking picker or signs the user in and
+// redirects to the test in the front end.
+func LTILaunch(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uuidString := utils.GetUUID()
+		c.Header("X-REQUEST-ID", uuidString)
+		ctx := middleware.RequestContext(c, uuidString)
+
+		tool, err := lti.GetDefaultTool()
+		if err != nil {
+			c.JSON(404, gin.H{
+				"message": "lti is not enabled",
+			})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		cookieValue, err := c.Cookie(ltiStateCookie)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": "launch session not found - please start the launch again",
+			})
+			return
+		}
+		setLTIStateCookie(c, tool, "", -1)
+		if subtle.ConstantTimeCompare([]byte(cookieValue), []byte(c.PostForm("state"))) != 1 {
+			logger.Logger.Info("API :: Rejected lti launch from another browser", zap.String("requestId", uuidString))
+			c.JSON(401, gin.H{
+				"message": "invalid launch",
+			})
+			return
+		}
+
+		launch, err := tool.ValidateLaunch(ctx, []byte(core.Config.AuthSecretKey), c.PostForm("id_token"), c.PostForm("state"))
+		if err != nil {
+			logger.Logger.Info("API :: Rejected lti launch", zap.String("requestId", uuidString), zap.Error(err))
+			c.JSON(401, gin.H{
+				"message": "invalid launch",
+			})
+			return
+		}
+
+		unused, err := store.Default.Identities.UseLTINonce(ctx, uuidString, launch.Nonce, launch.ExpiresAt)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		if !unused {
+			c.JSON(401, gin.H{
+				"message": "launch was already used",
+			})
+			return
+		}
+
+		// Platforms do not verify the email they send, so a launch never links to
+		// an existing account by email: that would let any course member of a
+		// registered platform sign in as whoever owns the address here.
+		identity := models.ExternalIdentitySchema{
+			Issuer:    launch.Platform.Issuer,
+			Subject:   launch.Subject,
+			Email:     ltiEmail(launch),
+			FirstName: launch.GivenName,
+			LastName:  launch.FamilyName,
+			Type:      ltiUserType(launch),
+		}
+		if identity.FirstName == "" && identity.LastName == "" {
+			identity.FirstName, identity.LastName, _ = strings.Cut(launch.Name, " ")
+		}
+		userId, err := store.Default.Identities.ProvisionIdentity(ctx, uuidString, identity, false)
+		if err == models.ErrIdentityEmailConflict {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+			return
+		} else if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		store.Default.Users.InvalidateUser(ctx, uuidString, userId)
+
+		launchData := models.LTILaunchSchema{
+			Issuer:            launch.Platform.Issuer,
+			DeploymentId:      launch.DeploymentId,
+			ContextId:         launch.Context.Id,
+			ContextLabel:      launch.Context.Label,
+			ContextTitle:      launch.Context.Title,
+			ResourceLinkId:    launch.ResourceLink.Id,
+			ResourceLinkTitle: launch.ResourceLink.Title,
+			UserId:            userId,
+			Roles:             launch.Roles,
+		}
+		launchData.TestId, _ = strconv.ParseInt(launch.Custom["test_id"], 10, 64)
+		if launch.AGSEndpoint != nil {
+			launchData.LineItemURL = launch.AGSEndpoint.LineItem
+		}
+		testId, err := store.Default.Identities.RecordLTILaunch(ctx, uuidString, launchData)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+
+		if launch.MessageType == lti.MessageTypeDeepLinking {
+			ltiDeepLinking(c, uuidString, tool, launch)
+			return
+		}
+
+		if testId == 0 {
+			c.JSON(400, gin.H{
+				"message": "this link is not connected to a test",
+			})
+			return
+		}
+
+		userData, err := store.Default.Users.FetchUserById(ctx, uuidString, userId)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		userDataFromDb := store.Default.Users.FetchUserForAuth(ctx, userData.Email)
+		if userDataFromDb.Id == 0 {
+			c.JSON(403, gin.H{
+				"message": "account is deactivated",
+			})
+			return
+		}
+
+		challenge, err := middleware.LoginMFAChallenge(ctx, userDataFromDb)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		if challenge != nil {
+			// The front end finishes the login at POST /token/mfa, as after a
+			// password login.
+			fragment := url.Values{
+				"mfa_required":            {"true"},
+				"mfa_enrollment_required": {strconv.FormatBool(challenge.EnrollmentRequired)},
+				"mfa_token":               {challenge.Token},
+				"mfa_expire":              {challenge.Expire.Format(time.RFC3339)},
+				"test_id":                 {strconv.FormatInt(testId, 10)},
+			}
+			c.Redirect(http.StatusFound, core.Config.AppBaseURL+"/lti/launch#"+fragment.Encode())
+			return
+		}
+
+		tokens, err := middleware.IssueLoginTokens(c, authMiddleware, userDataFromDb)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+
+		// Tokens travel in the fragment so they never reach server logs.
+		fragment := url.Values{
+			"token":          {tokens.Token},
+			"expire":         {tokens.Expire.Format(time.RFC3339)},
+			"refresh_token":  {tokens.RefreshToken},
+			"refresh_expire": {tokens.RefreshExpire.Format(time.RFC3339)},
+			"test_id":        {strconv.FormatInt(testId, 10)},
+		}
+		c.Redirect(http.StatusFound, core.Config.AppBaseURL+"/lti/launch#"+fragment.Encode())
+	}
+}
+
+// ltiDeepLinking renders the test picker. Every option carries its own signed
+// deep linking response, so picking a test posts straight back to the platform.
+func ltiDeepLinking(c *gin.Context, uuidString string, tool *lti.Tool, launch lti.Launch) {
+	ctx := middleware.RequestContext(c, uuidString)
+	if !launch.IsInstructor() {
+		c.JSON(403, gin.H{
+			"message": "only instructors can add tests",
+		})
+		return
+	}
+
+	testData, _, err := store.Default.Tests.FetchTests(ctx, uuidString, models.Page{Limit: 50})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	options := make([]lti.DeepLinkingOption, 0, len(testData))
+	for _, test := range testData {
+		signedResponse, err := tool.DeepLinkingResponse(launch, []lti.ContentItem{{
+			Type:   "ltiResourceLink",
+			Title:  test.Title,
+			URL:    tool.LaunchURL,
+			Custom: map[string]string{"test_id": strconv.FormatInt(test.Id, 10)},
+			LineItem: &lti.LineItem{
+				ScoreMaximum: 100,
+				Label:        test.Title,
+				ResourceId:   fmt.Sprintf("test-%d", test.Id),
+			},
+		}})
+		if err != nil {
+			logger.Logger.Error("API :: Error while signing deep linking response", zap.String("requestId", uuidString), zap.Error(err))
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		options = append(options, lti.DeepLinkingOption{Title: test.Title, JWT: signedResponse})
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(200)
+	if err := lti.WriteDeepLinkingPage(c.Writer, launch.DeepLinking.DeepLinkReturnURL, options); err != nil {
+		logger.Logger.Error("API :: Error while rendering deep linking page", zap.String("requestId", uuidString), zap.Error(err))
+	}
+}
+
+// SyncLTIScores publishes the current score of every student who answered the
+// test to the gradebook of each course the test is placed in.
+func SyncLTIScores(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	tool, err := lti.GetDefaultTool()
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "lti is not enabled",
+		})
+		return
+	}
+
+	targets, err := store.Default.Identities.FetchLTIScoreTargets(ctx, uuidString, uri.TestId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	sent, failed := 0, 0
+	for _, target := range targets {
+		platform, err := tool.Platform(target.Issuer, "")
+		if err == nil {
+			err = tool.PostScore(ctx, platform, target.LineItemURL, lti.Score{
+				UserId:           target.PlatformUserId,
+				ScoreGiven:       float64(target.CorrectAnswers),
+				ScoreMaximum:     float64(target.TotalQuestions),
+				ActivityProgress: "Completed",
+				GradingProgress:  "FullyGraded",
+			})
+		}
+		if err != nil {
+			logger.Logger.Error("API :: Error while publishing lti score", zap.String("requestId", uuidString), zap.Int64("userId", target.UserId), zap.String("lineItem", target.LineItemURL), zap.Error(err))
+			failed++
+			continue
+		}
+		sent++
+	}
+
+	c.JSON(200, gin.H{
+		"message": gin.H{
+			"sent":   sent,
+			"failed": failed,
+		},
+	})
+}
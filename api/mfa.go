@@ -0,0 +1,570 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+const recoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// newRecoveryCodes returns single use codes such as "k3vqa-7mzpe" and the
+// hashes to store for them.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		randomBytes := make([]byte, 7)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(randomBytes))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utils.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// issueRecoveryCodes replaces the user's recovery codes and writes the error
+// response when that fails.
+func issueRecoveryCodes(c *gin.Context, uuidString string, userId int64) ([]string, bool) {
+	ctx := middleware.RequestContext(c, uuidString)
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = store.Default.MFA.ReplaceRecoveryCodes(ctx, uuidString, userId, hashes)
+	}
+	if err != nil {
+		logger.Logger.Error("API :: Error while issuing recovery codes", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return nil, false
+	}
+	return codes, true
+}
+
+// startMFAEnrollment creates a new secret for the user and responds with it.
+func startMFAEnrollment(c *gin.Context, uuidString string, userData models.UserSchema) {
+	ctx := middleware.RequestContext(c, uuidString)
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		logger.Logger.Error("API :: Error while generating totp secret", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	encryptedSecret, err := utils.Encrypt([]byte(core.Config.AuthSecretKey), secret)
+	if err != nil {
+		logger.Logger.Error("API :: Error while encrypting totp secret", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	err = store.Default.MFA.StartMFAEnrollment(ctx, uuidString, userData.Id, encryptedSecret)
+	if err == models.ErrMFAAlreadyEnabled {
+		c.JSON(409, gin.H{
+			"message": "two-factor authentication is already enabled",
+		})
+		return
+	} else if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": gin.H{
+			"secret":      secret,
+			"otpauth_uri": utils.TOTPURI(core.Config.MFAIssuer, userData.Email, secret),
+		},
+	})
+}
+
+// checkSecondFactor verifies a TOTP code, or a recovery code once MFA is
+// enabled. A verified code also completes a pending enrollment when enable is
+// set. Each code works only once.
+func checkSecondFactor(ctx context.Context, uuidString string, userId int64, state models.MFAStateSchema, code string, recoveryCode string, enable bool) (bool, error) {
+	if state.Secret == "" {
+		return false, nil
+	}
+	if recoveryCode != "" {
+		if !state.Enabled {
+			return false, nil
+		}
+		return store.Default.MFA.UseRecoveryCode(ctx, uuidString, userId, utils.HashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	secret, err := utils.Decrypt([]byte(core.Config.AuthSecretKey), state.Secret)
+	if err != nil {
+		logger.Logger.Error("API :: Error while decrypting totp secret", zap.String("requestId", uuidString), zap.Int64("userId", userId), zap.Error(err))
+		return false, err
+	}
+	step, valid := utils.VerifyTOTP(secret, code, time.Now())
+	if !valid {
+		return false, nil
+	}
+	return store.Default.MFA.UseTOTPStep(ctx, uuidString, userId, step, enable)
+}
+
+func FetchMyMFA(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	userDataFromDb := middleware.CurrentUser(c)
+	state, err := store.Default.MFA.FetchMFAState(ctx, uuidString, userDataFromDb.Id, userDataFromDb.UserType())
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": gin.H{
+			"enabled":             state.Enabled,
+			"required":            state.Required,
+			"recovery_codes_left": state.RecoveryCodesLeft,
+		},
+	})
+}
+
+func EnrollMyMFA(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	startMFAEnrollment(c, uuidString, middleware.CurrentUser(c))
+}
+
+// VerifyMyMFA enables the second factor with a first code from the
+// authenticator app and returns the recovery codes.
+func VerifyMyMFA(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var codeData models.MFACodeSchema
+	if err := c.Bind(&codeData); err != nil || codeData.Code == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	state, err := store.Default.MFA.FetchMFAState(ctx, uuidString, userDataFromDb.Id, userDataFromDb.UserType())
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if state.Enabled {
+		c.JSON(409, gin.H{
+			"message": "two-factor authentication is already enabled",
+		})
+		return
+	}
+	if state.Secret == "" {
+		c.JSON(400, gin.H{
+			"message": "start the enrollment first",
+		})
+		return
+	}
+
+	valid, err := checkSecondFactor(ctx, uuidString, userDataFromDb.Id, state, codeData.Code, "", true)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !valid {
+		c.JSON(400, gin.H{
+			"message": "invalid code",
+		})
+		return
+	}
+
+	codes, ok := issueRecoveryCodes(c, uuidString, userDataFromDb.Id)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+func RegenerateMyRecoveryCodes(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var codeData models.MFACodeSchema
+	if err := c.Bind(&codeData); err != nil || codeData.Code == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	state, err := store.Default.MFA.FetchMFAState(ctx, uuidString, userDataFromDb.Id, userDataFromDb.UserType())
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !state.Enabled {
+		c.JSON(404, gin.H{
+			"message": "two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	valid, err := checkSecondFactor(ctx, uuidString, userDataFromDb.Id, state, codeData.Code, "", false)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !valid {
+		c.JSON(400, gin.H{
+			"message": "invalid code",
+		})
+		return
+	}
+
+	codes, ok := issueRecoveryCodes(c, uuidString, userDataFromDb.Id)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+func DisableMyMFA(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var codeData models.MFACodeSchema
+	if err := c.Bind(&codeData); err != nil || (codeData.Code == "" && codeData.RecoveryCode == "") {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	state, err := store.Default.MFA.FetchMFAState(ctx, uuidString, userDataFromDb.Id, userDataFromDb.UserType())
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !state.Enabled {
+		c.JSON(404, gin.H{
+			"message": "two-factor authentication is not enabled",
+		})
+		return
+	}
+	if state.Required {
+		c.JSON(403, gin.H{
+			"message": "your role requires two-factor authentication",
+		})
+		return
+	}
+
+	valid, err := checkSecondFactor(ctx, uuidString, userDataFromDb.Id, state, codeData.Code, codeData.RecoveryCode, false)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !valid {
+		c.JSON(400, gin.H{
+			"message": "invalid code",
+		})
+		return
+	}
+
+	if _, err := store.Default.MFA.DisableMFA(ctx, uuidString, userDataFromDb.Id); err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// fetchMFAChallengeUser loads the user an MFA challenge token was issued to
+// and writes the error response when the token or the account is not usable.
+func fetchMFAChallengeUser(c *gin.Context, uuidString string, mfaToken string) (models.UserSchema, bool) {
+	ctx := middleware.RequestContext(c, uuidString)
+	userId, err := middleware.VerifyMFAChallenge(mfaToken)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"message": "invalid or expired mfa token",
+		})
+		return models.UserSchema{}, false
+	}
+
+	userData, err := store.Default.Users.FetchUserById(ctx, uuidString, userId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return models.UserSchema{}, false
+	}
+	userDataFromDb := store.Default.Users.FetchUserForAuth(ctx, userData.Email)
+	if userDataFromDb.Id == 0 || userDataFromDb.Id != userId {
+		c.JSON(401, gin.H{
+			"message": "invalid or expired mfa token",
+		})
+		return models.UserSchema{}, false
+	}
+	return userDataFromDb, true
+}
+
+// MFALoginEnroll starts the enrollment of a user whose role requires a second
+// factor but who has none yet, during login.
+func MFALoginEnroll(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	var loginData models.MFALoginSchema
+	if err := c.Bind(&loginData); err != nil || loginData.MFAToken == "" {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb, ok := fetchMFAChallengeUser(c, uuidString, loginData.MFAToken)
+	if !ok {
+		return
+	}
+	startMFAEnrollment(c, uuidString, userDataFromDb)
+}
+
+// MFALogin completes a login with the challenge token from POST /token and a
+// TOTP or recovery code. For a user enrolling during login, the first code
+// enables the second factor and the recovery codes are returned with the
+// tokens.
+func MFALogin(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uuidString := utils.GetUUID()
+		c.Header("X-REQUEST-ID", uuidString)
+		ctx := middleware.RequestContext(c, uuidString)
+
+		var loginData models.MFALoginSchema
+		if err := c.Bind(&loginData); err != nil || loginData.MFAToken == "" || (loginData.Code == "" && loginData.RecoveryCode == "") {
+			c.JSON(400, gin.H{
+				"message": "something went wrong - please check request body",
+			})
+			return
+		}
+
+		userDataFromDb, ok := fetchMFAChallengeUser(c, uuidString, loginData.MFAToken)
+		if !ok {
+			return
+		}
+		if throttleErr := middleware.ReserveLoginAttempt(c, userDataFromDb.Email); throttleErr != nil {
+			c.JSON(throttleErr.Status(), gin.H{"message": throttleErr.Message})
+			return
+		}
+		state, err := store.Default.MFA.FetchMFAState(ctx, uuidString, userDataFromDb.Id, userDataFromDb.UserType())
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		if state.Secret == "" {
+			c.JSON(400, gin.H{
+				"message": "start the enrollment first",
+			})
+			return
+		}
+
+		enrolling := !state.Enabled
+		valid, err := checkSecondFactor(ctx, uuidString, userDataFromDb.Id, state, loginData.Code, loginData.RecoveryCode, enrolling)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		if !valid {
+			logger.Logger.Info("API :: Rejected mfa code", zap.String("requestId", uuidString), zap.Int64("userId", userDataFromDb.Id))
+			c.JSON(401, gin.H{
+				"message": "invalid code",
+			})
+			return
+		}
+		middleware.ReleaseLoginAttempt(c, userDataFromDb.Email)
+		middleware.ClearLoginFailures(c, userDataFromDb.Email)
+
+		if !enrolling {
+			middleware.CompleteLogin(c, authMiddleware, userDataFromDb)
+			return
+		}
+
+		codes, ok := issueRecoveryCodes(c, uuidString, userDataFromDb.Id)
+		if !ok {
+			return
+		}
+		tokens, err := middleware.IssueLoginTokens(c, authMiddleware, userDataFromDb)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		c.JSON(200, gin.H{
+			"code":           200,
+			"token":          tokens.Token,
+			"expire":         tokens.Expire.Format(time.RFC3339),
+			"refresh_token":  tokens.RefreshToken,
+			"refresh_expire": tokens.RefreshExpire.Format(time.RFC3339),
+			"recovery_codes": codes,
+		})
+	}
+}
+
+func AdminFetchRoleSettings(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	data, err := store.Default.MFA.FetchRoleSettings(ctx, uuidString)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": data,
+		"count":   len(data),
+	})
+}
+
+func AdminUpdateRoleSettings(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+	userType := models.GetUserType(uri.Role)
+	if userType == 0 {
+		c.JSON(404, gin.H{
+			"message": "role not found",
+		})
+		return
+	}
+
+	var settingData models.RoleSettingUpdateSchema
+	if err := c.Bind(&settingData); err != nil || settingData.MFARequired == nil {
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	if _, err := store.Default.MFA.UpdateRoleMFARequired(ctx, uuidString, userType, *settingData.MFARequired); err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": models.RoleSettingSchema{
+			Role:        uri.Role,
+			MFARequired: *settingData.MFARequired,
+			UpdatedAt:   time.Now(),
+		},
+	})
+}
+
+// AdminResetUserMFA removes a user's second factor, for users who lost both
+// their device and their recovery codes. Users of a role that requires MFA
+// enroll again at their next login.
+func AdminResetUserMFA(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	if _, ok := fetchAdminTargetUser(c, uuidString, uri.UserId); !ok {
+		return
+	}
+
+	removed, err := store.Default.MFA.DisableMFA(ctx, uuidString, uri.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if !removed {
+		c.JSON(404, gin.H{
+			"message": "two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "two-factor authentication reset",
+	})
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/oidc"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcStateCookie  = "oidc_login"
+	oidcStatePurpose = "oidc_login"
+	oidcStateTTL     = 10 * time.Minute
+)
+
+// oidcLoginState is kept in a signed cookie between the redirect to the
+// identity provider and the callback.
+type oidcLoginState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// oidcRolePrecedence decides which user type wins when the role claim maps to
+// several of them.
+var oidcRolePrecedence = []int{models.ADMIN, models.TEACHER, models.TEACHINGASSISTANT, models.STUDENT}
+
+func mapOIDCUserType(claims oidc.Claims) int {
+	oidcConfig := core.Config.OIDC
+
+	mapped := make(map[int]bool)
+	for _, value := range claims.ClaimValues(oidcConfig.RoleClaim) {
+		if roleName, ok := oidcConfig.RoleMapping[value]; ok {
+			mapped[models.GetUserType(roleName)] = true
+		}
+	}
+	for _, userType := range oidcRolePrecedence {
+		if mapped[userType] {
+			return userType
+		}
+	}
+	return models.GetUserType(oidcConfig.DefaultRole)
+}
+
+func setOIDCStateCookie(c *gin.Context, provider *oidc.Provider, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, "/oidc", "", strings.HasPrefix(provider.RedirectURL, "https://"), true)
+}
+
+func OIDCLogin(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	provider, err := oidc.DefaultProvider(ctx)
+	if err == oidc.ErrDisabled {
+		c.JSON(404, gin.H{
+			"message": "single sign-on is not enabled",
+		})
+		return
+	} else if err != nil {
+		c.JSON(503, gin.H{
+			"message": "identity provider is unavailable",
+		})
+		return
+	}
+
+	var loginState oidcLoginState
+	if loginState.State, err = utils.RandomString(24); err == nil {
+		if loginState.Nonce, err = utils.RandomString(24); err == nil {
+			loginState.CodeVerifier, err = oidc.NewCodeVerifier()
+		}
+	}
+	if err != nil {
+		logger.Logger.Error("API :: Error while generating oidc login state", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	cookieValue, err := utils.SignData([]byte(core.Config.AuthSecretKey), oidcStatePurpose, loginState, oidcStateTTL)
+	if err != nil {
+		logger.Logger.Error("API :: Error while signing oidc login state", zap.String("requestId", uuidString), zap.Error(err))
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	setOIDCStateCookie(c, provider, cookieValue, int(oidcStateTTL.Seconds()))
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(loginState.State, loginState.Nonce, oidc.CodeChallengeS256(loginState.CodeVerifier)))
+}
+
+// OIDCCallback completes the authorization code flow and answers like
+// POST /token.
+func OIDCCallback(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uuidString := utils.GetUUID()
+		c.Header("X-REQUEST-ID", uuidString)
+		ctx := middleware.RequestContext(c, uuidString)
+
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		provider, err := oidc.DefaultProvider(ctx)
+		if err == oidc.ErrDisabled {
+			c.JSON(404, gin.H{
+				"message": "single sign-on is not enabled",
+			})
+			return
+		} else if err != nil {
+			c.JSON(503, gin.H{
+				"message": "identity provider is unavailable",
+			})
+			return
+		}
+
+		if errorCode := c.Query("error"); errorCode != "" {
+			logger.Logger.Info("API :: Identity provider returned an error", zap.String("requestId", uuidString), zap.String("error", errorCode), zap.String("description", c.Query("error_description")))
+			c.JSON(401, gin.H{
+				"message": "single sign-on failed: " + errorCode,
+			})
+			return
+		}
+
+		cookieValue, err := c.Cookie(oidcStateCookie)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": "login session not found - please start the login again",
+			})
+			return
+		}
+		setOIDCStateCookie(c, provider, "", -1)
+
+		var loginState oidcLoginState
+		if err := utils.VerifyData([]byte(core.Config.AuthSecretKey), oidcStatePurpose, cookieValue, &loginState); err != nil || loginState.State != c.Query("state") {
+			c.JSON(400, gin.H{
+				"message": "invalid login state - please start the login again",
+			})
+			return
+		}
+
+		tokenResponse, err := provider.Exchange(ctx, c.Query("code"), loginState.CodeVerifier)
+		if err != nil {
+			logger.Logger.Error("API :: Error while exchanging authorization code", zap.String("requestId", uuidString), zap.Error(err))
+			c.JSON(401, gin.H{
+				"message": "single sign-on failed",
+			})
+			return
+		}
+
+		claims, err := provider.VerifyIDToken(ctx, tokenResponse.IDToken, loginState.Nonce)
+		if err != nil {
+			logger.Logger.Error("API :: Error while verifying id token", zap.String("requestId", uuidString), zap.Error(err))
+			c.JSON(401, gin.H{
+				"message": "single sign-on failed",
+			})
+			return
+		}
+		if claims.Email == "" {
+			c.JSON(401, gin.H{
+				"message": "identity provider did not return an email address",
+			})
+			return
+		}
+
+		userType := mapOIDCUserType(claims)
+		if models.ValidateUserType(userType) == "" {
+			c.JSON(403, gin.H{
+				"message": "your account has no role in this application",
+			})
+			return
+		}
+
+		firstName, lastName := claims.GivenName, claims.FamilyName
+		if firstName == "" && lastName == "" {
+			firstName, lastName, _ = strings.Cut(claims.Name, " ")
+		}
+
+		identity := models.ExternalIdentitySchema{
+			Issuer:        provider.Issuer,
+			Subject:       claims.Subject,
+			Email:         claims.Email,
+			EmailVerified: claims.EmailVerified,
+			FirstName:     firstName,
+			LastName:      lastName,
+			Type:          userType,
+		}
+		userId, err := store.Default.Identities.ProvisionIdentity(ctx, uuidString, identity, core.Config.OIDC.SyncRole)
+		if err == models.ErrIdentityEmailConflict {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+			return
+		} else if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		store.Default.Users.InvalidateUser(ctx, uuidString, userId)
+
+		userData, err := store.Default.Users.FetchUserById(ctx, uuidString, userId)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		userDataFromDb := store.Default.Users.FetchUserForAuth(ctx, userData.Email)
+		if userDataFromDb.Id == 0 {
+			c.JSON(403, gin.H{
+				"message": "account is deactivated",
+			})
+			return
+		}
+
+		challenge, err := middleware.LoginMFAChallenge(ctx, userDataFromDb)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"message": "something went wrong",
+			})
+			return
+		}
+		if challenge != nil {
+			middleware.MFAChallengeResponse(c, challenge)
+			return
+		}
+		middleware.CompleteLogin(c, authMiddleware, userDataFromDb)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+const (
+	PAGEDEFAULTLIMIT int = 10
+	PAGEMAXLIMIT     int = 50
+)
+
+// parsePage reads the paging query params of a list endpoint: limit, and
+// either after (a next_cursor of a previous response) or offset. The total
+// count is returned in offset mode, and in cursor mode only with count=true;
+// count=false skips it in both.
+func parsePage(c *gin.Context) (models.Page, error) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	if limit > PAGEMAXLIMIT {
+		return models.Page{}, errors.New("please check query params - param should not greater than 50")
+	}
+	if limit <= 0 {
+		limit = PAGEDEFAULTLIMIT
+	}
+	page := models.Page{Limit: limit, Offset: offset}
+
+	if after := c.Query("after"); after != "" {
+		id, err := utils.DecodeCursor(after)
+		if err != nil {
+			return models.Page{}, errors.New("please check query params - invalid cursor")
+		}
+		page.After = id
+		page.Offset = 0
+	}
+
+	page.WithCount = page.After == 0
+	if countQuery := c.Query("count"); countQuery != "" {
+		withCount, err := strconv.ParseBool(countQuery)
+		if err != nil {
+			return models.Page{}, errors.New("please check query params - count should be true or false")
+		}
+		page.WithCount = withCount
+	}
+	return page, nil
+}
+
+// pageResponse is the body of a list endpoint. next_cursor is null on the
+// last page; a full page may be followed by an empty one.
+func pageResponse[T any](data []T, count int, page models.Page, id func(T) int64) gin.H {
+	response := gin.H{"message": data, "next_cursor": nil}
+	if len(data) == 0 {
+		response["message"] = make([]string, 0)
+	}
+	if len(data) == page.Limit {
+		response["next_cursor"] = utils.EncodeCursor(id(data[len(data)-1]))
+	}
+	if page.WithCount {
+		response["count"] = count
+	}
+	return response
+}
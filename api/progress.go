@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+func FetchMyProgress(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	userDataFromDb := middleware.CurrentUser(c)
+	if userDataFromDb.Id == 0 {
+		c.JSON(400, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	progressData, err := store.Default.Reports.FetchUserProgress(ctx, uuidString, userDataFromDb.Id)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": progressData,
+	})
+}
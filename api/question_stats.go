@@ -0,0 +1,59 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+func FetchQuestionStats(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	bucket := models.ValidateStatsBucket(c.DefaultQuery("bucket", models.STATSBUCKETDAY))
+	if bucket == "" {
+		c.JSON(400, gin.H{
+			"message": "please check query params - bucket should be one of day, week or month",
+		})
+		return
+	}
+
+	questionData, err := store.Default.Questions.FetchQuestion(ctx, uuidString, uri.QuestionId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if questionData.Id == 0 {
+		c.JSON(404, gin.H{
+			"message": "question not found",
+		})
+		return
+	}
+
+	statsData, err := store.Default.Reports.FetchQuestionStats(ctx, uuidString, uri.QuestionId, bucket)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": statsData,
+	})
+}
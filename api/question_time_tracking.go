@@ -0,0 +1,86 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+func RecordQuestionEvent(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	var eventData models.QuestionEventCreateSchema
+	if err := c.Bind(&eventData); err != nil {
+		logger.Logger.Error("API :: Error while binding request data with question event schema.",
+			zap.String("requestId", uuidString),
+			zap.Error(err),
+		)
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	if eventType := models.ValidateQuestionEventType(eventData.Type); eventType == "" {
+		c.JSON(400, gin.H{
+			"message": "please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+
+	id, err := store.Default.Events.RecordQuestionEvent(ctx, uuidString, uri.TestId, userDataFromDb.Id, uri.QuestionId, eventData)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
+
+func FetchQuestionTimingReport(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	data, err := store.Default.Reports.FetchQuestionTimingReport(ctx, uuidString, uri.TestId, core.Config.FastAnswerThresholdMs, core.Config.FastAnswerFlagCount)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":           data,
+		"threshold_ms":      core.Config.FastAnswerThresholdMs,
+		"flag_answer_count": core.Config.FastAnswerFlagCount,
+	})
+}
@@ -1,12 +1,12 @@
 package api
 
 import (
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
 	"github.com/open-lms-test-functionality/models"
 	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
 	"github.com/open-lms-test-functionality/utils"
 	"go.uber.org/zap"
 )
@@ -14,6 +14,7 @@ import (
 func CreateQuestion(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -22,27 +23,6 @@ func CreateQuestion(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
 	var questionData models.QuestionCreateSchema
 	if err := c.Bind(&questionData); err != nil {
 		logger.Logger.Error("API :: Error while binding request data with question create schema.",
@@ -62,7 +42,7 @@ func CreateQuestion(c *gin.Context) {
 		return
 	}
 
-	id, err := questionData.Insert(uuidString)
+	id, err := store.Default.Questions.CreateQuestion(ctx, uuidString, questionData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -79,6 +59,7 @@ func CreateQuestion(c *gin.Context) {
 func UpdateQuestion(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -87,27 +68,6 @@ func UpdateQuestion(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
 	var questionData models.QuestionCreateSchema
 	if err := c.Bind(&questionData); err != nil {
 		logger.Logger.Error("API :: Error while binding request data with question create schema.",
@@ -120,7 +80,7 @@ func UpdateQuestion(c *gin.Context) {
 		return
 	}
 
-	id, err := questionData.Update(uuidString, uri.QuestionId)
+	id, err := store.Default.Questions.UpdateQuestion(ctx, uuidString, uri.QuestionId, questionData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -137,6 +97,7 @@ func UpdateQuestion(c *gin.Context) {
 func DeleteQuestion(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -145,28 +106,7 @@ func DeleteQuestion(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	status, err := models.DeleteQuestion(uuidString, uri.QuestionId)
+	status, err := store.Default.Questions.DeleteQuestion(ctx, uuidString, uri.QuestionId)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -182,6 +122,7 @@ func DeleteQuestion(c *gin.Context) {
 func FetchQuestion(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -190,28 +131,7 @@ func FetchQuestion(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	testData, err := models.FetchQuestion(uuidString, uri.QuestionId)
+	testData, err := store.Default.Questions.FetchQuestion(ctx, uuidString, uri.QuestionId)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -227,6 +147,7 @@ func FetchQuestion(c *gin.Context) {
 func FetchQuestions(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -235,43 +156,13 @@ func FetchQuestions(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
+	page, err := parsePage(c)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	limitQuery := c.DefaultQuery("limit", "0")
-	offsetQuery := c.DefaultQuery("offset", "0")
-	limit, _ := strconv.Atoi(limitQuery)
-	offset, _ := strconv.Atoi(offsetQuery)
-
-	if limit > 50 {
-		c.JSON(400, gin.H{
-			"message": "please check query params - param should not greater than 50",
-		})
+		c.JSON(400, gin.H{"message": err.Error()})
 		return
 	}
-	if limit == 0 {
-		limit = 10
-	}
 
-	testData, count, err := models.FetchQuestions(uuidString, limit, offset, false)
+	testData, count, err := store.Default.Questions.FetchQuestions(ctx, uuidString, page, false)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -279,17 +170,5 @@ func FetchQuestions(c *gin.Context) {
 		return
 	}
 
-	if count == 0 {
-		emptyArray := make([]string, 0)
-		c.JSON(200, gin.H{
-			"message": emptyArray,
-			"count":   count,
-		})
-		return
-	}
-
-	c.JSON(200, gin.H{
-		"message": testData,
-		"count":   count,
-	})
+	c.JSON(200, pageResponse(testData, count, page, func(question models.QuestionResponseSchema) int64 { return question.Id }))
 }
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+// RevokeCurrentSession revokes the session of the request's access token. It
+// runs before the JWT logout handler, which clears the cookie and responds.
+func RevokeCurrentSession(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	userDataFromDb := middleware.CurrentUser(c)
+	if _, err := store.Default.Sessions.RevokeSession(ctx, uuidString, userDataFromDb.Id, middleware.CurrentSessionId(c), models.SESSIONREVOKEDLOGOUT); err != nil {
+		c.AbortWithStatusJSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	c.Next()
+}
+
+func sessionsResponse(c *gin.Context, uuidString string, userId int64) {
+	ctx := middleware.RequestContext(c, uuidString)
+	data, err := store.Default.Sessions.FetchUserSessions(ctx, uuidString, userId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	currentSessionId := middleware.CurrentSessionId(c)
+	for i := range data {
+		data[i].Current = data[i].Id == currentSessionId
+	}
+	if len(data) == 0 {
+		data = make([]models.SessionSchema, 0)
+	}
+
+	c.JSON(200, gin.H{
+		"message": data,
+		"count":   len(data),
+	})
+}
+
+func FetchMySessions(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	userDataFromDb := middleware.CurrentUser(c)
+	sessionsResponse(c, uuidString, userDataFromDb.Id)
+}
+
+func RevokeMySession(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	id, err := store.Default.Sessions.RevokeSession(ctx, uuidString, userDataFromDb.Id, uri.SessionId, models.SESSIONREVOKEDBYUSER)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+	if id == 0 {
+		c.JSON(404, gin.H{
+			"message": "session not found",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": true,
+	})
+}
+
+func AdminFetchUserSessions(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	sessionsResponse(c, uuidString, uri.UserId)
+}
+
+func AdminRevokeUserSessions(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
+		return
+	}
+
+	if _, ok := fetchAdminTargetUser(c, uuidString, uri.UserId); !ok {
+		return
+	}
+
+	count, err := store.Default.Sessions.RevokeUserSessions(ctx, uuidString, uri.UserId, models.SESSIONREVOKEDBYADMIN)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": count,
+	})
+}
@@ -1,12 +1,14 @@
 package api
 
 import (
-	"strconv"
+	"context"
 
 	"github.com/gin-gonic/gin"
 	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
 	"github.com/open-lms-test-functionality/models"
 	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
 	"github.com/open-lms-test-functionality/utils"
 	"go.uber.org/zap"
 )
@@ -14,6 +16,7 @@ import (
 func SubmitTestQuestionSubmission(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -36,12 +39,21 @@ func SubmitTestQuestionSubmission(c *gin.Context) {
 
 	logger.Logger.Debug("API :: question answer data ", zap.Any("data", questionAnswerData), zap.Any("1", questionAnswerData["answer_data"]))
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
+	userDataFromDb := middleware.CurrentUser(c)
+
+	// The question is graded and the submission stored in one transaction. It
+	// runs at read committed: concurrent submissions of the same answer wait
+	// for each other on the row's unique key and the last one wins, where
+	// serializable transactions would fail and run out of retries.
+	var id int64
+	err := store.Default.WithReadCommittedTx(ctx, func(ctx context.Context) error {
+		questionData, err := store.Default.Questions.FetchQuestion(ctx, uuidString, uri.QuestionId)
+		if err != nil {
+			return err
+		}
+		id, err = store.Default.Submissions.CreateOrUpdateTestQuestionSubmission(ctx, uuidString, uri.TestId, userDataFromDb.Id, uri.QuestionId, questionAnswerData, questionData, c.ClientIP(), c.Request.UserAgent())
+		return err
+	})
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "something went wrong",
@@ -49,38 +61,44 @@ func SubmitTestQuestionSubmission(c *gin.Context) {
 		return
 	}
 
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "teacher" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
+	c.JSON(200, gin.H{
+		"message": id,
+	})
+}
+
+func GetTestQuestionSubmissions(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var uri schemas.URI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		logger.Logger.Error("API :: Error while uri binding", zap.Error(err), zap.String("requestId", uuidString))
+		c.JSON(400, gin.H{"message": err})
 		return
 	}
 
-	questionData, err := models.FetchQuestion(uuidString, uri.QuestionId)
+	page, err := parsePage(c)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
+		c.JSON(400, gin.H{"message": err.Error()})
 		return
 	}
 
-	id, err := models.CreateOrUpdateTestQuestionSubmission(uuidString, uri.TestId, userDataFromDb.Id, uri.QuestionId, questionAnswerData, questionData)
+	userDataFromDb := middleware.CurrentUser(c)
+
+	data, count, err := store.Default.Submissions.FetchTestQuestionSubmissions(ctx, uuidString, uri.TestId, userDataFromDb.Id, page)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
+		c.JSON(400, gin.H{"message": "something went wrong"})
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"message": id,
-	})
+	c.JSON(200, pageResponse(data, count, page, func(submission models.TestQuestionSubmissionSchema) int64 { return submission.Id }))
 }
 
-func GetTestQuestionSubmissions(c *gin.Context) {
+func GetStudentTestQuestionSubmissions(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -89,43 +107,17 @@ func GetTestQuestionSubmissions(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	limitQuery := c.DefaultQuery("limit", "0")
-	offsetQuery := c.DefaultQuery("offset", "0")
-	limit, _ := strconv.Atoi(limitQuery)
-	offset, _ := strconv.Atoi(offsetQuery)
-
-	if limit > 50 {
-		c.JSON(400, gin.H{
-			"message": "please check query params - param should not greater than 50",
-		})
+	page, err := parsePage(c)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
 		return
 	}
-	if limit == 0 {
-		limit = 10
-	}
 
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	data, count, err := models.FetchTestQuestionSubmissions(uuidString, uri.TestId, userDataFromDb.Id, limit, offset)
+	data, count, err := store.Default.Submissions.FetchTestQuestionSubmissions(ctx, uuidString, uri.TestId, uri.UserId, page)
 	if err != nil {
 		c.JSON(400, gin.H{"message": "something went wrong"})
 		return
 	}
 
-	if count == 0 {
-		emptyArray := make([]string, 0)
-		c.JSON(200, gin.H{
-			"message": emptyArray,
-			"count":   count,
-		})
-		return
-	}
-
-	c.JSON(200, gin.H{
-		"message": data,
-		"count":   count,
-	})
+	c.JSON(200, pageResponse(data, count, page, func(submission models.TestQuestionSubmissionSchema) int64 { return submission.Id }))
 }
@@ -1,12 +1,12 @@
 package api
 
 import (
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
 	"github.com/open-lms-test-functionality/models"
 	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
 	"github.com/open-lms-test-functionality/utils"
 	"go.uber.org/zap"
 )
@@ -14,6 +14,7 @@ import (
 func CreateTestQuestionary(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -22,12 +23,7 @@ func CreateTestQuestionary(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
+	allQuestions, _, err := store.Default.Questions.FetchQuestions(ctx, uuidString, models.Page{Limit: 50}, true)
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "something went wrong",
@@ -35,23 +31,7 @@ func CreateTestQuestionary(c *gin.Context) {
 		return
 	}
 
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	allQuestions, count, err := models.FetchQuestions(uuidString, 50, 0, true)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	if count == 0 {
+	if len(allQuestions) == 0 {
 		c.JSON(400, gin.H{
 			"message": "no questions to create questionary",
 		})
@@ -63,7 +43,7 @@ func CreateTestQuestionary(c *gin.Context) {
 		questionIds = append(questionIds, questionData.Id)
 	}
 
-	_, err = models.CreateTestQuestionary(uuidString, uri.TestId, questionIds)
+	_, err = store.Default.Tests.CreateTestQuestionary(ctx, uuidString, uri.TestId, questionIds)
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "something went wrong",
@@ -79,6 +59,7 @@ func CreateTestQuestionary(c *gin.Context) {
 func AddTestQuestion(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -87,28 +68,7 @@ func AddTestQuestion(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	_, err = models.CreateTestQuestionary(uuidString, uri.TestId, []int64{uri.QuestionId})
+	_, err := store.Default.Tests.CreateTestQuestionary(ctx, uuidString, uri.TestId, []int64{uri.QuestionId})
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "something went wrong",
@@ -125,6 +85,7 @@ func AddTestQuestion(c *gin.Context) {
 func DeleteTestQuestion(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -133,28 +94,7 @@ func DeleteTestQuestion(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	status, err := models.DeleteTestQuestionary(uuidString, uri.TestId, uri.QuestionId)
+	status, err := store.Default.Tests.DeleteTestQuestionary(ctx, uuidString, uri.TestId, uri.QuestionId)
 	if err != nil {
 		c.JSON(400, gin.H{
 			"message": "something went wrong",
@@ -171,6 +111,7 @@ func DeleteTestQuestion(c *gin.Context) {
 func FetchTestQuestionary(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -179,37 +120,18 @@ func FetchTestQuestionary(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
+	userDataFromDb := middleware.CurrentUser(c)
 
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
+	page, err := parsePage(c)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	limitQuery := c.DefaultQuery("limit", "0")
-	offsetQuery := c.DefaultQuery("offset", "0")
-	limit, _ := strconv.Atoi(limitQuery)
-	offset, _ := strconv.Atoi(offsetQuery)
-
-	if limit > 50 {
-		c.JSON(400, gin.H{
-			"message": "please check query params - param should not greater than 50",
-		})
+		c.JSON(400, gin.H{"message": err.Error()})
 		return
 	}
-	if limit == 0 {
-		limit = 10
-	}
 
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "student" {
-		data, count, err := models.FetchTestQuestionaryForStrudent(uuidString, uri.TestId, limit, offset)
+	// Users who can read the question bank see the answers, test takers only
+	// get the questions.
+	if userDataFromDb.HasPermission(models.PermissionQuestionRead) {
+		data, count, err := store.Default.Tests.FetchTestQuestionaryForTeacher(ctx, uuidString, uri.TestId, page)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": "something went wrong",
@@ -217,22 +139,10 @@ func FetchTestQuestionary(c *gin.Context) {
 			return
 		}
 
-		if count == 0 {
-			emptyArray := make([]string, 0)
-			c.JSON(200, gin.H{
-				"message": emptyArray,
-				"count":   count,
-			})
-			return
-		}
-
-		c.JSON(200, gin.H{
-			"message": data,
-			"count":   count,
-		})
+		c.JSON(200, pageResponse(data, count, page, func(testQuestion models.TestQuestionsSchema) int64 { return testQuestion.Id }))
 		return
-	} else if userTypeStr == "teacher" {
-		data, count, err := models.FetchTestQuestionaryForTeacher(uuidString, uri.TestId, limit, offset)
+	} else if userDataFromDb.HasPermission(models.PermissionTestTake) {
+		data, count, err := store.Default.Tests.FetchTestQuestionaryForStudent(ctx, uuidString, uri.TestId, page)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": "something went wrong",
@@ -240,23 +150,12 @@ func FetchTestQuestionary(c *gin.Context) {
 			return
 		}
 
-		if count == 0 {
-			emptyArray := make([]string, 0)
-			c.JSON(200, gin.H{
-				"message": emptyArray,
-				"count":   count,
-			})
-			return
-		}
-
-		c.JSON(200, gin.H{
-			"message": data,
-			"count":   count,
-		})
+		c.JSON(200, pageResponse(data, count, page, func(testQuestion models.TestQuestionSchemaForTakeTest) int64 { return testQuestion.Id }))
 		return
 	} else {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
+		e := middleware.NewForbidden("you're not allowed for this operation")
+		c.JSON(e.Status(), gin.H{
+			"message": e.Message,
 		})
 		return
 	}
@@ -1,12 +1,12 @@
 package api
 
 import (
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
 	"github.com/open-lms-test-functionality/models"
 	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
 	"github.com/open-lms-test-functionality/utils"
 	"go.uber.org/zap"
 )
@@ -14,6 +14,7 @@ import (
 func CreateTest(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -22,27 +23,6 @@ func CreateTest(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
 	var testData models.TestCreateSchema
 	if err := c.Bind(&testData); err != nil {
 		logger.Logger.Error("API :: Error while binding request data with test create schema.",
@@ -55,7 +35,7 @@ func CreateTest(c *gin.Context) {
 		return
 	}
 
-	id, err := testData.Insert(uuidString)
+	id, err := store.Default.Tests.CreateTest(ctx, uuidString, testData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -72,6 +52,7 @@ func CreateTest(c *gin.Context) {
 func UpdateTest(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -80,27 +61,6 @@ func UpdateTest(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
 	var testData models.TestCreateSchema
 	if err := c.Bind(&testData); err != nil {
 		logger.Logger.Error("API :: Error while binding request data with test create schema.",
@@ -113,7 +73,7 @@ func UpdateTest(c *gin.Context) {
 		return
 	}
 
-	id, err := testData.Update(uuidString, uri.TestId)
+	id, err := store.Default.Tests.UpdateTest(ctx, uuidString, uri.TestId, testData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -130,6 +90,7 @@ func UpdateTest(c *gin.Context) {
 func DeleteTest(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -138,28 +99,7 @@ func DeleteTest(c *gin.Context) {
 		return
 	}
 
-	user, _ := c.Get("id")
-	userEmail := user.(*models.UserSchema).Email
-
-	userDataFromDb := models.FetchUserForAuth(userEmail)
-
-	userType, err := strconv.Atoi(userDataFromDb.Type)
-	if err != nil {
-		c.JSON(400, gin.H{
-			"message": "something went wrong",
-		})
-		return
-	}
-
-	userTypeStr := models.ValidateUserType(userType)
-	if userTypeStr == "" || userTypeStr == "student" {
-		c.JSON(400, gin.H{
-			"message": "you're not allowed for this operation",
-		})
-		return
-	}
-
-	status, err := models.DeleteTest(uuidString, uri.TestId)
+	status, err := store.Default.Tests.DeleteTest(ctx, uuidString, uri.TestId)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -175,6 +115,7 @@ func DeleteTest(c *gin.Context) {
 func FetchTest(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -183,7 +124,7 @@ func FetchTest(c *gin.Context) {
 		return
 	}
 
-	testData, err := models.FetchTest(uuidString, uri.TestId)
+	testData, err := store.Default.Tests.FetchTest(ctx, uuidString, uri.TestId)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -199,6 +140,7 @@ func FetchTest(c *gin.Context) {
 func FetchTests(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -207,22 +149,13 @@ func FetchTests(c *gin.Context) {
 		return
 	}
 
-	limitQuery := c.DefaultQuery("limit", "0")
-	offsetQuery := c.DefaultQuery("offset", "0")
-	limit, _ := strconv.Atoi(limitQuery)
-	offset, _ := strconv.Atoi(offsetQuery)
-
-	if limit > 50 {
-		c.JSON(400, gin.H{
-			"message": "please check query params - param should not greater than 50",
-		})
+	page, err := parsePage(c)
+	if err != nil {
+		c.JSON(400, gin.H{"message": err.Error()})
 		return
 	}
-	if limit == 0 {
-		limit = 10
-	}
 
-	testData, count, err := models.FetchTests(uuidString, limit, offset)
+	testData, count, err := store.Default.Tests.FetchTests(ctx, uuidString, page)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -230,18 +163,6 @@ func FetchTests(c *gin.Context) {
 		return
 	}
 
-	if count == 0 {
-		emptyArray := make([]string, 0)
-		c.JSON(200, gin.H{
-			"message": emptyArray,
-			"count":   count,
-		})
-		return
-	}
-
-	c.JSON(200, gin.H{
-		"message": testData,
-		"count":   count,
-	})
+	c.JSON(200, pageResponse(testData, count, page, func(test models.TestResponseSchema) int64 { return test.Id }))
 
 }
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 	"github.com/open-lms-test-functionality/core"
 	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/middleware"
 	"github.com/open-lms-test-functionality/models"
 	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
 	"github.com/open-lms-test-functionality/utils"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
@@ -14,6 +18,7 @@ import (
 func CreateUser(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -34,7 +39,7 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
-	if userType := models.GetUserType(userData.Type); userType == 0 {
+	if userType := models.GetUserType(userData.Type); !models.IsSelfRegistrableUserType(userType) {
 		c.JSON(400, gin.H{
 			"message": "please check request body",
 		})
@@ -52,13 +57,14 @@ func CreateUser(c *gin.Context) {
 	}
 	userData.Password = string(passwordHashBytes)
 
-	id, err := userData.Insert(uuidString)
+	id, err := store.Default.Users.CreateUser(ctx, uuidString, userData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
 		})
 		return
 	}
+	go sendUserTokenEmail(ctx, uuidString, id, userData.Email, models.TOKENPURPOSEEMAILVERIFICATION)
 
 	c.JSON(200, gin.H{
 		"message": id,
@@ -69,6 +75,7 @@ func CreateUser(c *gin.Context) {
 func UpdateUser(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -89,7 +96,7 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	id, err := userData.Update(uuidString, uri.UserId)
+	id, err := store.Default.Users.UpdateUser(ctx, uuidString, uri.UserId, userData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -102,9 +109,26 @@ func UpdateUser(c *gin.Context) {
 	})
 }
 
+// deactivateUser deactivates an account and revokes its sessions together, so
+// a failure cannot leave a deactivated user signed in.
+func deactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	var id int64
+	err := store.Default.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = store.Default.Users.DeactivateUser(ctx, uuidString, userId)
+		if err != nil {
+			return err
+		}
+		_, err = store.Default.Sessions.RevokeUserSessions(ctx, uuidString, userId, models.SESSIONREVOKEDDEACTIVATED)
+		return err
+	})
+	return id, err
+}
+
 func DeleteUser(c *gin.Context) {
 	uuidString := utils.GetUUID()
 	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
 
 	var uri schemas.URI
 	if err := c.ShouldBindUri(&uri); err != nil {
@@ -113,7 +137,75 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	status, err := models.DeleteUserFromDB(uuidString, uri.UserId)
+	_, err := deactivateUser(ctx, uuidString, uri.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": true,
+	})
+}
+
+func FetchMe(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+
+	userDataFromDb := middleware.CurrentUser(c)
+	if userDataFromDb.Id == 0 {
+		c.JSON(400, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	userType := userDataFromDb.UserType()
+	permissions := userDataFromDb.Permissions()
+	if permissions == nil {
+		permissions = make([]models.Permission, 0)
+	}
+
+	c.JSON(200, gin.H{
+		"message": models.UserResponseSchema{
+			Id:        userDataFromDb.Id,
+			FirstName: userDataFromDb.FirstName,
+			LastName:  userDataFromDb.LastName,
+			Email:     userDataFromDb.Email,
+			Type:      models.ValidateUserType(userType),
+		},
+		"permissions": permissions,
+	})
+}
+
+func UpdateMe(c *gin.Context) {
+	uuidString := utils.GetUUID()
+	c.Header("X-REQUEST-ID", uuidString)
+	ctx := middleware.RequestContext(c, uuidString)
+
+	var userData models.UserCreateSchema
+	if err := c.Bind(&userData); err != nil {
+		logger.Logger.Error("API :: Error while binding request data with user update schema.",
+			zap.String("requestId", uuidString),
+			zap.Error(err),
+		)
+		c.JSON(400, gin.H{
+			"message": "something went wrong - please check request body",
+		})
+		return
+	}
+
+	userDataFromDb := middleware.CurrentUser(c)
+	if userDataFromDb.Id == 0 {
+		c.JSON(400, gin.H{
+			"message": "something went wrong",
+		})
+		return
+	}
+
+	id, err := store.Default.Users.UpdateUser(ctx, uuidString, userDataFromDb.Id, userData)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"message": "something went wrong",
@@ -122,6 +214,6 @@ func DeleteUser(c *gin.Context) {
 	}
 
 	c.JSON(200, gin.H{
-		"message": status,
+		"message": id,
 	})
 }
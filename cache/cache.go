@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+// Cache keeps short lived copies of values that are expensive to load. A
+// missing key is not an error: Get reports it with found set to false.
+type Cache interface {
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// ErrClosed is returned by caches used after Close.
+var ErrClosed = errors.New("cache: closed")
+
+// Default is the cache configured by CacheInit, nil when caching is disabled.
+var Default Cache
+
+// NewCache builds the cache selected by the cache driver setting. It returns a
+// nil Cache for the "none" driver.
+func NewCache() (Cache, error) {
+	cacheConfig := core.Config.Cache
+	switch cacheConfig.Driver {
+	case "memory", "":
+		return NewLRU(cacheConfig.Size), nil
+	case "redis":
+		return NewRedis(RedisOptions{
+			Addr:     cacheConfig.Addr,
+			Password: cacheConfig.Password,
+			DB:       cacheConfig.DB,
+		}), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver %q", cacheConfig.Driver)
+	}
+}
+
+func CacheInit() error {
+	cache, err := NewCache()
+	if err != nil {
+		return err
+	}
+	Default = cache
+	logger.Logger.Info("Cache configured Successfully", zap.String("driver", core.Config.Cache.Driver))
+	return nil
+}
@@ -0,0 +1,257 @@
+// Package cachetest provides an in-process stand-in for a Redis server, so the
+// Redis cache can be exercised without running one. It keeps strings in
+// memory and understands the commands the cache sends: PING, AUTH, SELECT,
+// GET, SET (with EX or PX), DEL and EXISTS, plus FLUSHALL for resetting it.
+//
+//	server := cachetest.NewServer("secret")
+//	defer server.Close()
+//	// set cache.driver to "redis" and cache.addr to server.Addr()
+package cachetest
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-lms-test-functionality/cache"
+)
+
+type entry struct {
+	value     string
+	expiresAt time.Time
+}
+
+type Server struct {
+	Password string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	data     map[int]map[string]entry // keyed by database number
+	conns    map[net.Conn]struct{}
+	commands []string
+}
+
+// NewServer starts a server on a random local port. When password is not
+// empty, clients must AUTH before any other command. It panics if it cannot
+// listen, like httptest.NewServer.
+func NewServer(password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("cachetest: failed to listen: " + err.Error())
+	}
+	s := &Server{
+		Password: password,
+		listener: listener,
+		data:     make(map[int]map[string]entry),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and drops every client connection.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Keys returns the live keys of database db in sorted order.
+func (s *Server) Keys(db int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key, e := range s.data[db] {
+		if !e.expired() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Commands returns the names of the commands received so far, in order.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (e entry) expired() bool {
+	return !e.expiresAt.IsZero() && time.Now().After(e.expiresAt)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// client is the state of one connection.
+type client struct {
+	authenticated bool
+	db            int
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	state := &client{authenticated: s.Password == ""}
+	for {
+		request, err := cache.ReadReply(reader)
+		if err != nil {
+			return
+		}
+		parts, ok := request.([]any)
+		if !ok || len(parts) == 0 {
+			conn.Write(errorReply("ERR protocol error"))
+			return
+		}
+		args := make([]string, len(parts))
+		for i, part := range parts {
+			value, ok := part.([]byte)
+			if !ok {
+				conn.Write(errorReply("ERR protocol error"))
+				return
+			}
+			args[i] = string(value)
+		}
+		if _, err := conn.Write(s.execute(state, args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(state *client, args []string) []byte {
+	name := strings.ToUpper(args[0])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, name)
+
+	if name == "AUTH" {
+		if len(args) != 2 {
+			return errorReply("ERR wrong number of arguments for 'auth' command")
+		}
+		if s.Password == "" {
+			return errorReply("ERR Client sent AUTH, but no password is set")
+		}
+		if args[1] != s.Password {
+			return errorReply("WRONGPASS invalid username-password pair")
+		}
+		state.authenticated = true
+		return []byte("+OK\r\n")
+	}
+	if !state.authenticated {
+		return errorReply("NOAUTH Authentication required.")
+	}
+
+	db := s.data[state.db]
+	if db == nil {
+		db = make(map[string]entry)
+		s.data[state.db] = db
+	}
+	switch name {
+	case "PING":
+		return []byte("+PONG\r\n")
+	case "SELECT":
+		if len(args) != 2 {
+			return errorReply("ERR wrong number of arguments for 'select' command")
+		}
+		number, err := strconv.Atoi(args[1])
+		if err != nil || number < 0 || number > 15 {
+			return errorReply("ERR DB index is out of range")
+		}
+		state.db = number
+		return []byte("+OK\r\n")
+	case "GET":
+		if len(args) != 2 {
+			return errorReply("ERR wrong number of arguments for 'get' command")
+		}
+		e, ok := db[args[1]]
+		if !ok || e.expired() {
+			delete(db, args[1])
+			return []byte("$-1\r\n")
+		}
+		return bulkReply(e.value)
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			return errorReply("ERR syntax error")
+		}
+		e := entry{value: args[2]}
+		if len(args) == 5 {
+			amount, err := strconv.ParseInt(args[4], 10, 64)
+			if err != nil || amount <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			switch strings.ToUpper(args[3]) {
+			case "EX":
+				e.expiresAt = time.Now().Add(time.Duration(amount) * time.Second)
+			case "PX":
+				e.expiresAt = time.Now().Add(time.Duration(amount) * time.Millisecond)
+			default:
+				return errorReply("ERR syntax error")
+			}
+		}
+		db[args[1]] = e
+		return []byte("+OK\r\n")
+	case "DEL", "EXISTS":
+		if len(args) < 2 {
+			return errorReply("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		}
+		count := 0
+		for _, key := range args[1:] {
+			if e, ok := db[key]; ok && !e.expired() {
+				count++
+			}
+			if name == "DEL" {
+				delete(db, key)
+			}
+		}
+		return []byte(":" + strconv.Itoa(count) + "\r\n")
+	case "FLUSHALL":
+		s.data = make(map[int]map[string]entry)
+		return []byte("+OK\r\n")
+	default:
+		return errorReply("ERR unknown command '" + args[0] + "'")
+	}
+}
+
+func errorReply(message string) []byte {
+	return []byte("-" + message + "\r\n")
+}
+
+func bulkReply(value string) []byte {
+	return []byte("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func lruHas(t *testing.T, l *LRU, key string) bool {
+	t.Helper()
+	_, ok, err := l.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+	l.Set(ctx, "a", []byte("1"), 0)
+	l.Set(ctx, "b", []byte("2"), 0)
+
+	// Reading a makes b the least recently used entry.
+	if !lruHas(t, l, "a") {
+		t.Fatal("a is not cached")
+	}
+	l.Set(ctx, "c", []byte("3"), 0)
+	if lruHas(t, l, "b") {
+		t.Error("b was not evicted")
+	}
+	if !lruHas(t, l, "a") || !lruHas(t, l, "c") {
+		t.Error("a or c was evicted")
+	}
+	if l.Len() != 2 {
+		t.Errorf("Len = %d, want 2", l.Len())
+	}
+
+	// Overwriting an entry refreshes it without growing the cache.
+	l.Set(ctx, "a", []byte("4"), 0)
+	l.Set(ctx, "d", []byte("5"), 0)
+	if lruHas(t, l, "c") {
+		t.Error("c was not evicted")
+	}
+	if value, ok, _ := l.Get(ctx, "a"); !ok || string(value) != "4" {
+		t.Errorf("Get(a) = %q, %v, want the overwritten value", value, ok)
+	}
+}
+
+func TestLRUExpiresAndDeletes(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(10)
+	l.Set(ctx, "short", []byte("1"), time.Millisecond)
+	l.Set(ctx, "long", []byte("2"), time.Minute)
+	l.Set(ctx, "forever", []byte("3"), 0)
+
+	time.Sleep(5 * time.Millisecond)
+	if lruHas(t, l, "short") {
+		t.Error("expired entry was served")
+	}
+	if !lruHas(t, l, "long") || !lruHas(t, l, "forever") {
+		t.Error("unexpired entry was not served")
+	}
+
+	l.Delete(ctx, "long", "forever", "missing")
+	if lruHas(t, l, "long") || lruHas(t, l, "forever") {
+		t.Error("deleted entry was served")
+	}
+	if l.Len() != 0 {
+		t.Errorf("Len = %d, want 0", l.Len())
+	}
+}
+
+func TestLRUReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(10)
+	value := []byte("value")
+	l.Set(ctx, "key", value, 0)
+	value[0] = 'X'
+
+	got, _, _ := l.Get(ctx, "key")
+	got[1] = 'X'
+	if again, _, _ := l.Get(ctx, "key"); string(again) != "value" {
+		t.Errorf("Get = %q, want the value as it was set", again)
+	}
+}
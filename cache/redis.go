@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	REDISDEFAULTADDR    string        = "127.0.0.1:6379"
+	REDISMAXIDLE        int           = 10
+	REDISCOMMANDTIMEOUT time.Duration = 2 * time.Second
+)
+
+// RedisOptions configures the connection to a server speaking the Redis
+// protocol (Redis, Valkey, KeyDB, ...).
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	MaxIdle  int // connections kept open between commands
+}
+
+// Redis is a cache shared by every instance of the application. It speaks
+// RESP2 over plain TCP and only uses GET, SET and DEL, plus AUTH and SELECT
+// when a connection is opened.
+type Redis struct {
+	options RedisOptions
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// RedisError is an error reply sent by the server.
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedis returns a Redis cache. Connections are opened on first use.
+func NewRedis(options RedisOptions) *Redis {
+	if options.Addr == "" {
+		options.Addr = REDISDEFAULTADDR
+	}
+	if options.MaxIdle <= 0 {
+		options.MaxIdle = REDISMAXIDLE
+	}
+	return &Redis{options: options}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key. A ttl of zero keeps the entry until it is
+// evicted or deleted.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Close closes the idle connections; commands still running close theirs
+// when they finish.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for _, conn := range r.idle {
+		conn.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+// do sends one command and reads its reply. A connection that fails in any
+// way other than an error reply is closed instead of being reused, since its
+// read position in the stream is unknown.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	r.put(conn)
+	return reply, err
+}
+
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(r.idle); n > 0 {
+		conn := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return conn, nil
+	}
+	r.mu.Unlock()
+	return r.dial(ctx)
+}
+
+func (r *Redis) put(conn *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || len(r.idle) >= r.options.MaxIdle {
+		conn.Close()
+		return
+	}
+	r.idle = append(r.idle, conn)
+}
+
+func (r *Redis) dial(ctx context.Context) (*redisConn, error) {
+	var dialer net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, REDISCOMMANDTIMEOUT)
+	defer cancel()
+	netConn, err := dialer.DialContext(dialCtx, "tcp", r.options.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if r.options.Password != "" {
+		if _, err := conn.do(ctx, "AUTH", r.options.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.options.DB != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(r.options.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (conn *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > REDISCOMMANDTIMEOUT {
+		deadline = time.Now().Add(REDISCOMMANDTIMEOUT)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(WriteCommand(nil, args...)); err != nil {
+		return nil, err
+	}
+	return ReadReply(conn.reader)
+}
+
+// WriteCommand appends args to buf as a RESP array of bulk strings.
+func WriteCommand(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// ReadReply reads one RESP2 value: a simple string as string, an integer as
+// int64, a bulk string as []byte, an array as []any and a null as nil. Error
+// replies are returned as RedisError, or kept as RedisError values inside an
+// array.
+func ReadReply(reader *bufio.Reader) (any, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		values := make([]any, size)
+		for i := range values {
+			value, err := ReadReply(reader)
+			var redisErr RedisError
+			if errors.As(err, &redisErr) {
+				// Keep reading so the rest of the array is consumed.
+				values[i] = redisErr
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+const usage = `usage: web-app <command> [arguments]
+
+commands:
+  serve [-migrate]          start the HTTP server (default), applying pending migrations first with -migrate
+  migrate up                apply every pending migration
+  migrate down N            revert the last N migrations
+  migrate goto V            migrate up or down to version V
+  migrate version           print the schema version
+  migrate force V           set the schema version to V and clear the dirty flag, after a manual repair
+  migrate status            list migrations; exits 1 when the schema is dirty or behind
+  create-admin -email ...   create or promote the first admin
+`
+
+// run dispatches the command line to a command and returns its exit code: 0
+// on success, 1 when the command failed and 2 for usage errors.
+func run(args []string) int {
+	if len(args) == 0 {
+		return serve(nil)
+	}
+	switch args[0] {
+	case "serve":
+		return serve(args[1:])
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "create-admin":
+		return createAdmin(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// migrateCommand manages the database schema:
+//
+//	web-app migrate up|down N|goto V|version|force V|status
+//
+// Results are printed to stdout and progress is logged.
+func migrateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	subcommand, args := args[0], args[1:]
+
+	wantArgs := 0
+	switch subcommand {
+	case "down", "goto", "force":
+		wantArgs = 1
+	case "up", "version", "status":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", subcommand, usage)
+		return 2
+	}
+	if len(args) != wantArgs {
+		fmt.Fprintf(os.Stderr, "migrate %s :: expected %d argument(s)\n\n%s", subcommand, wantArgs, usage)
+		return 2
+	}
+	var number int
+	if wantArgs == 1 {
+		var err error
+		number, err = strconv.Atoi(args[0])
+		if err != nil || number < -1 || (subcommand != "force" && number < 0) || (subcommand == "down" && number == 0) {
+			fmt.Fprintf(os.Stderr, "migrate %s :: invalid number %q\n", subcommand, args[0])
+			return 2
+		}
+	}
+
+	core.ReadEnvFile()
+	logger.LoggerInit()
+	defer logger.Logger.Sync()
+
+	var err error
+	switch subcommand {
+	case "up":
+		err = models.MigrateUp()
+	case "down":
+		err = models.MigrateDown(number)
+	case "goto":
+		err = models.MigrateGoto(uint(number))
+	case "force":
+		err = models.MigrateForce(number)
+	case "version", "status":
+		var status models.MigrationStatusSchema
+		status, err = models.FetchMigrationStatus()
+		if err != nil {
+			break
+		}
+		printMigrationStatus(status, subcommand == "status")
+		if subcommand == "status" && (status.Dirty || status.Pending()) {
+			return 1
+		}
+	}
+	if err != nil {
+		logger.Logger.Error("MIGRATE :: Command failed", zap.String("command", subcommand), zap.Error(err))
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(status models.MigrationStatusSchema, list bool) {
+	dirty := ""
+	if status.Dirty {
+		dirty = " (dirty)"
+	}
+	fmt.Printf("version %d%s\n", status.Version, dirty)
+	if !list {
+		return
+	}
+	fmt.Printf("latest  %d\n\n", status.Latest)
+	for _, migration := range status.Migrations {
+		state := "pending"
+		if migration.Applied {
+			state = "applied"
+		}
+		if status.Dirty && migration.Version == status.Version {
+			state = "dirty"
+		}
+		fmt.Printf("%06d  %-8s %s\n", migration.Version, state, migration.Name)
+	}
+}
+
+// createAdmin bootstraps the first admin account:
+//
+//	ADMIN_PASSWORD=... web-app create-admin -email admin@example.com
+//
+// The password is read from the environment so it does not end up in the
+// shell history. If the email already belongs to an account, that account is
+// promoted instead. The command refuses to run once an active admin exists;
+// further admins are managed through the admin APIs.
+func createAdmin(args []string) int {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the admin account")
+	firstName := flags.String("first-name", "", "first name of the admin account")
+	lastName := flags.String("last-name", "", "last name of the admin account")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	if *email == "" || password == "" {
+		fmt.Fprintln(os.Stderr, "create-admin :: -email flag and ADMIN_PASSWORD environment variable are required")
+		return 2
+	}
+
+	core.ReadEnvFile()
+	logger.LoggerInit()
+	defer logger.Logger.Sync()
+	if err := models.CreateConnection(); err != nil {
+		logger.Logger.Error("CREATE ADMIN :: Error while connecting to the database", zap.Error(err))
+		return 1
+	}
+	defer models.CloseConnections()
+	if err := models.CheckMigrations(); err != nil {
+		logger.Logger.Error("CREATE ADMIN :: Refusing to run", zap.Error(err))
+		return 1
+	}
+
+	uuidString := utils.GetUUID()
+	ctx := models.WithRequestId(context.Background(), uuidString)
+	adminCount, err := store.Default.Users.CountActiveUsersOfType(ctx, uuidString, models.ADMIN)
+	if err != nil {
+		logger.Logger.Error("CREATE ADMIN :: Error while checking existing admins", zap.Error(err))
+		return 1
+	}
+	if adminCount > 0 {
+		logger.Logger.Error("CREATE ADMIN :: An admin already exists, use the admin APIs to manage roles")
+		return 1
+	}
+
+	existingUser := store.Default.Users.FetchUserForAuth(ctx, *email)
+	if existingUser.Id != 0 {
+		if _, err := store.Default.Users.UpdateUserType(ctx, uuidString, existingUser.Id, models.ADMIN); err != nil {
+			logger.Logger.Error("CREATE ADMIN :: Error while promoting user", zap.Error(err))
+			return 1
+		}
+		logger.Logger.Info("CREATE ADMIN :: Existing user promoted to admin", zap.Int64("userId", existingUser.Id))
+		return 0
+	}
+
+	passwordHashBytes, err := bcrypt.GenerateFromPassword([]byte(password), core.Config.PasswordHashCost)
+	if err != nil {
+		logger.Logger.Error("CREATE ADMIN :: Error while generating password hash", zap.Error(err))
+		return 1
+	}
+	userData := models.UserCreateSchema{
+		FirstName: *firstName,
+		LastName:  *lastName,
+		Email:     *email,
+		Password:  string(passwordHashBytes),
+		Type:      "admin",
+	}
+	id, err := store.Default.Users.CreateUser(ctx, uuidString, userData)
+	if err != nil {
+		logger.Logger.Error("CREATE ADMIN :: Error while creating admin", zap.Error(err))
+		return 1
+	}
+	logger.Logger.Info("CREATE ADMIN :: Admin created", zap.Int64("userId", id))
+	return 0
+}
@@ -40,13 +40,129 @@ func ReadEnvFile() {
 		os.Exit(1)
 	}
 
-	Config.DBString = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		Config.DBConfig.DBUser,
-		Config.DBConfig.DBPassword,
-		Config.DBConfig.DBHost,
-		Config.DBConfig.DBPort,
-		Config.DBConfig.DBName,
-		Config.DBConfig.DBSSLMode,
+	Config.DBString = databaseString(Config.DBConfig)
+	for _, replica := range Config.DBReplicas {
+		Config.DBReplicaStrings = append(Config.DBReplicaStrings, databaseString(replicaConfig(Config.DBConfig, replica)))
+	}
+
+	setDefaults()
+}
+
+func databaseString(dbConfig schemas.DBConfig) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
+		dbConfig.DBUser,
+		dbConfig.DBPassword,
+		dbConfig.DBHost,
+		dbConfig.DBPort,
+		dbConfig.DBName,
+		dbConfig.DBSSLMode,
 	)
+}
+
+// replicaConfig fills the settings a replica leaves empty from the primary.
+func replicaConfig(primary schemas.DBConfig, replica schemas.DBReplicaConfig) schemas.DBConfig {
+	if replica.DBName == "" {
+		replica.DBName = primary.DBName
+	}
+	if replica.DBUser == "" {
+		replica.DBUser = primary.DBUser
+		if replica.DBPassword == "" {
+			replica.DBPassword = primary.DBPassword
+		}
+	}
+	if replica.DBHost == "" {
+		replica.DBHost = primary.DBHost
+	}
+	if replica.DBPort == "" {
+		replica.DBPort = primary.DBPort
+	}
+	if replica.DBSSLMode == "" {
+		replica.DBSSLMode = primary.DBSSLMode
+	}
+	return replica
+}
 
+// setDefaults fills in optional settings that were left out of the env file.
+func setDefaults() {
+	if Config.FastAnswerThresholdMs == 0 {
+		Config.FastAnswerThresholdMs = 3000
+	}
+	if Config.FastAnswerFlagCount == 0 {
+		Config.FastAnswerFlagCount = 3
+	}
+	if Config.IntegrityMaxEvents == 0 {
+		Config.IntegrityMaxEvents = 10
+	}
+	if Config.IntegrityMaxIPChanges == 0 {
+		Config.IntegrityMaxIPChanges = 1
+	}
+	if Config.Mail.Driver == "" {
+		Config.Mail.Driver = "log"
+	}
+	if Config.EmailVerificationTTLHours == 0 {
+		Config.EmailVerificationTTLHours = 48
+	}
+	if Config.PasswordResetTTLMinutes == 0 {
+		Config.PasswordResetTTLMinutes = 30
+	}
+	if Config.AccessTokenTTLMinutes == 0 {
+		Config.AccessTokenTTLMinutes = 60
+	}
+	if Config.RefreshTokenTTLHours == 0 {
+		Config.RefreshTokenTTLHours = 336
+	}
+	if Config.APIKeyMaxTTLDays == 0 {
+		Config.APIKeyMaxTTLDays = 365
+	}
+	if Config.MFAIssuer == "" {
+		Config.MFAIssuer = "Open LMS"
+	}
+	if Config.LoginProtection.AccountMaxFailures == 0 {
+		Config.LoginProtection.AccountMaxFailures = 10
+	}
+	if Config.LoginProtection.AccountBackoffAfter == 0 {
+		Config.LoginProtection.AccountBackoffAfter = 3
+	}
+	if Config.LoginProtection.IPMaxFailures == 0 {
+		Config.LoginProtection.IPMaxFailures = 100
+	}
+	if Config.LoginProtection.IPBackoffAfter == 0 {
+		Config.LoginProtection.IPBackoffAfter = 20
+	}
+	if Config.LoginProtection.BackoffBaseSeconds == 0 {
+		Config.LoginProtection.BackoffBaseSeconds = 1
+	}
+	if Config.LoginProtection.BackoffMaxSeconds == 0 {
+		Config.LoginProtection.BackoffMaxSeconds = 60
+	}
+	if Config.LoginProtection.LockoutMinutes == 0 {
+		Config.LoginProtection.LockoutMinutes = 15
+	}
+	if Config.LoginProtection.FailureWindowMinutes == 0 {
+		Config.LoginProtection.FailureWindowMinutes = 15
+	}
+	if Config.OIDC.DefaultRole == "" {
+		Config.OIDC.DefaultRole = "student"
+	}
+	if Config.LTI.KeyId == "" {
+		Config.LTI.KeyId = "lti-tool-key"
+	}
+	if Config.DBReplicaMaxLagSeconds == 0 {
+		Config.DBReplicaMaxLagSeconds = 10
+	}
+	if Config.DBConnectAttempts == 0 {
+		Config.DBConnectAttempts = 5
+	}
+	if Config.ShutdownGraceSeconds == 0 {
+		Config.ShutdownGraceSeconds = 30
+	}
+	if Config.Cache.Driver == "" {
+		Config.Cache.Driver = "memory"
+	}
+	if Config.Cache.Size == 0 {
+		Config.Cache.Size = 10000
+	}
+	if Config.Cache.TTLSeconds == 0 {
+		Config.Cache.TTLSeconds = 30
+	}
 }
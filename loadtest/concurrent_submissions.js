@@ -0,0 +1,60 @@
+// Hammers PUT /auth/test/:testId/question/:questionId with many concurrent
+// submissions from one student and checks that exactly one submission row
+// exists for the question afterwards.
+//
+//   k6 run -e BASE_URL=http://localhost:8000 -e EMAIL=student@example.com \
+//     -e PASSWORD=... -e TEST_ID=1 -e QUESTION_ID=1 loadtest/concurrent_submissions.js
+//
+// The question must be part of the test and the student must not have answered
+// it yet, or the run only proves that an existing row is updated.
+import http from 'k6/http';
+import { check, fail } from 'k6';
+
+const baseUrl = __ENV.BASE_URL || 'http://localhost:8000';
+const testId = __ENV.TEST_ID;
+const questionId = __ENV.QUESTION_ID;
+
+export const options = {
+  scenarios: {
+    submissions: {
+      executor: 'shared-iterations',
+      vus: 50,
+      iterations: 500,
+      maxDuration: '1m',
+    },
+  },
+  thresholds: {
+    checks: ['rate==1.0'],
+  },
+};
+
+export function setup() {
+  const login = http.post(`${baseUrl}/token`, JSON.stringify({
+    username: __ENV.EMAIL,
+    password: __ENV.PASSWORD,
+  }), { headers: { 'Content-Type': 'application/json' } });
+  if (login.status !== 200 || !login.json('token')) {
+    fail(`login failed with status ${login.status}`);
+  }
+  return { token: login.json('token') };
+}
+
+function headers(token) {
+  return { headers: { 'Content-Type': 'application/json', Authorization: `Bearer ${token}` } };
+}
+
+export default function (data) {
+  const answer = Math.random() < 0.5 ? 'true' : 'false';
+  const response = http.put(
+    `${baseUrl}/auth/test/${testId}/question/${questionId}`,
+    JSON.stringify({ answer_data: [answer] }),
+    headers(data.token),
+  );
+  check(response, { 'submission accepted': (r) => r.status === 200 });
+}
+
+export function teardown(data) {
+  const response = http.get(`${baseUrl}/auth/test/${testId}/submissions?limit=50`, headers(data.token));
+  const rows = (response.json('message') || []).filter((row) => String(row.question.id) === String(questionId));
+  check(rows, { 'exactly one submission row': (r) => r.length === 1 });
+}
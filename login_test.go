@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/models"
+)
+
+// testConcurrentGuessesLockOut sends wrong passwords for one account all at
+// once and checks that no more of them are checked than the lockout allows.
+func testConcurrentGuessesLockOut(t *testing.T, s *testServer) {
+	core.Config.LoginProtection.AccountMaxFailures = 5
+	core.Config.LoginProtection.AccountBackoffAfter = 10
+	email := fmt.Sprintf("guessed-%d@example.com", time.Now().UnixNano())
+	userId := s.register(email, "secret-password", "student")
+
+	const guesses = 30
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	start := make(chan struct{})
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			code, _ := s.do(http.MethodPost, "/token", "", map[string]string{"username": email, "password": fmt.Sprintf("guess-%d", i)})
+			mu.Lock()
+			codes[code]++
+			mu.Unlock()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if codes[http.StatusUnauthorized] != 5 || codes[http.StatusTooManyRequests] != guesses-5 {
+		t.Fatalf("concurrent guesses: status counts %v, want 5 checked and the rest refused", codes)
+	}
+	if code, response := s.do(http.MethodPost, "/token", "", map[string]string{"username": email, "password": "secret-password"}); code != http.StatusTooManyRequests {
+		t.Fatalf("login with the right password during lockout: status %d, want 429, %v", code, response)
+	}
+	lockouts, _, err := s.stores.LoginThrottles.FetchLoginLockouts(context.Background(), "", userId, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 1 || lockouts[0].Failures != 5 {
+		t.Fatalf("lockouts = %+v, want one after 5 failures", lockouts)
+	}
+}
+
+func TestConcurrentLoginGuessesLockOut(t *testing.T) {
+	testConcurrentGuessesLockOut(t, newTestServer(t))
+}
+
+func TestConcurrentLoginGuessesLockOutPostgres(t *testing.T) {
+	s := newPostgresTestServer(t)
+	// Every test request comes from the same address, whose counter would
+	// otherwise carry over to the next run.
+	t.Cleanup(func() {
+		models.DbPool().Exec(context.Background(), `DELETE FROM login_throttles WHERE scope=$1 AND key=$2`, models.LOGINTHROTTLEIP, "192.0.2.1")
+	})
+	testConcurrentGuessesLockOut(t, s)
+}
+
+func TestRightPasswordDoesNotCountTowardsLockout(t *testing.T) {
+	s := newTestServer(t)
+	core.Config.LoginProtection.AccountMaxFailures = 3
+	core.Config.LoginProtection.AccountBackoffAfter = 10
+	s.register("student@example.com", "secret-password", "student")
+
+	for i := 0; i < 2; i++ {
+		if code, response := s.do(http.MethodPost, "/token", "", map[string]string{"username": "student@example.com", "password": "wrong"}); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: status %d, %v", code, response)
+		}
+	}
+	// Two failures and a success: the success clears the count instead of
+	// being the third strike.
+	s.login("student@example.com", "secret-password")
+	for i := 0; i < 2; i++ {
+		if code, response := s.do(http.MethodPost, "/token", "", map[string]string{"username": "student@example.com", "password": "wrong"}); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password after a login: status %d, %v", code, response)
+		}
+	}
+	s.login("student@example.com", "secret-password")
+}
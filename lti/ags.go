@@ -0,0 +1,173 @@
+package lti
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+const (
+	ScopeScore    = "https://purl.imsglobal.org/spec/lti-ags/scope/score"
+	ScopeLineItem = "https://purl.imsglobal.org/spec/lti-ags/scope/lineitem"
+
+	scoreContentType = "application/vnd.ims.lis.v1.score+json"
+)
+
+// Score is an AGS score publish message.
+type Score struct {
+	UserId           string  `json:"userId"`
+	ScoreGiven       float64 `json:"scoreGiven"`
+	ScoreMaximum     float64 `json:"scoreMaximum"`
+	Comment          string  `json:"comment,omitempty"`
+	ActivityProgress string  `json:"activityProgress"`
+	GradingProgress  string  `json:"gradingProgress"`
+	Timestamp        string  `json:"timestamp"`
+}
+
+type accessToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// AccessToken gets a service access token from the platform with the OAuth2
+// client credentials grant, authenticating with a JWT signed by the tool key.
+// Tokens are cached until shortly before they expire.
+func (t *Tool) AccessToken(ctx context.Context, platform schemas.LTIPlatformConfig, scopes ...string) (string, error) {
+	scope := strings.Join(scopes, " ")
+	cacheKey := platform.Issuer + "|" + platform.ClientID + "|" + scope
+
+	t.mu.Lock()
+	cached, ok := t.accessTokens[cacheKey]
+	t.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	audience := platform.AuthTokenAudience
+	if audience == "" {
+		audience = platform.AuthTokenURL
+	}
+	jti, err := utils.RandomString(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	assertion, err := t.sign(jwt.MapClaims{
+		"iss": platform.ClientID,
+		"sub": platform.ClientID,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"jti": jti,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {assertion},
+		"scope":                 {scope},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, platform.AuthTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResponse accessTokenResponse
+	if err := t.do(req, &tokenResponse); err != nil {
+		return "", fmt.Errorf("lti access token: %w", err)
+	}
+
+	expiresIn := time.Duration(tokenResponse.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = time.Hour
+	}
+	t.mu.Lock()
+	t.accessTokens[cacheKey] = accessToken{
+		token:     tokenResponse.AccessToken,
+		expiresAt: now.Add(expiresIn - time.Minute),
+	}
+	t.mu.Unlock()
+	return tokenResponse.AccessToken, nil
+}
+
+// scoresURL appends /scores to the line item path, keeping any query string.
+func scoresURL(lineItemURL string) (string, error) {
+	parsedURL, err := url.Parse(lineItemURL)
+	if err != nil {
+		return "", err
+	}
+	parsedURL.Path = strings.TrimSuffix(parsedURL.Path, "/") + "/scores"
+	return parsedURL.String(), nil
+}
+
+// PostScore publishes a score for a platform user to a line item.
+func (t *Tool) PostScore(ctx context.Context, platform schemas.LTIPlatformConfig, lineItemURL string, score Score) error {
+	token, err := t.AccessToken(ctx, platform, ScopeScore)
+	if err != nil {
+		return err
+	}
+	targetURL, err := scoresURL(lineItemURL)
+	if err != nil {
+		return err
+	}
+	if score.Timestamp == "" {
+		score.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	body, err := json.Marshal(score)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", scoreContentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if err := t.do(req, nil); err != nil {
+		return fmt.Errorf("lti score passback: %w", err)
+	}
+	return nil
+}
+
+func (t *Tool) do(req *http.Request, v any) error {
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,93 @@
+package lti
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+type LineItem struct {
+	ScoreMaximum float64 `json:"scoreMaximum"`
+	Label        string  `json:"label,omitempty"`
+	ResourceId   string  `json:"resourceId,omitempty"`
+	Tag          string  `json:"tag,omitempty"`
+}
+
+// ContentItem is an ltiResourceLink item returned to the platform by deep
+// linking.
+type ContentItem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title,omitempty"`
+	Text     string            `json:"text,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Custom   map[string]string `json:"custom,omitempty"`
+	LineItem *LineItem         `json:"lineItem,omitempty"`
+}
+
+// DeepLinkingResponse signs the message that returns the selected items to
+// the platform. It is posted by the browser to the deep link return URL.
+func (t *Tool) DeepLinkingResponse(launch Launch, items []ContentItem) (string, error) {
+	nonce, err := utils.RandomString(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                 launch.Platform.ClientID,
+		"aud":                 launch.Platform.Issuer,
+		"iat":                 now.Unix(),
+		"exp":                 now.Add(5 * time.Minute).Unix(),
+		"nonce":               nonce,
+		ClaimMessageType:      MessageTypeDeepLinkingResponse,
+		ClaimVersion:          Version,
+		ClaimDeploymentId:     launch.DeploymentId,
+		ClaimDeepLinkingItems: items,
+	}
+	if launch.DeepLinking != nil && launch.DeepLinking.Data != "" {
+		claims[ClaimDeepLinkingData] = launch.DeepLinking.Data
+	}
+	return t.sign(claims)
+}
+
+func (t *Tool) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = t.KeyId
+	return token.SignedString(t.PrivateKey)
+}
+
+// DeepLinkingOption is one selectable item on the deep linking page, with its
+// response already signed.
+type DeepLinkingOption struct {
+	Title string
+	JWT   string
+}
+
+var deepLinkingPage = template.Must(template.New("deep_linking").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Select a test</title></head>
+<body>
+<h1>Select a test</h1>
+{{if not .Options}}<p>There are no tests yet.</p>{{end}}
+<ul>
+{{range .Options}}<li>
+<form method="post" action="{{$.ReturnURL}}">
+<input type="hidden" name="JWT" value="{{.JWT}}">
+<button type="submit">{{.Title}}</button>
+</form>
+</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// WriteDeepLinkingPage renders the page an instructor uses to pick the item
+// to link from the platform.
+func WriteDeepLinkingPage(w io.Writer, returnURL string, options []DeepLinkingOption) error {
+	return deepLinkingPage.Execute(w, struct {
+		ReturnURL string
+		Options   []DeepLinkingOption
+	}{returnURL, options})
+}
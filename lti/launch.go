@@ -0,0 +1,315 @@
+package lti
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+const (
+	MessageTypeResourceLink        = "LtiResourceLinkRequest"
+	MessageTypeDeepLinking         = "LtiDeepLinkingRequest"
+	MessageTypeDeepLinkingResponse = "LtiDeepLinkingResponse"
+	Version                        = "1.3.0"
+
+	ClaimMessageType         = "https://purl.imsglobal.org/spec/lti/claim/message_type"
+	ClaimVersion             = "https://purl.imsglobal.org/spec/lti/claim/version"
+	ClaimDeploymentId        = "https://purl.imsglobal.org/spec/lti/claim/deployment_id"
+	ClaimTargetLinkURI       = "https://purl.imsglobal.org/spec/lti/claim/target_link_uri"
+	ClaimResourceLink        = "https://purl.imsglobal.org/spec/lti/claim/resource_link"
+	ClaimRoles               = "https://purl.imsglobal.org/spec/lti/claim/roles"
+	ClaimContext             = "https://purl.imsglobal.org/spec/lti/claim/context"
+	ClaimCustom              = "https://purl.imsglobal.org/spec/lti/claim/custom"
+	ClaimAGSEndpoint         = "https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"
+	ClaimDeepLinkingSettings = "https://purl.imsglobal.org/spec/lti-dl/claim/deep_linking_settings"
+	ClaimDeepLinkingItems    = "https://purl.imsglobal.org/spec/lti-dl/claim/content_items"
+	ClaimDeepLinkingData     = "https://purl.imsglobal.org/spec/lti-dl/claim/data"
+
+	statePurpose = "lti_login"
+	stateTTL     = 10 * time.Minute
+)
+
+var ErrInvalidLaunch = errors.New("lti: invalid launch")
+
+// LoginInitiation holds the parameters of a third party login initiation
+// request sent by the platform.
+type LoginInitiation struct {
+	Issuer         string `form:"iss"`
+	LoginHint      string `form:"login_hint"`
+	TargetLinkURI  string `form:"target_link_uri"`
+	LTIMessageHint string `form:"lti_message_hint"`
+	ClientId       string `form:"client_id"`
+	DeploymentId   string `form:"lti_deployment_id"`
+}
+
+// loginState travels through the platform in the state parameter. It is
+// signed, so the launch can trust the platform and nonce it names.
+type loginState struct {
+	Issuer   string `json:"iss"`
+	ClientId string `json:"client_id"`
+	Nonce    string `json:"nonce"`
+}
+
+type ContextClaim struct {
+	Id    string `json:"id"`
+	Label string `json:"label"`
+	Title string `json:"title"`
+}
+
+type ResourceLinkClaim struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type AGSEndpointClaim struct {
+	Scope     []string `json:"scope"`
+	LineItems string   `json:"lineitems"`
+	LineItem  string   `json:"lineitem"`
+}
+
+type DeepLinkingSettings struct {
+	DeepLinkReturnURL string   `json:"deep_link_return_url"`
+	AcceptTypes       []string `json:"accept_types"`
+	AcceptMultiple    bool     `json:"accept_multiple"`
+	Data              string   `json:"data"`
+	Title             string   `json:"title"`
+}
+
+type launchClaims struct {
+	Subject             string               `json:"sub"`
+	Email               string               `json:"email"`
+	GivenName           string               `json:"given_name"`
+	FamilyName          string               `json:"family_name"`
+	Name                string               `json:"name"`
+	Nonce               string               `json:"nonce"`
+	MessageType         string               `json:"https://purl.imsglobal.org/spec/lti/claim/message_type"`
+	Version             string               `json:"https://purl.imsglobal.org/spec/lti/claim/version"`
+	DeploymentId        string               `json:"https://purl.imsglobal.org/spec/lti/claim/deployment_id"`
+	TargetLinkURI       string               `json:"https://purl.imsglobal.org/spec/lti/claim/target_link_uri"`
+	ResourceLink        ResourceLinkClaim    `json:"https://purl.imsglobal.org/spec/lti/claim/resource_link"`
+	Roles               []string             `json:"https://purl.imsglobal.org/spec/lti/claim/roles"`
+	Context             ContextClaim         `json:"https://purl.imsglobal.org/spec/lti/claim/context"`
+	Custom              map[string]any       `json:"https://purl.imsglobal.org/spec/lti/claim/custom"`
+	AGSEndpoint         *AGSEndpointClaim    `json:"https://purl.imsglobal.org/spec/lti-ags/claim/endpoint"`
+	DeepLinkingSettings *DeepLinkingSettings `json:"https://purl.imsglobal.org/spec/lti-dl/claim/deep_linking_settings"`
+}
+
+// Launch is a validated launch message.
+type Launch struct {
+	Platform      schemas.LTIPlatformConfig
+	MessageType   string
+	DeploymentId  string
+	Subject       string
+	Email         string
+	GivenName     string
+	FamilyName    string
+	Name          string
+	Roles         []string
+	Context       ContextClaim
+	ResourceLink  ResourceLinkClaim
+	Custom        map[string]string
+	TargetLinkURI string
+	AGSEndpoint   *AGSEndpointClaim
+	DeepLinking   *DeepLinkingSettings
+	Nonce         string
+	ExpiresAt     time.Time
+}
+
+// LoginRedirectURL answers a login initiation with the platform's
+// authorization URL. The nonce and platform are carried in the signed state,
+// which is returned as well so the caller can bind it to the browser.
+func (t *Tool) LoginRedirectURL(secret []byte, initiation LoginInitiation) (string, string, error) {
+	platform, err := t.Platform(initiation.Issuer, initiation.ClientId)
+	if err != nil {
+		return "", "", err
+	}
+	if initiation.LoginHint == "" {
+		return "", "", fmt.Errorf("%w: login_hint is required", ErrInvalidLaunch)
+	}
+
+	nonce, err := utils.RandomString(24)
+	if err != nil {
+		return "", "", err
+	}
+	state, err := utils.SignData(secret, statePurpose, loginState{
+		Issuer:   platform.Issuer,
+		ClientId: platform.ClientID,
+		Nonce:    nonce,
+	}, stateTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	redirectURI := t.LaunchURL
+	if initiation.TargetLinkURI != "" && sameOrigin(initiation.TargetLinkURI, t.LaunchURL) {
+		redirectURI = initiation.TargetLinkURI
+	}
+	values := url.Values{
+		"scope":         {"openid"},
+		"response_type": {"id_token"},
+		"response_mode": {"form_post"},
+		"prompt":        {"none"},
+		"client_id":     {platform.ClientID},
+		"redirect_uri":  {redirectURI},
+		"login_hint":    {initiation.LoginHint},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	if initiation.LTIMessageHint != "" {
+		values.Set("lti_message_hint", initiation.LTIMessageHint)
+	}
+
+	separator := "?"
+	if strings.Contains(platform.AuthLoginURL, "?") {
+		separator = "&"
+	}
+	return platform.AuthLoginURL + separator + values.Encode(), state, nil
+}
+
+func sameOrigin(a string, b string) bool {
+	urlA, errA := url.Parse(a)
+	urlB, errB := url.Parse(b)
+	return errA == nil && errB == nil && urlA.Scheme == urlB.Scheme && urlA.Host == urlB.Host
+}
+
+// ValidateLaunch verifies the id_token posted by the platform against the
+// state created at login initiation. The caller must still make sure the
+// state came from the browser that started the login and that the nonce has
+// not been used before.
+func (t *Tool) ValidateLaunch(ctx context.Context, secret []byte, idToken string, state string) (Launch, error) {
+	var launch Launch
+
+	var stateData loginState
+	if err := utils.VerifyData(secret, statePurpose, state, &stateData); err != nil {
+		return launch, fmt.Errorf("%w: bad state", ErrInvalidLaunch)
+	}
+	platform, err := t.Platform(stateData.Issuer, stateData.ClientId)
+	if err != nil {
+		return launch, err
+	}
+
+	mapClaims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	_, err = parser.ParseWithClaims(idToken, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return t.keySet(platform).Key(ctx, kid)
+	})
+	if err != nil {
+		return launch, fmt.Errorf("%w: %v", ErrInvalidLaunch, err)
+	}
+
+	if !mapClaims.VerifyIssuer(platform.Issuer, true) {
+		return launch, fmt.Errorf("%w: issuer mismatch", ErrInvalidLaunch)
+	}
+	if !mapClaims.VerifyAudience(platform.ClientID, true) {
+		return launch, fmt.Errorf("%w: audience mismatch", ErrInvalidLaunch)
+	}
+	if audiences, ok := mapClaims["aud"].([]interface{}); ok && len(audiences) > 1 {
+		if azp, _ := mapClaims["azp"].(string); azp != platform.ClientID {
+			return launch, fmt.Errorf("%w: authorized party mismatch", ErrInvalidLaunch)
+		}
+	}
+	if !mapClaims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return launch, fmt.Errorf("%w: token expired", ErrInvalidLaunch)
+	}
+
+	claimBytes, err := json.Marshal(mapClaims)
+	if err != nil {
+		return launch, err
+	}
+	var claims launchClaims
+	if err := json.Unmarshal(claimBytes, &claims); err != nil {
+		return launch, fmt.Errorf("%w: malformed claims", ErrInvalidLaunch)
+	}
+
+	if claims.Nonce == "" || claims.Nonce != stateData.Nonce {
+		return launch, fmt.Errorf("%w: nonce mismatch", ErrInvalidLaunch)
+	}
+	if claims.Version != Version {
+		return launch, fmt.Errorf("%w: unsupported version %q", ErrInvalidLaunch, claims.Version)
+	}
+	if !deploymentAllowed(platform, claims.DeploymentId) {
+		return launch, fmt.Errorf("%w: unknown deployment", ErrInvalidLaunch)
+	}
+	if claims.Subject == "" {
+		return launch, fmt.Errorf("%w: missing subject", ErrInvalidLaunch)
+	}
+	switch claims.MessageType {
+	case MessageTypeResourceLink:
+		if claims.ResourceLink.Id == "" {
+			return launch, fmt.Errorf("%w: missing resource link", ErrInvalidLaunch)
+		}
+	case MessageTypeDeepLinking:
+		if claims.DeepLinkingSettings == nil || claims.DeepLinkingSettings.DeepLinkReturnURL == "" {
+			return launch, fmt.Errorf("%w: missing deep linking settings", ErrInvalidLaunch)
+		}
+	default:
+		return launch, fmt.Errorf("%w: unsupported message type %q", ErrInvalidLaunch, claims.MessageType)
+	}
+
+	custom := make(map[string]string, len(claims.Custom))
+	for key, value := range claims.Custom {
+		custom[key] = fmt.Sprint(value)
+	}
+	expiresAt := time.Now().Add(stateTTL)
+	if exp, ok := mapClaims["exp"].(float64); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+
+	return Launch{
+		Platform:      platform,
+		MessageType:   claims.MessageType,
+		DeploymentId:  claims.DeploymentId,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Name:          claims.Name,
+		Roles:         claims.Roles,
+		Context:       claims.Context,
+		ResourceLink:  claims.ResourceLink,
+		Custom:        custom,
+		TargetLinkURI: claims.TargetLinkURI,
+		AGSEndpoint:   claims.AGSEndpoint,
+		DeepLinking:   claims.DeepLinkingSettings,
+		Nonce:         claims.Nonce,
+		ExpiresAt:     expiresAt,
+	}, nil
+}
+
+// hasRole matches full LIS role URIs as well as the short names older
+// platforms send, e.g. "Instructor".
+func (l Launch) hasRole(names ...string) bool {
+	for _, role := range l.Roles {
+		name := role
+		if index := strings.LastIndex(role, "#"); index >= 0 {
+			name = role[index+1:]
+		}
+		for _, wanted := range names {
+			if name == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l Launch) IsInstructor() bool {
+	return l.hasRole("Instructor", "Administrator", "ContentDeveloper")
+}
+
+func (l Launch) IsTeachingAssistant() bool {
+	return l.hasRole("TeachingAssistant")
+}
+
+func (l Launch) IsLearner() bool {
+	return l.hasRole("Learner")
+}
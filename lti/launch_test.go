@@ -0,0 +1,165 @@
+package lti_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/open-lms-test-functionality/lti"
+	"github.com/open-lms-test-functionality/lti/ltitest"
+	"github.com/open-lms-test-functionality/schemas"
+)
+
+var testSecret = []byte("lti-test-secret")
+
+const testLearnerRole = "http://purl.imsglobal.org/vocab/lis/v2/membership#Learner"
+
+func newTestTool(t *testing.T) (*lti.Tool, *ltitest.Platform) {
+	t.Helper()
+	platform := ltitest.NewPlatform("lms", "deployment-1")
+	t.Cleanup(platform.Close)
+	platform.SetLaunchClaims(map[string]any{
+		"sub":                 "learner-1",
+		lti.ClaimRoles:        []string{testLearnerRole},
+		lti.ClaimResourceLink: map[string]any{"id": "link-1"},
+	})
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lti.NewTool("https://tool.example.com/lti/launch", "tool-key", key, []schemas.LTIPlatformConfig{platform.Config()}), platform
+}
+
+// startLaunch runs the login initiation and the platform's authorization
+// endpoint, and returns what the launch form posts back.
+func startLaunch(t *testing.T, tool *lti.Tool, platform *ltitest.Platform) (string, string) {
+	t.Helper()
+	authURL, state, err := tool.LoginRedirectURL(testSecret, lti.LoginInitiation{
+		Issuer:    platform.Issuer(),
+		ClientId:  platform.ClientID,
+		LoginHint: "learner-1",
+	})
+	if err != nil {
+		t.Fatalf("login initiation: %v", err)
+	}
+	idToken, postedState, err := platform.Launch(authURL)
+	if err != nil {
+		t.Fatalf("platform launch: %v", err)
+	}
+	if postedState != state {
+		t.Fatalf("platform posted state %q, want %q", postedState, state)
+	}
+	return idToken, state
+}
+
+func TestValidateLaunch(t *testing.T) {
+	tool, platform := newTestTool(t)
+	idToken, state := startLaunch(t, tool, platform)
+
+	launch, err := tool.ValidateLaunch(context.Background(), testSecret, idToken, state)
+	if err != nil {
+		t.Fatalf("ValidateLaunch: %v", err)
+	}
+	if launch.Subject != "learner-1" || launch.DeploymentId != "deployment-1" || launch.ResourceLink.Id != "link-1" {
+		t.Errorf("launch = %+v", launch)
+	}
+	if launch.Nonce == "" || launch.IsInstructor() {
+		t.Errorf("launch nonce %q, instructor %v", launch.Nonce, launch.IsInstructor())
+	}
+}
+
+func TestValidateLaunchRejectsBadSignature(t *testing.T) {
+	tool, platform := newTestTool(t)
+	idToken, state := startLaunch(t, tool, platform)
+
+	// Swap the subject but keep the platform's signature of the original.
+	parts := strings.Split(idToken, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	claims["sub"] = "admin"
+	payload, err = json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	_, err = tool.ValidateLaunch(context.Background(), testSecret, strings.Join(parts, "."), state)
+	if !errors.Is(err, lti.ErrInvalidLaunch) {
+		t.Fatalf("ValidateLaunch error = %v, want ErrInvalidLaunch", err)
+	}
+}
+
+func TestValidateLaunchRejectsTokenOfAnotherPlatform(t *testing.T) {
+	tool, platform := newTestTool(t)
+	_, state := startLaunch(t, tool, platform)
+
+	// Same client id and key id, but signed with another key.
+	impostor := ltitest.NewPlatform(platform.ClientID, platform.DeploymentID)
+	defer impostor.Close()
+	authURL, _, err := tool.LoginRedirectURL(testSecret, lti.LoginInitiation{
+		Issuer:    platform.Issuer(),
+		ClientId:  platform.ClientID,
+		LoginHint: "learner-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, _, err := impostor.Launch(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tool.ValidateLaunch(context.Background(), testSecret, idToken, state)
+	if !errors.Is(err, lti.ErrInvalidLaunch) {
+		t.Fatalf("ValidateLaunch error = %v, want ErrInvalidLaunch", err)
+	}
+}
+
+func TestValidateLaunchRejectsNonceOfAnotherLogin(t *testing.T) {
+	tool, platform := newTestTool(t)
+	idToken, _ := startLaunch(t, tool, platform)
+	_, otherState := startLaunch(t, tool, platform)
+
+	_, err := tool.ValidateLaunch(context.Background(), testSecret, idToken, otherState)
+	if !errors.Is(err, lti.ErrInvalidLaunch) || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("ValidateLaunch error = %v, want nonce mismatch", err)
+	}
+}
+
+func TestValidateLaunchRejectsUnknownDeployment(t *testing.T) {
+	tool, platform := newTestTool(t)
+	platform.SetLaunchClaims(map[string]any{
+		"sub":                 "learner-1",
+		lti.ClaimRoles:        []string{testLearnerRole},
+		lti.ClaimResourceLink: map[string]any{"id": "link-1"},
+		lti.ClaimDeploymentId: "deployment-2",
+	})
+	idToken, state := startLaunch(t, tool, platform)
+
+	_, err := tool.ValidateLaunch(context.Background(), testSecret, idToken, state)
+	if !errors.Is(err, lti.ErrInvalidLaunch) || !strings.Contains(err.Error(), "deployment") {
+		t.Fatalf("ValidateLaunch error = %v, want unknown deployment", err)
+	}
+}
+
+func TestValidateLaunchRejectsForgedState(t *testing.T) {
+	tool, platform := newTestTool(t)
+	idToken, state := startLaunch(t, tool, platform)
+
+	_, err := tool.ValidateLaunch(context.Background(), []byte("another-secret"), idToken, state)
+	if !errors.Is(err, lti.ErrInvalidLaunch) {
+		t.Fatalf("ValidateLaunch error = %v, want ErrInvalidLaunch", err)
+	}
+}
@@ -0,0 +1,299 @@
+// Package ltitest provides an in-process LTI 1.3 platform for local
+// development and automated checks of the tool. It implements the
+// authorization endpoint of the launch flow, answering with an auto-posting
+// form that carries a signed id_token, an OAuth2 token endpoint that verifies
+// the tool's client assertion, the platform JWKS, and an AGS score endpoint
+// that records published scores.
+//
+//	platform := ltitest.NewPlatform("lms", "deployment-1")
+//	defer platform.Close()
+//	platform.SetToolJWKSURL("http://localhost:8080/lti/jwks")
+//	platform.SetLaunchClaims(map[string]any{"sub": "42", lti.ClaimRoles: []string{"http://purl.imsglobal.org/vocab/lis/v2/membership#Learner"}})
+//	// add platform.Config() to the lti platforms and start at POST /lti/login
+package ltitest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/open-lms-test-functionality/lti"
+	"github.com/open-lms-test-functionality/oidc"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/utils"
+)
+
+const keyId = "ltitest-key"
+
+type Platform struct {
+	*httptest.Server
+	ClientID     string
+	DeploymentID string
+
+	key *rsa.PrivateKey
+
+	mu           sync.Mutex
+	claims       map[string]any
+	toolKeys     *oidc.KeySet
+	accessTokens map[string]bool
+	scores       map[string][]lti.Score
+}
+
+// NewPlatform starts a fake platform with a single registered tool. It panics
+// if the signing key cannot be generated, like httptest.NewServer does when it
+// cannot listen.
+func NewPlatform(clientID string, deploymentID string) *Platform {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("ltitest: generating key: " + err.Error())
+	}
+
+	p := &Platform{
+		ClientID:     clientID,
+		DeploymentID: deploymentID,
+		key:          key,
+		claims:       map[string]any{"sub": "ltitest-user"},
+		accessTokens: make(map[string]bool),
+		scores:       make(map[string][]lti.Score),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", p.auth)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/lineitems/", p.lineItemScores)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *Platform) Issuer() string {
+	return p.URL
+}
+
+// Config returns the platform registration to add to the lti settings.
+func (p *Platform) Config() schemas.LTIPlatformConfig {
+	return schemas.LTIPlatformConfig{
+		Issuer:        p.Issuer(),
+		ClientID:      p.ClientID,
+		DeploymentIDs: []string{p.DeploymentID},
+		AuthLoginURL:  p.URL + "/auth",
+		AuthTokenURL:  p.URL + "/token",
+		JWKSURL:       p.URL + "/jwks",
+	}
+}
+
+// SetToolJWKSURL sets where the platform fetches the tool's keys to verify
+// client assertions. Token requests are rejected until it is set.
+func (p *Platform) SetToolJWKSURL(jwksURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.toolKeys = oidc.NewKeySet(jwksURL, nil)
+}
+
+// SetLaunchClaims sets the claims of the next launches. "sub" is required.
+// iss, aud, iat, exp and nonce are filled in by the platform; the message type,
+// version and deployment id default to a resource link launch of the
+// platform's deployment.
+func (p *Platform) SetLaunchClaims(claims map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.claims = claims
+}
+
+// LineItemURL is the AGS line item URL for the given id, for use in the
+// endpoint claim of a launch.
+func (p *Platform) LineItemURL(id string) string {
+	return p.URL + "/lineitems/" + id
+}
+
+// Scores returns the scores published to a line item so far.
+func (p *Platform) Scores(id string) []lti.Score {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]lti.Score(nil), p.scores[id]...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+var launchForm = template.Must(template.New("launch").Parse(`<!DOCTYPE html>
+<html>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.RedirectURI}}">
+<input type="hidden" name="id_token" value="{{.IDToken}}">
+<input type="hidden" name="state" value="{{.State}}">
+<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+func (p *Platform) auth(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	signedIDToken, err := p.signLaunch(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	launchForm.Execute(w, map[string]string{
+		"RedirectURI": r.Form.Get("redirect_uri"),
+		"IDToken":     signedIDToken,
+		"State":       r.Form.Get("state"),
+	})
+}
+
+// Launch answers the authorization URL a tool redirected to, like the
+// authorization endpoint does, and returns the id_token and state the launch
+// form would post back to the tool.
+func (p *Platform) Launch(authURL string) (string, string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	params := u.Query()
+	signedIDToken, err := p.signLaunch(params)
+	if err != nil {
+		return "", "", err
+	}
+	return signedIDToken, params.Get("state"), nil
+}
+
+// signLaunch checks an authentication request and signs the id_token that
+// answers it. The deployment id and version of the launch claims win over the
+// platform's, so tools can be tested against misbehaving platforms.
+func (p *Platform) signLaunch(params url.Values) (string, error) {
+	if params.Get("client_id") != p.ClientID || params.Get("response_type") != "id_token" ||
+		params.Get("response_mode") != "form_post" || params.Get("scope") != "openid" {
+		return "", errors.New("invalid authentication request")
+	}
+	if params.Get("nonce") == "" || params.Get("login_hint") == "" || params.Get("redirect_uri") == "" {
+		return "", errors.New("nonce, login_hint and redirect_uri are required")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		lti.ClaimMessageType:  lti.MessageTypeResourceLink,
+		lti.ClaimVersion:      lti.Version,
+		lti.ClaimDeploymentId: p.DeploymentID,
+	}
+	p.mu.Lock()
+	for key, value := range p.claims {
+		claims[key] = value
+	}
+	p.mu.Unlock()
+	claims["iss"] = p.Issuer()
+	claims["aud"] = p.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(5 * time.Minute).Unix()
+	claims["nonce"] = params.Get("nonce")
+	claims[lti.ClaimTargetLinkURI] = params.Get("redirect_uri")
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = keyId
+	return idToken.SignedString(p.key)
+}
+
+func (p *Platform) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.PostFormValue("grant_type") != "client_credentials" ||
+		r.PostFormValue("client_assertion_type") != "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	p.mu.Lock()
+	toolKeys := p.toolKeys
+	p.mu.Unlock()
+	if toolKeys == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "tool jwks url is not set"})
+		return
+	}
+
+	assertion := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	_, err := parser.ParseWithClaims(r.PostFormValue("client_assertion"), assertion, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return toolKeys.Key(context.Background(), kid)
+	})
+	if err != nil || assertion["iss"] != p.ClientID || assertion["sub"] != p.ClientID ||
+		!assertion.VerifyAudience(p.URL+"/token", true) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	accessToken, err := utils.RandomString(24)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	p.mu.Lock()
+	p.accessTokens[accessToken] = true
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"scope":        r.PostFormValue("scope"),
+	})
+}
+
+func (p *Platform) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{oidc.RSAPublicJWK(keyId, &p.key.PublicKey)},
+	})
+}
+
+// lineItemScores accepts POST /lineitems/{id}/scores.
+func (p *Platform) lineItemScores(w http.ResponseWriter, r *http.Request) {
+	id, found := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/lineitems/"), "/scores")
+	if !found || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p.mu.Lock()
+	authorized := p.accessTokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	p.mu.Unlock()
+	if !authorized {
+		http.Error(w, "invalid access token", http.StatusUnauthorized)
+		return
+	}
+
+	var score lti.Score
+	if err := json.NewDecoder(r.Body).Decode(&score); err != nil || score.UserId == "" {
+		http.Error(w, fmt.Sprintf("invalid score: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	p.scores[id] = append(p.scores[id], score)
+	p.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
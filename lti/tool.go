@@ -0,0 +1,154 @@
+// Package lti implements the tool side of LTI 1.3: the OIDC third party
+// login initiation, launch validation, deep linking responses and score
+// passback through the Assignment and Grade Services.
+package lti
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/oidc"
+	"github.com/open-lms-test-functionality/schemas"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrDisabled        = errors.New("lti is not enabled")
+	ErrUnknownPlatform = errors.New("lti: unknown platform")
+)
+
+// Tool is this application registered as an LTI tool with one or more
+// platforms. Platform keys and AGS access tokens are cached per platform.
+type Tool struct {
+	LaunchURL  string
+	KeyId      string
+	PrivateKey *rsa.PrivateKey
+	Platforms  []schemas.LTIPlatformConfig
+
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	keySets      map[string]*oidc.KeySet
+	accessTokens map[string]accessToken
+}
+
+func NewTool(launchURL string, keyId string, privateKey *rsa.PrivateKey, platforms []schemas.LTIPlatformConfig) *Tool {
+	return &Tool{
+		LaunchURL:    launchURL,
+		KeyId:        keyId,
+		PrivateKey:   privateKey,
+		Platforms:    platforms,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		keySets:      make(map[string]*oidc.KeySet),
+		accessTokens: make(map[string]accessToken),
+	}
+}
+
+var DefaultTool *Tool
+
+// ToolInit configures DefaultTool from the lti settings. It does nothing when
+// LTI is disabled.
+func ToolInit() error {
+	ltiConfig := core.Config.LTI
+	if !ltiConfig.Enabled {
+		return nil
+	}
+
+	privateKey, err := loadPrivateKey(ltiConfig.PrivateKeyPath)
+	if err != nil {
+		return err
+	}
+	DefaultTool = NewTool(ltiConfig.LaunchURL, ltiConfig.KeyId, privateKey, ltiConfig.Platforms)
+	logger.Logger.Info("LTI :: Tool configured Successfully", zap.Int("platforms", len(ltiConfig.Platforms)))
+	return nil
+}
+
+// GetDefaultTool returns DefaultTool, or ErrDisabled when LTI is not enabled.
+func GetDefaultTool() (*Tool, error) {
+	if DefaultTool == nil {
+		return nil, ErrDisabled
+	}
+	return DefaultTool, nil
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		logger.Logger.Warn("LTI :: No private key configured, generating a temporary one")
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("lti: no PEM data in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("lti: parsing private key: %w", err)
+	}
+	key, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("lti: private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Platform looks up a registered platform. The client id may be empty when
+// the platform has a single registration.
+func (t *Tool) Platform(issuer string, clientId string) (schemas.LTIPlatformConfig, error) {
+	for _, platform := range t.Platforms {
+		if platform.Issuer == issuer && (clientId == "" || platform.ClientID == clientId) {
+			return platform, nil
+		}
+	}
+	return schemas.LTIPlatformConfig{}, ErrUnknownPlatform
+}
+
+func (t *Tool) keySet(platform schemas.LTIPlatformConfig) *oidc.KeySet {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cacheKey := platform.Issuer + "|" + platform.ClientID
+	keySet, ok := t.keySets[cacheKey]
+	if !ok {
+		keySet = oidc.NewKeySet(platform.JWKSURL, t.httpClient)
+		t.keySets[cacheKey] = keySet
+	}
+	return keySet
+}
+
+// JWKS is the tool's public key set, served for platforms to verify deep
+// linking responses and client assertions.
+func (t *Tool) JWKS() map[string]any {
+	return map[string]any{
+		"keys": []map[string]string{oidc.RSAPublicJWK(t.KeyId, &t.PrivateKey.PublicKey)},
+	}
+}
+
+func deploymentAllowed(platform schemas.LTIPlatformConfig, deploymentId string) bool {
+	if len(platform.DeploymentIDs) == 0 {
+		return deploymentId != ""
+	}
+	for _, allowed := range platform.DeploymentIDs {
+		if allowed == deploymentId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/open-lms-test-functionality/lti"
+	"github.com/open-lms-test-functionality/lti/ltitest"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+)
+
+const ltiLearnerRole = "http://purl.imsglobal.org/vocab/lis/v2/membership#Learner"
+
+// ltiPlatform registers a fake platform with the default tool and returns it.
+func (s *testServer) ltiPlatform() *ltitest.Platform {
+	s.t.Helper()
+	platform := ltitest.NewPlatform("lms", "deployment-1")
+	s.t.Cleanup(platform.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	previousTool := lti.DefaultTool
+	s.t.Cleanup(func() {
+		lti.DefaultTool = previousTool
+	})
+	lti.DefaultTool = lti.NewTool("http://lms.test/lti/launch", "tool-key", key, []schemas.LTIPlatformConfig{platform.Config()})
+	return platform
+}
+
+// ltiLogin starts a launch at the login initiation endpoint and returns what
+// the platform posts back, together with the state cookie of the browser.
+func (s *testServer) ltiLogin(platform *ltitest.Platform) (url.Values, *http.Cookie) {
+	s.t.Helper()
+	query := url.Values{"iss": {platform.Issuer()}, "client_id": {platform.ClientID}, "login_hint": {"learner"}}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/lti/login?"+query.Encode(), nil))
+	if recorder.Code != http.StatusFound {
+		s.t.Fatalf("GET /lti/login: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+
+	var stateCookie *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "lti_login" {
+			stateCookie = cookie
+		}
+	}
+	if stateCookie == nil || !stateCookie.HttpOnly {
+		s.t.Fatalf("GET /lti/login: no http only state cookie in %v", recorder.Result().Cookies())
+	}
+
+	idToken, state, err := platform.Launch(recorder.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatalf("platform launch: %v", err)
+	}
+	return url.Values{"id_token": {idToken}, "state": {state}}, stateCookie
+}
+
+// ltiLaunch posts a launch form, with the state cookie when it is not nil.
+func (s *testServer) ltiLaunch(form url.Values, stateCookie *http.Cookie) *httptest.ResponseRecorder {
+	s.t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/lti/launch", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if stateCookie != nil {
+		request.AddCookie(stateCookie)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// ltiTest creates a test and makes the platform launch into it.
+func (s *testServer) ltiTest(platform *ltitest.Platform, email string) int64 {
+	s.t.Helper()
+	testId, err := s.stores.Tests.CreateTest(context.Background(), "", models.TestCreateSchema{Title: "LTI test"})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	platform.SetLaunchClaims(map[string]any{
+		"sub":                 "learner-1",
+		"email":               email,
+		lti.ClaimRoles:        []string{ltiLearnerRole},
+		lti.ClaimResourceLink: map[string]any{"id": "link-1"},
+		lti.ClaimCustom:       map[string]any{"test_id": strconv.FormatInt(testId, 10)},
+	})
+	return testId
+}
+
+func TestLTILaunchSignsIn(t *testing.T) {
+	s := newTestServer(t)
+	platform := s.ltiPlatform()
+	testId := s.ltiTest(platform, "learner@example.com")
+
+	form, stateCookie := s.ltiLogin(platform)
+	recorder := s.ltiLaunch(form, stateCookie)
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("POST /lti/launch: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fragment.Get("test_id") != strconv.FormatInt(testId, 10) || fragment.Get("token") == "" {
+		t.Fatalf("POST /lti/launch: redirected to %s", location)
+	}
+
+	code, response := s.do(http.MethodGet, "/auth/me", fragment.Get("token"), nil)
+	if code != http.StatusOK || response["message"].(map[string]any)["email"] != "learner@example.com" {
+		t.Fatalf("GET /auth/me: status %d, %v", code, response)
+	}
+}
+
+func TestLTILaunchRejectsReplay(t *testing.T) {
+	s := newTestServer(t)
+	platform := s.ltiPlatform()
+	s.ltiTest(platform, "learner@example.com")
+
+	form, stateCookie := s.ltiLogin(platform)
+	if recorder := s.ltiLaunch(form, stateCookie); recorder.Code != http.StatusFound {
+		t.Fatalf("first launch: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := s.ltiLaunch(form, stateCookie); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed launch: status %d, want 401", recorder.Code)
+	}
+}
+
+func TestLTILaunchIsBoundToLoginBrowser(t *testing.T) {
+	s := newTestServer(t)
+	platform := s.ltiPlatform()
+	s.ltiTest(platform, "learner@example.com")
+
+	form, _ := s.ltiLogin(platform)
+	if recorder := s.ltiLaunch(form, nil); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("launch without state cookie: status %d, want 400", recorder.Code)
+	}
+
+	// A launch started in the attacker's browser and delivered to the victim's.
+	_, victimCookie := s.ltiLogin(platform)
+	if recorder := s.ltiLaunch(form, victimCookie); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("launch with another browser's state: status %d, want 401", recorder.Code)
+	}
+}
+
+func TestLTILaunchDoesNotLinkExistingAccount(t *testing.T) {
+	s := newTestServer(t)
+	adminId := s.register("admin@example.com", "secret-password", "student")
+	if _, err := s.stores.Users.UpdateUserType(context.Background(), "", adminId, models.ADMIN); err != nil {
+		t.Fatal(err)
+	}
+	platform := s.ltiPlatform()
+	s.ltiTest(platform, "admin@example.com")
+
+	form, stateCookie := s.ltiLogin(platform)
+	recorder := s.ltiLaunch(form, stateCookie)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("launch with an existing email: status %d, want 409, %s", recorder.Code, recorder.Body.String())
+	}
+	if strings.Contains(recorder.Header().Get("Location"), "token=") {
+		t.Fatalf("launch with an existing email signed in: %s", recorder.Header().Get("Location"))
+	}
+}
+
+func TestLTILaunchAsksForRoleSecondFactor(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.stores.MFA.UpdateRoleMFARequired(context.Background(), "", models.STUDENT, true); err != nil {
+		t.Fatal(err)
+	}
+	platform := s.ltiPlatform()
+	testId := s.ltiTest(platform, "learner@example.com")
+
+	form, stateCookie := s.ltiLogin(platform)
+	recorder := s.ltiLaunch(form, stateCookie)
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("POST /lti/launch: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fragment.Get("mfa_required") != "true" || fragment.Get("mfa_token") == "" || fragment.Has("token") || fragment.Get("test_id") != strconv.FormatInt(testId, 10) {
+		t.Fatalf("POST /lti/launch: redirected to %s, want an mfa challenge", location)
+	}
+
+	code, response := s.do(http.MethodPost, "/token/mfa/enroll", "", map[string]string{"mfa_token": fragment.Get("mfa_token")})
+	if code != http.StatusOK {
+		t.Fatalf("POST /token/mfa/enroll: status %d, %v", code, response)
+	}
+}
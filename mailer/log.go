@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+// LogMailer is meant for local development: it logs every message and, when a
+// directory is configured, also writes it there as a .eml file so links in the
+// message can be opened.
+type LogMailer struct {
+	Directory string
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, message)
+	m.mu.Unlock()
+
+	logger.Logger.Info("MAILER :: Email",
+		zap.Strings("to", message.To),
+		zap.String("subject", message.Subject),
+		zap.String("body", message.Body),
+	)
+	if m.Directory == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Directory, 0755); err != nil {
+		return err
+	}
+	fileName := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), utils.GetUUID())
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", strings.Join(message.To, ", "), message.Subject, message.Body)
+	return os.WriteFile(filepath.Join(m.Directory, fileName), []byte(content), 0644)
+}
+
+// Sent returns a copy of the messages sent so far.
+func (m *LogMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := make([]Message, len(m.sent))
+	copy(sent, m.sent)
+	return sent
+}
@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outbound email.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+var DefaultMailer Mailer
+
+// NewMailer builds the mailer selected by the mail driver setting.
+func NewMailer() (Mailer, error) {
+	mailConfig := core.Config.Mail
+	switch mailConfig.Driver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     mailConfig.SMTPHost,
+			Port:     mailConfig.SMTPPort,
+			Username: mailConfig.SMTPUsername,
+			Password: mailConfig.SMTPPassword,
+			From:     mailConfig.From,
+		}, nil
+	case "log", "":
+		return &LogMailer{Directory: mailConfig.LogDirectory}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", mailConfig.Driver)
+	}
+}
+
+func MailerInit() error {
+	mailer, err := NewMailer()
+	if err != nil {
+		return err
+	}
+	DefaultMailer = mailer
+	logger.Logger.Info("Mailer configured Successfully", zap.String("driver", core.Config.Mail.Driver))
+	return nil
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	// JWT
@@ -16,10 +22,14 @@ import (
 
 	// Internal packages
 	"github.com/open-lms-test-functionality/api"
+	"github.com/open-lms-test-functionality/cache"
 	"github.com/open-lms-test-functionality/core"
 	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/lti"
+	"github.com/open-lms-test-functionality/mailer"
 	"github.com/open-lms-test-functionality/middleware"
 	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
 )
 
 func Hello(c *gin.Context) {
@@ -27,18 +37,117 @@ func Hello(c *gin.Context) {
 }
 
 func main() {
-	core.ReadEnvFile()        // Configure ENV File
-	logger.LoggerInit()       // Configure Logger
-	models.RunMigrations()    // Run migrations to sync db schema related changes
-	models.CreateConnection() // Create DB connection pool
+	os.Exit(run(os.Args[1:]))
+}
+
+// serve starts the HTTP server. With -migrate pending migrations are applied
+// first; without it the server refuses to start on a dirty or outdated schema.
+// It exits non-zero when the database cannot be reached. On SIGINT or SIGTERM
+// it stops accepting connections and gives in-flight requests the shutdown
+// grace period to finish before closing the database pools and the cache.
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	applyMigrations := flags.Bool("migrate", false, "apply pending migrations before starting")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	core.ReadEnvFile()  // Configure ENV File
+	logger.LoggerInit() // Configure Logger
+	defer logger.Logger.Sync()
+	// Connecting first waits for a database that is still starting; the
+	// migration steps below open their own connection and do not retry.
+	if err := models.CreateConnection(); err != nil { // Create DB connection pool
+		logger.Logger.Error("MAIN :: Error while connecting to the database", zap.Error(err))
+		return 1
+	}
+	defer models.CloseConnections()
+	if *applyMigrations {
+		if err := models.MigrateUp(); err != nil {
+			logger.Logger.Error("MAIN :: Error while applying migrations", zap.Error(err))
+			return 1
+		}
+	}
+	if err := models.CheckMigrations(); err != nil {
+		logger.Logger.Error("MAIN :: Refusing to start", zap.Error(err))
+		return 1
+	}
+
+	if err := mailer.MailerInit(); err != nil {
+		logger.Logger.Error("MAIN :: Error while configuring mailer", zap.Error(err))
+		return 1
+	}
+
+	if err := cache.CacheInit(); err != nil {
+		logger.Logger.Error("MAIN :: Error while configuring cache", zap.Error(err))
+		return 1
+	}
+	if closer, ok := cache.Default.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if cache.Default != nil {
+		store.Default = store.NewCached(store.Default, cache.Default, time.Duration(core.Config.Cache.TTLSeconds)*time.Second)
+	}
+
+	if err := lti.ToolInit(); err != nil {
+		logger.Logger.Error("MAIN :: Error while configuring lti tool", zap.Error(err))
+		return 1
+	}
 
 	authMiddleware, err := middleware.GetAuthMiddleware()
 	if err != nil {
 		logger.Logger.Error("MAIN :: Error while configuring auth middleware", zap.Error(err))
-		os.Exit(1)
+		return 1
+	}
+
+	r, err := newRouter(authMiddleware)
+	if err != nil {
+		logger.Logger.Error("MAIN :: Error while configuring trusted proxies", zap.Error(err))
+		return 1
+	}
+
+	// Starting server
+	server := &http.Server{Addr: ":8000", Handler: r}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serverErr:
+		logger.Logger.Error("Failed to start the server:", zap.Error(err))
+		return 1
+	case <-ctx.Done():
+		stop() // A second signal kills the process right away
+	}
+
+	grace := time.Duration(core.Config.ShutdownGraceSeconds) * time.Second
+	logger.Logger.Info("MAIN :: Shutting down, draining in-flight requests", zap.Duration("grace", grace))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Logger.Error("MAIN :: Requests still running after the grace period", zap.Error(err))
+		server.Close()
+		return 1
 	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		logger.Logger.Error("MAIN :: Server stopped with an error", zap.Error(err))
+		return 1
+	}
+	logger.Logger.Info("MAIN :: Server stopped")
+	return 0
+}
 
+// newRouter registers every route of the service on a new engine.
+func newRouter(authMiddleware *jwt.GinJWTMiddleware) (*gin.Engine, error) {
 	r := gin.New()
+	// ClientIP only reads X-Forwarded-For when the request comes from one of
+	// these proxies; with none configured it is the address of the peer.
+	if err := r.SetTrustedProxies(core.Config.TrustedProxies); err != nil {
+		return nil, err
+	}
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 	r.Use(middleware.Timeout(60*time.Second, middleware.NewServiceUnavailable()))
@@ -49,6 +158,10 @@ func main() {
 	})
 
 	r.POST("/user", api.CreateUser) // Open endpoint
+	r.POST("/user/verify_email", api.VerifyEmail)
+	r.POST("/user/resend_verification", api.ResendVerificationEmail)
+	r.POST("/user/password_reset", api.RequestPasswordReset)
+	r.POST("/user/password_reset/confirm", api.ConfirmPasswordReset)
 
 	r.NoRoute(authMiddleware.MiddlewareFunc(), func(c *gin.Context) {
 		claims := jwt.ExtractClaims(c)
@@ -56,45 +169,95 @@ func main() {
 		c.JSON(404, gin.H{"code": "PAGE_NOT_FOUND", "message": "Page not found"})
 	})
 
-	r.POST("/token", authMiddleware.LoginHandler)
-	r.GET("/logout", authMiddleware.LogoutHandler)
-	r.GET("/refresh_token", authMiddleware.RefreshHandler)
+	r.POST("/token", middleware.LoginHandler(authMiddleware))
+	r.POST("/token/mfa", api.MFALogin(authMiddleware))
+	r.POST("/token/mfa/enroll", api.MFALoginEnroll)
+	r.GET("/logout", authMiddleware.MiddlewareFunc(), api.RevokeCurrentSession, authMiddleware.LogoutHandler)
+	r.POST("/refresh_token", middleware.RefreshHandler(authMiddleware))
+	r.GET("/oidc/login", api.OIDCLogin)
+	r.GET("/oidc/callback", api.OIDCCallback(authMiddleware))
+	r.GET("/lti/jwks", api.LTIJWKS)
+	r.GET("/lti/login", api.LTILogin)
+	r.POST("/lti/login", api.LTILogin)
+	r.POST("/lti/launch", api.LTILaunch(authMiddleware))
 
 	// Auth Group
 	auth := r.Group("/auth")
-	auth.Use(authMiddleware.MiddlewareFunc())
+	auth.Use(middleware.Authenticate(authMiddleware))
 
 	// User APIs
-	auth.PUT("/user/:userId", api.UpdateUser)
-	auth.DELETE("/user/:userId", api.DeleteUser)
+	auth.PUT("/user/:userId", middleware.RequireOwnerOrPermission(models.PermissionUserManage), api.UpdateUser)
+	auth.DELETE("/user/:userId", middleware.RequireOwnerOrPermission(models.PermissionUserManage), api.DeleteUser)
+	auth.GET("/me", api.FetchMe)
+	auth.PUT("/me", middleware.RequireSession(), api.UpdateMe)
+	auth.PUT("/me/password", middleware.RequireSession(), api.ChangeMyPassword)
+	auth.GET("/me/progress", api.FetchMyProgress)
+	auth.GET("/me/sessions", middleware.RequireSession(), api.FetchMySessions)
+	auth.DELETE("/me/sessions/:sessionId", middleware.RequireSession(), api.RevokeMySession)
+	auth.GET("/me/api_keys", middleware.RequireSession(), api.FetchMyAPIKeys)
+	auth.POST("/me/api_keys", middleware.RequireSession(), api.CreateMyAPIKey)
+	auth.DELETE("/me/api_keys/:apiKeyId", middleware.RequireSession(), api.RevokeMyAPIKey)
+	auth.GET("/me/mfa", middleware.RequireSession(), api.FetchMyMFA)
+	auth.POST("/me/mfa", middleware.RequireSession(), api.EnrollMyMFA)
+	auth.POST("/me/mfa/verify", middleware.RequireSession(), api.VerifyMyMFA)
+	auth.POST("/me/mfa/recovery_codes", middleware.RequireSession(), api.RegenerateMyRecoveryCodes)
+	auth.DELETE("/me/mfa", middleware.RequireSession(), api.DisableMyMFA)
+
+	// Admin APIs
+	admin := auth.Group("/admin")
+	admin.Use(middleware.RequirePermission(models.PermissionUserManage))
+	admin.GET("/users", api.AdminFetchUsers)
+	admin.GET("/user/:userId", api.AdminFetchUser)
+	admin.PUT("/user/:userId/role", api.AdminUpdateUserRole)
+	admin.POST("/user/:userId/deactivate", api.AdminDeactivateUser)
+	admin.POST("/user/:userId/reactivate", api.AdminReactivateUser)
+	admin.POST("/user/:userId/force_password_reset", api.AdminForcePasswordReset)
+	admin.GET("/user/:userId/sessions", api.AdminFetchUserSessions)
+	admin.DELETE("/user/:userId/sessions", api.AdminRevokeUserSessions)
+	admin.GET("/user/:userId/api_keys", api.AdminFetchUserAPIKeys)
+	admin.DELETE("/user/:userId/api_keys/:apiKeyId", api.AdminRevokeUserAPIKey)
+	admin.DELETE("/user/:userId/mfa", api.AdminResetUserMFA)
+	admin.POST("/user/:userId/unlock", api.AdminUnlockUserLogin)
+	admin.GET("/lockouts", api.AdminFetchLoginLockouts)
+	admin.GET("/roles", api.AdminFetchRoleSettings)
+	admin.PUT("/roles/:role", api.AdminUpdateRoleSettings)
 
 	// Test APIs
-	auth.GET("/tests", api.FetchTests)
-	auth.GET("/test/:testId", api.FetchTest)
-	auth.POST("/test", api.CreateTest)
-	auth.PUT("/test/:testId", api.UpdateTest)
-	auth.DELETE("/test/:testId", api.DeleteTest)
+	auth.GET("/tests", middleware.RequirePermission(models.PermissionTestRead), api.FetchTests)
+	auth.GET("/test/:testId", middleware.RequirePermission(models.PermissionTestRead), api.FetchTest)
+	auth.POST("/test", middleware.RequirePermission(models.PermissionTestWrite), api.CreateTest)
+	auth.PUT("/test/:testId", middleware.RequirePermission(models.PermissionTestWrite), api.UpdateTest)
+	auth.DELETE("/test/:testId", middleware.RequirePermission(models.PermissionTestWrite), api.DeleteTest)
 
 	// Question APIs
-	auth.GET("/questions", api.FetchQuestions)
-	auth.GET("/question/:questionId", api.FetchQuestion)
-	auth.POST("/question", api.CreateQuestion)
-	auth.PUT("/question/:questionId", api.UpdateQuestion)
-	auth.DELETE("/question/:questionId", api.DeleteQuestion)
+	auth.GET("/questions", middleware.RequirePermission(models.PermissionQuestionRead), api.FetchQuestions)
+	auth.GET("/question/:questionId", middleware.RequirePermission(models.PermissionQuestionRead), api.FetchQuestion)
+	auth.POST("/question", middleware.RequirePermission(models.PermissionQuestionWrite), api.CreateQuestion)
+	auth.PUT("/question/:questionId", middleware.RequirePermission(models.PermissionQuestionWrite), api.UpdateQuestion)
+	auth.DELETE("/question/:questionId", middleware.RequirePermission(models.PermissionQuestionWrite), api.DeleteQuestion)
+	auth.GET("/question/:questionId/stats", middleware.RequirePermission(models.PermissionQuestionRead), api.FetchQuestionStats)
 
 	// Test questionary APIs
-	auth.GET("/test/:testId/questions", api.FetchTestQuestionary)
-	auth.POST("/test/:testId/generate_questionary", api.CreateTestQuestionary)
-	auth.PUT("/test/:testId/question/:questionId/add_question", api.AddTestQuestion)
-	auth.DELETE("/test/:testId/question/:questionId", api.DeleteTestQuestion)
+	auth.GET("/test/:testId/questions", middleware.RequirePermission(models.PermissionTestRead), api.FetchTestQuestionary)
+	auth.POST("/test/:testId/generate_questionary", middleware.RequirePermission(models.PermissionTestWrite), api.CreateTestQuestionary)
+	auth.PUT("/test/:testId/question/:questionId/add_question", middleware.RequirePermission(models.PermissionTestWrite), api.AddTestQuestion)
+	auth.DELETE("/test/:testId/question/:questionId", middleware.RequirePermission(models.PermissionTestWrite), api.DeleteTestQuestion)
 
 	// Test question submission APIs
-	auth.PUT("/test/:testId/question/:questionId", api.SubmitTestQuestionSubmission)
-	auth.GET("/test/:testId/submissions", api.GetTestQuestionSubmissions)
+	auth.PUT("/test/:testId/question/:questionId", middleware.RequirePermission(models.PermissionTestTake), api.SubmitTestQuestionSubmission)
+	auth.GET("/test/:testId/submissions", middleware.RequirePermission(models.PermissionTestTake), api.GetTestQuestionSubmissions)
+	auth.GET("/test/:testId/user/:userId/submissions", middleware.RequirePermission(models.PermissionTestGrade), api.GetStudentTestQuestionSubmissions)
+	auth.GET("/test/:testId/user/:userId/question/:questionId/history", middleware.RequirePermission(models.PermissionTestGrade), api.GetSubmissionHistory)
 
-	// Starting server
-	if err := r.Run(":8000"); err != nil {
-		logger.Logger.Fatal("Failed to start the server:", zap.Error(err))
-		return
-	}
+	// Question time tracking APIs
+	auth.POST("/test/:testId/question/:questionId/events", middleware.RequirePermission(models.PermissionTestTake), api.RecordQuestionEvent)
+	auth.GET("/test/:testId/timing_report", middleware.RequirePermission(models.PermissionTestGrade), api.FetchQuestionTimingReport)
+
+	// Test integrity APIs
+	auth.POST("/test/:testId/integrity_events", middleware.RequirePermission(models.PermissionTestTake), api.RecordIntegrityEvent)
+	auth.GET("/test/:testId/integrity_report", middleware.RequirePermission(models.PermissionTestGrade), api.FetchIntegrityReport)
+
+	// LTI grade passback APIs
+	auth.POST("/test/:testId/lti/scores", middleware.RequirePermission(models.PermissionTestGrade), api.SyncLTIScores)
+	return r, nil
 }
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/mailer"
+	"github.com/open-lms-test-functionality/middleware"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/schemas"
+	"github.com/open-lms-test-functionality/store"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testMailer keeps the messages the handlers send.
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, message mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// token waits for a message to email and returns the token of its link.
+func (m *testMailer) token(t *testing.T, email string, subject string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.Lock()
+		for _, message := range m.messages {
+			if message.To[0] != email || message.Subject != subject {
+				continue
+			}
+			for _, field := range strings.Fields(message.Body) {
+				if link, err := url.Parse(field); err == nil && link.Query().Get("token") != "" {
+					m.mu.Unlock()
+					return link.Query().Get("token")
+				}
+			}
+		}
+		m.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %q email sent to %s", subject, email)
+	return ""
+}
+
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	stores store.Stores
+	mailer *testMailer
+}
+
+// newTestServer serves every route from an empty in-memory store.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	previousConfig, previousLogger, previousStores, previousMailer := core.Config, logger.Logger, store.Default, mailer.DefaultMailer
+	t.Cleanup(func() {
+		core.Config, logger.Logger, store.Default, mailer.DefaultMailer = previousConfig, previousLogger, previousStores, previousMailer
+	})
+
+	core.Config = schemas.ProjectConfiguration{
+		PasswordHashCost:          bcrypt.MinCost,
+		AuthRealm:                 "test",
+		AuthSecretKey:             "test-secret",
+		FastAnswerThresholdMs:     3000,
+		FastAnswerFlagCount:       3,
+		IntegrityMaxEvents:        10,
+		IntegrityMaxIPChanges:     1,
+		AppBaseURL:                "http://lms.test",
+		EmailVerificationTTLHours: 48,
+		PasswordResetTTLMinutes:   30,
+		AccessTokenTTLMinutes:     60,
+		RefreshTokenTTLHours:      24,
+		APIKeyMaxTTLDays:          30,
+		MFAIssuer:                 "Open LMS",
+		LoginProtection: schemas.LoginProtectionConfig{
+			AccountMaxFailures:   10,
+			AccountBackoffAfter:  3,
+			IPMaxFailures:        100,
+			IPBackoffAfter:       20,
+			BackoffBaseSeconds:   1,
+			BackoffMaxSeconds:    60,
+			LockoutMinutes:       15,
+			FailureWindowMinutes: 15,
+		},
+	}
+	logger.Logger = zap.NewNop()
+	server := &testServer{t: t, stores: store.NewMemory(), mailer: &testMailer{}}
+	store.Default = server.stores
+	mailer.DefaultMailer = server.mailer
+
+	authMiddleware, err := middleware.GetAuthMiddleware()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.router, err = newRouter(authMiddleware)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// do sends a JSON request, authenticated with token when it is not empty, and
+// decodes the JSON response.
+func (s *testServer) do(method string, path string, token string, body any) (int, map[string]any) {
+	s.t.Helper()
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	request := httptest.NewRequest(method, path, reader)
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+
+	var response map[string]any
+	if recorder.Body.Len() > 0 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			s.t.Fatalf("%s %s: decoding %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code, response
+}
+
+// register creates a user and returns its id.
+func (s *testServer) register(email string, password string, userType string) int64 {
+	s.t.Helper()
+	code, response := s.do(http.MethodPost, "/user", "", map[string]string{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      email,
+		"password":   password,
+		"type":       userType,
+	})
+	if code != http.StatusOK {
+		s.t.Fatalf("register %s: status %d, %v", email, code, response)
+	}
+	// The verification email is sent in the background; waiting for it keeps
+	// that goroutine from outliving the test's configuration.
+	s.mailer.token(s.t, email, "Verify your email address")
+	return int64(response["message"].(float64))
+}
+
+// login returns the access and refresh tokens of a password login.
+func (s *testServer) login(email string, password string) (string, string) {
+	s.t.Helper()
+	code, response := s.do(http.MethodPost, "/token", "", map[string]string{"username": email, "password": password})
+	if code != http.StatusOK {
+		s.t.Fatalf("login %s: status %d, %v", email, code, response)
+	}
+	return response["token"].(string), response["refresh_token"].(string)
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	s.register("student@example.com", "secret-password", "student")
+	token, refreshToken := s.login("student@example.com", "secret-password")
+
+	code, response := s.do(http.MethodGet, "/auth/me", token, nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET /auth/me: status %d, %v", code, response)
+	}
+	if email := response["message"].(map[string]any)["email"]; email != "student@example.com" {
+		t.Fatalf("GET /auth/me: email %v", email)
+	}
+	code, response = s.do(http.MethodGet, "/auth/me/sessions", token, nil)
+	if code != http.StatusOK || len(response["message"].([]any)) != 1 {
+		t.Fatalf("GET /auth/me/sessions: status %d, %v", code, response)
+	}
+
+	code, response = s.do(http.MethodPost, "/refresh_token", "", map[string]string{"refresh_token": refreshToken})
+	if code != http.StatusOK {
+		t.Fatalf("refresh: status %d, %v", code, response)
+	}
+	refreshedToken := response["token"].(string)
+	if code, _ := s.do(http.MethodGet, "/auth/me", refreshedToken, nil); code != http.StatusOK {
+		t.Fatalf("GET /auth/me with refreshed token: status %d", code)
+	}
+
+	// Replaying the rotated refresh token revokes the whole session; the
+	// access tokens of a revoked session are refused.
+	if code, _ := s.do(http.MethodPost, "/refresh_token", "", map[string]string{"refresh_token": refreshToken}); code != http.StatusUnauthorized {
+		t.Fatalf("refresh token reuse: status %d", code)
+	}
+	if code, _ := s.do(http.MethodGet, "/auth/me", refreshedToken, nil); code != http.StatusForbidden {
+		t.Fatalf("GET /auth/me after reuse: status %d", code)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	s := newTestServer(t)
+	s.register("teacher@example.com", "secret-password", "teacher")
+	token, _ := s.login("teacher@example.com", "secret-password")
+
+	if code, response := s.do(http.MethodGet, "/logout", token, nil); code != http.StatusOK {
+		t.Fatalf("GET /logout: status %d, %v", code, response)
+	}
+	if code, _ := s.do(http.MethodGet, "/auth/me", token, nil); code != http.StatusForbidden {
+		t.Fatalf("GET /auth/me after logout: status %d", code)
+	}
+}
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	s := newTestServer(t)
+	s.register("student@example.com", "secret-password", "student")
+	token, _ := s.login("student@example.com", "secret-password")
+
+	if code, response := s.do(http.MethodPost, "/user/password_reset", "", map[string]string{"email": "student@example.com"}); code != http.StatusOK {
+		t.Fatalf("request reset: status %d, %v", code, response)
+	}
+	resetToken := s.mailer.token(t, "student@example.com", "Reset your password")
+	code, response := s.do(http.MethodPost, "/user/password_reset/confirm", "", map[string]string{"token": resetToken, "password": "new-secret-password"})
+	if code != http.StatusOK {
+		t.Fatalf("confirm reset: status %d, %v", code, response)
+	}
+
+	if code, _ := s.do(http.MethodGet, "/auth/me", token, nil); code != http.StatusForbidden {
+		t.Fatalf("GET /auth/me with a session from before the reset: status %d", code)
+	}
+	if code, _ := s.do(http.MethodPost, "/user/password_reset/confirm", "", map[string]string{"token": resetToken, "password": "another-password"}); code == http.StatusOK {
+		t.Fatal("reset token accepted twice")
+	}
+	s.login("student@example.com", "new-secret-password")
+}
+
+// failingRevocation is a session store whose revocations fail.
+type failingRevocation struct {
+	store.SessionStore
+}
+
+func (failingRevocation) RevokeUserSessions(ctx context.Context, uuidString string, userId int64, reason string) (int64, error) {
+	return 0, errors.New("revocation failed")
+}
+
+func TestPasswordResetKeepsOldPasswordWhenRevocationFails(t *testing.T) {
+	s := newTestServer(t)
+	s.register("student@example.com", "secret-password", "student")
+	token, _ := s.login("student@example.com", "secret-password")
+
+	if code, response := s.do(http.MethodPost, "/user/password_reset", "", map[string]string{"email": "student@example.com"}); code != http.StatusOK {
+		t.Fatalf("request reset: status %d, %v", code, response)
+	}
+	resetToken := s.mailer.token(t, "student@example.com", "Reset your password")
+
+	store.Default.Sessions = failingRevocation{s.stores.Sessions}
+	code, response := s.do(http.MethodPost, "/user/password_reset/confirm", "", map[string]string{"token": resetToken, "password": "new-secret-password"})
+	if code != http.StatusInternalServerError {
+		t.Fatalf("confirm reset with failing revocation: status %d, want 500, %v", code, response)
+	}
+	store.Default.Sessions = s.stores.Sessions
+
+	// Nothing changed: the old password and session still work and the token
+	// can be used again.
+	if code, response := s.do(http.MethodGet, "/auth/me", token, nil); code != http.StatusOK {
+		t.Fatalf("GET /auth/me after the failed reset: status %d, %v", code, response)
+	}
+	s.login("student@example.com", "secret-password")
+	if code, response := s.do(http.MethodPost, "/user/password_reset/confirm", "", map[string]string{"token": resetToken, "password": "new-secret-password"}); code != http.StatusOK {
+		t.Fatalf("confirm reset again: status %d, %v", code, response)
+	}
+	if code, _ := s.do(http.MethodGet, "/auth/me", token, nil); code != http.StatusForbidden {
+		t.Fatalf("GET /auth/me with a session from before the reset: status %d", code)
+	}
+}
+
+func TestTeacherReadsSubmissionReports(t *testing.T) {
+	s := newTestServer(t)
+	s.register("teacher@example.com", "secret-password", "teacher")
+	studentId := s.register("student@example.com", "secret-password", "student")
+	teacherToken, _ := s.login("teacher@example.com", "secret-password")
+	studentToken, _ := s.login("student@example.com", "secret-password")
+
+	code, response := s.do(http.MethodPost, "/auth/test", teacherToken, map[string]string{"title": "Fractions"})
+	if code != http.StatusOK {
+		t.Fatalf("create test: status %d, %v", code, response)
+	}
+	testId := int64(response["message"].(float64))
+	code, response = s.do(http.MethodPost, "/auth/question", teacherToken, map[string]any{
+		"type":          "multiple_choice",
+		"question_data": map[string]any{"question": "1/2 + 1/4?", "options": []string{"3/4", "2/6"}},
+		"answer_data":   map[string]any{"choices": []string{"3/4"}},
+		"tags":          []string{"fractions"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create question: status %d, %v", code, response)
+	}
+	questionId := int64(response["message"].(float64))
+	if code, response := s.do(http.MethodPut, fmt.Sprintf("/auth/test/%d/question/%d/add_question", testId, questionId), teacherToken, nil); code != http.StatusCreated {
+		t.Fatalf("add question: status %d, %v", code, response)
+	}
+
+	questionPath := fmt.Sprintf("/auth/test/%d/question/%d", testId, questionId)
+	if code, response := s.do(http.MethodPost, questionPath+"/events", studentToken, map[string]any{"type": "view", "active_ms": 1000}); code != http.StatusOK {
+		t.Fatalf("record question event: status %d, %v", code, response)
+	}
+	if code, response := s.do(http.MethodPut, questionPath, studentToken, map[string][]string{"answer_data": {"3/4"}}); code != http.StatusOK {
+		t.Fatalf("submit answer: status %d, %v", code, response)
+	}
+
+	code, response = s.do(http.MethodGet, "/auth/me/progress", studentToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET /auth/me/progress: status %d, %v", code, response)
+	}
+	tests := response["message"].(map[string]any)["tests"].([]any)
+	if len(tests) != 1 || tests[0].(map[string]any)["correct_answers"] != float64(1) {
+		t.Fatalf("progress: %v", response)
+	}
+
+	code, response = s.do(http.MethodGet, fmt.Sprintf("/auth/test/%d/user/%d/question/%d/history", testId, studentId, questionId), teacherToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET history: status %d, %v", code, response)
+	}
+	if events := response["message"].([]any); len(events) != 1 {
+		t.Fatalf("history: %v", response)
+	}
+
+	code, response = s.do(http.MethodGet, fmt.Sprintf("/auth/test/%d/timing_report", testId), teacherToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET timing report: status %d, %v", code, response)
+	}
+	rows := response["message"].([]any)
+	if len(rows) != 1 || rows[0].(map[string]any)["fast_answer_count"] != float64(1) {
+		t.Fatalf("timing report: %v", response)
+	}
+}
+
+func TestTimingReportDoesNotTrustClientTimes(t *testing.T) {
+	s := newTestServer(t)
+	s.register("teacher@example.com", "secret-password", "teacher")
+	s.register("silent@example.com", "secret-password", "student")
+	s.register("inflated@example.com", "secret-password", "student")
+	teacherToken, _ := s.login("teacher@example.com", "secret-password")
+	silentToken, _ := s.login("silent@example.com", "secret-password")
+	inflatedToken, _ := s.login("inflated@example.com", "secret-password")
+
+	code, response := s.do(http.MethodPost, "/auth/test", teacherToken, map[string]string{"title": "Fractions"})
+	if code != http.StatusOK {
+		t.Fatalf("create test: status %d, %v", code, response)
+	}
+	testId := int64(response["message"].(float64))
+	var questionPaths []string
+	for i := 0; i < 3; i++ {
+		code, response = s.do(http.MethodPost, "/auth/question", teacherToken, map[string]any{
+			"type":          "multiple_choice",
+			"question_data": map[string]any{"question": fmt.Sprintf("question %d", i), "options": []string{"a", "b"}},
+			"answer_data":   map[string]any{"choices": []string{"a"}},
+		})
+		if code != http.StatusOK {
+			t.Fatalf("create question: status %d, %v", code, response)
+		}
+		questionId := int64(response["message"].(float64))
+		if code, response := s.do(http.MethodPut, fmt.Sprintf("/auth/test/%d/question/%d/add_question", testId, questionId), teacherToken, nil); code != http.StatusCreated {
+			t.Fatalf("add question: status %d, %v", code, response)
+		}
+		questionPaths = append(questionPaths, fmt.Sprintf("/auth/test/%d/question/%d", testId, questionId))
+	}
+
+	for _, questionPath := range questionPaths {
+		// One student sends no tracking events at all, the other claims a
+		// minute of work but answers right after the first view.
+		if code, response := s.do(http.MethodPut, questionPath, silentToken, map[string][]string{"answer_data": {"a"}}); code != http.StatusOK {
+			t.Fatalf("submit answer: status %d, %v", code, response)
+		}
+		if code, response := s.do(http.MethodPost, questionPath+"/events", inflatedToken, map[string]any{"type": "view", "active_ms": 60000}); code != http.StatusOK {
+			t.Fatalf("record question event: status %d, %v", code, response)
+		}
+		if code, response := s.do(http.MethodPut, questionPath, inflatedToken, map[string][]string{"answer_data": {"a"}}); code != http.StatusOK {
+			t.Fatalf("submit answer: status %d, %v", code, response)
+		}
+	}
+
+	code, response = s.do(http.MethodGet, fmt.Sprintf("/auth/test/%d/timing_report", testId), teacherToken, nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET timing report: status %d, %v", code, response)
+	}
+	rows := response["message"].([]any)
+	if len(rows) != 2 {
+		t.Fatalf("timing report: %v", response)
+	}
+	for _, row := range rows {
+		report := row.(map[string]any)
+		if report["fast_answer_count"] != float64(3) || report["flagged"] != true {
+			t.Errorf("timing report of %s: %v, want 3 fast answers and flagged", report["email"], report)
+		}
+		if report["email"] == "silent@example.com" && report["untracked_count"] != float64(3) {
+			t.Errorf("timing report of silent@example.com: %v, want 3 untracked answers", report)
+		}
+	}
+}
+
+// sessionClientIP logs in with an X-Forwarded-For header and returns the
+// client IP recorded for the new session.
+func (s *testServer) sessionClientIP(email string, password string, forwardedFor string) string {
+	s.t.Helper()
+	data, err := json.Marshal(map[string]string{"username": email, "password": password})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/token", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Forwarded-For", forwardedFor)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	var response map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || recorder.Code != http.StatusOK {
+		s.t.Fatalf("login %s: status %d, %s", email, recorder.Code, recorder.Body.String())
+	}
+
+	code, sessions := s.do(http.MethodGet, "/auth/me/sessions", response["token"].(string), nil)
+	if code != http.StatusOK {
+		s.t.Fatalf("GET /auth/me/sessions: status %d, %v", code, sessions)
+	}
+	for _, session := range sessions["message"].([]any) {
+		if session.(map[string]any)["current"] == true {
+			return session.(map[string]any)["client_ip"].(string)
+		}
+	}
+	s.t.Fatalf("GET /auth/me/sessions: no current session in %v", sessions)
+	return ""
+}
+
+func TestClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	s := newTestServer(t)
+	s.register("student@example.com", "secret-password", "student")
+
+	// httptest requests come from 192.0.2.1.
+	if clientIP := s.sessionClientIP("student@example.com", "secret-password", "203.0.113.7"); clientIP != "192.0.2.1" {
+		t.Fatalf("client IP without trusted proxies = %s, want the peer address", clientIP)
+	}
+
+	core.Config.TrustedProxies = []string{"192.0.2.0/24"}
+	authMiddleware, err := middleware.GetAuthMiddleware()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.router, err = newRouter(authMiddleware); err != nil {
+		t.Fatal(err)
+	}
+	if clientIP := s.sessionClientIP("student@example.com", "secret-password", "203.0.113.7"); clientIP != "203.0.113.7" {
+		t.Fatalf("client IP behind a trusted proxy = %s, want the forwarded address", clientIP)
+	}
+}
+
+func TestAPIKeyCannotManageOwnAccount(t *testing.T) {
+	s := newTestServer(t)
+	userId := s.register("student@example.com", "secret-password", "student")
+	token, _ := s.login("student@example.com", "secret-password")
+
+	code, response := s.do(http.MethodPost, "/auth/me/api_keys", token, map[string]any{
+		"name":            "reporting",
+		"scopes":          []string{"test:read"},
+		"expires_in_days": 30,
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("POST /auth/me/api_keys: status %d, %v", code, response)
+	}
+	key := response["message"].(map[string]any)["key"].(string)
+
+	if code, response := s.do(http.MethodGet, "/auth/me", key, nil); code != http.StatusOK {
+		t.Fatalf("GET /auth/me with api key: status %d, %v", code, response)
+	}
+	update := map[string]string{"first_name": "Renamed", "last_name": "User", "email": "attacker@example.com", "type": "student"}
+	userPath := fmt.Sprintf("/auth/user/%d", userId)
+	for _, request := range []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodPut, userPath, update},
+		{http.MethodDelete, userPath, nil},
+		{http.MethodPut, "/auth/me", update},
+	} {
+		if code, response := s.do(request.method, request.path, key, request.body); code != http.StatusForbidden {
+			t.Errorf("%s %s with api key: status %d, want 403, %v", request.method, request.path, code, response)
+		}
+	}
+
+	code, response = s.do(http.MethodGet, "/auth/me", token, nil)
+	if code != http.StatusOK || response["message"].(map[string]any)["email"] != "student@example.com" {
+		t.Fatalf("GET /auth/me after api key attempts: status %d, %v", code, response)
+	}
+	if code, response := s.do(http.MethodPut, userPath, token, map[string]string{"first_name": "Renamed", "last_name": "User", "email": "student@example.com", "type": "student"}); code != http.StatusOK {
+		t.Fatalf("PUT %s with session: status %d, %v", userPath, code, response)
+	}
+}
+
+func TestAPIKeyWithTestTakeScopeSubmitsAnswers(t *testing.T) {
+	s := newTestServer(t)
+	s.register("teacher@example.com", "secret-password", "teacher")
+	s.register("student@example.com", "secret-password", "student")
+	teacherToken, _ := s.login("teacher@example.com", "secret-password")
+	studentToken, _ := s.login("student@example.com", "secret-password")
+
+	code, response := s.do(http.MethodPost, "/auth/test", teacherToken, map[string]string{"title": "Fractions"})
+	if code != http.StatusOK {
+		t.Fatalf("create test: status %d, %v", code, response)
+	}
+	testId := int64(response["message"].(float64))
+	code, response = s.do(http.MethodPost, "/auth/question", teacherToken, map[string]any{
+		"type":          "multiple_choice",
+		"question_data": map[string]any{"question": "1/2 + 1/4?", "options": []string{"3/4", "2/6"}},
+		"answer_data":   map[string]any{"choices": []string{"3/4"}},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create question: status %d, %v", code, response)
+	}
+	questionId := int64(response["message"].(float64))
+	if code, response := s.do(http.MethodPut, fmt.Sprintf("/auth/test/%d/question/%d/add_question", testId, questionId), teacherToken, nil); code != http.StatusCreated {
+		t.Fatalf("add question: status %d, %v", code, response)
+	}
+
+	code, response = s.do(http.MethodPost, "/auth/me/api_keys", studentToken, map[string]any{
+		"name":   "answers",
+		"scopes": []string{"test:take"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("POST /auth/me/api_keys with test:take: status %d, %v", code, response)
+	}
+	key := response["message"].(map[string]any)["key"].(string)
+
+	questionPath := fmt.Sprintf("/auth/test/%d/question/%d", testId, questionId)
+	if code, response := s.do(http.MethodPut, questionPath, key, map[string][]string{"answer_data": {"3/4"}}); code != http.StatusOK {
+		t.Fatalf("submit answer with api key: status %d, %v", code, response)
+	}
+}
+
+func TestRoleChangeRevokesSessionsAndAPIKeys(t *testing.T) {
+	s := newTestServer(t)
+	adminId := s.register("admin@example.com", "secret-password", "student")
+	if _, err := s.stores.Users.UpdateUserType(context.Background(), "", adminId, models.ADMIN); err != nil {
+		t.Fatal(err)
+	}
+	teacherId := s.register("teacher@example.com", "secret-password", "teacher")
+	adminToken, _ := s.login("admin@example.com", "secret-password")
+	teacherToken, _ := s.login("teacher@example.com", "secret-password")
+
+	code, response := s.do(http.MethodPost, "/auth/me/api_keys", teacherToken, map[string]any{
+		"name":   "authoring",
+		"scopes": []string{"test:write"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("POST /auth/me/api_keys: status %d, %v", code, response)
+	}
+	key := response["message"].(map[string]any)["key"].(string)
+	if code, response := s.do(http.MethodPost, "/auth/test", key, map[string]string{"title": "Fractions"}); code != http.StatusOK {
+		t.Fatalf("create test with api key: status %d, %v", code, response)
+	}
+
+	rolePath := fmt.Sprintf("/auth/admin/user/%d/role", teacherId)
+	if code, response := s.do(http.MethodPut, rolePath, adminToken, map[string]string{"type": "student"}); code != http.StatusOK {
+		t.Fatalf("PUT %s: status %d, %v", rolePath, code, response)
+	}
+
+	// Neither the session nor the key issued while the user was a teacher
+	// keeps working; a new login gets the student's permissions.
+	if code, _ := s.do(http.MethodGet, "/auth/me", teacherToken, nil); code != http.StatusForbidden {
+		t.Errorf("GET /auth/me with a session from before the role change: status %d, want 403", code)
+	}
+	if code, _ := s.do(http.MethodPost, "/auth/test", key, map[string]string{"title": "Decimals"}); code == http.StatusOK {
+		t.Error("create test with an api key from before the role change succeeded")
+	}
+	studentToken, _ := s.login("teacher@example.com", "secret-password")
+	if code, _ := s.do(http.MethodPost, "/auth/test", studentToken, map[string]string{"title": "Decimals"}); code != http.StatusForbidden {
+		t.Errorf("create test as a student: status %d, want 403", code)
+	}
+}
@@ -14,10 +14,12 @@ const (
 	Authorization        Type = "AUTHORIZATION"          // Authentication Failures -
 	BadRequest           Type = "BAD_REQUEST"            // Validation errors / BadInput
 	Conflict             Type = "CONFLICT"               // Already exists (eg, create account with existent email) - 409
+	Forbidden            Type = "FORBIDDEN"              // Authenticated but not allowed - 403
 	Internal             Type = "INTERNAL"               // Server (500) and fallback errors
 	NotFound             Type = "NOT_FOUND"              // For not finding resource
 	PayloadTooLarge      Type = "PAYLOAD_TOO_LARGE"      // for uploading tons of JSON, or an image over the limit - 413
 	ServiceUnavailable   Type = "SERVICE_UNAVAILABLE"    // For long running handlers
+	TooManyRequests      Type = "TOO_MANY_REQUESTS"      // Throttled, e.g. repeated failed logins - 429
 	UnsupportedMediaType Type = "UNSUPPORTED_MEDIA_TYPE" // for http 415
 )
 
@@ -47,6 +49,8 @@ func (e *Error) Status() int {
 		return http.StatusBadRequest
 	case Conflict:
 		return http.StatusConflict
+	case Forbidden:
+		return http.StatusForbidden
 	case Internal:
 		return http.StatusInternalServerError
 	case NotFound:
@@ -55,6 +59,8 @@ func (e *Error) Status() int {
 		return http.StatusRequestEntityTooLarge
 	case ServiceUnavailable:
 		return http.StatusServiceUnavailable
+	case TooManyRequests:
+		return http.StatusTooManyRequests
 	case UnsupportedMediaType:
 		return http.StatusUnsupportedMediaType
 	default:
@@ -101,6 +107,14 @@ func NewConflict(name string, value string) *Error {
 	}
 }
 
+// NewForbidden to create an error for 403
+func NewForbidden(reason string) *Error {
+	return &Error{
+		Type:    Forbidden,
+		Message: reason,
+	}
+}
+
 // NewInternal for 500 errors and unknown errors
 func NewInternal() *Error {
 	return &Error{
@@ -133,6 +147,14 @@ func NewServiceUnavailable() *Error {
 	}
 }
 
+// NewTooManyRequests to create an error for 429
+func NewTooManyRequests(reason string) *Error {
+	return &Error{
+		Type:    TooManyRequests,
+		Message: reason,
+	}
+}
+
 // NewUnsupportedMediaType to create an error for 415
 func NewUnsupportedMediaType(reason string) *Error {
 	return &Error{
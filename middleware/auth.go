@@ -9,6 +9,8 @@ import (
 	"github.com/open-lms-test-functionality/core"
 	"github.com/open-lms-test-functionality/logger"
 	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -26,7 +28,8 @@ func payloadFunc() func(data interface{}) jwt.MapClaims {
 	return func(data interface{}) jwt.MapClaims {
 		if v, ok := data.(*models.UserSchema); ok {
 			return jwt.MapClaims{
-				identityKey: v.Email,
+				identityKey:    v.Email,
+				sessionIdClaim: v.SessionId,
 			}
 		}
 		return jwt.MapClaims{}
@@ -36,8 +39,10 @@ func payloadFunc() func(data interface{}) jwt.MapClaims {
 func identityHandler() func(c *gin.Context) interface{} {
 	return func(c *gin.Context) interface{} {
 		claims := jwt.ExtractClaims(c)
+		sessionId, _ := claims[sessionIdClaim].(string)
 		return &models.UserSchema{
-			Email: claims[identityKey].(string),
+			Email:     claims[identityKey].(string),
+			SessionId: sessionId,
 		}
 	}
 }
@@ -51,7 +56,11 @@ func authenticator() func(c *gin.Context) (interface{}, error) {
 		userEmail := loginVals.Username
 		password := loginVals.Password
 
-		userDataFromDb := models.FetchUserForAuth(userEmail)
+		if throttleErr := ReserveLoginAttempt(c, userEmail); throttleErr != nil {
+			return nil, throttleErr
+		}
+
+		userDataFromDb := store.Default.Users.FetchUserForAuth(c.Request.Context(), userEmail)
 		if userDataFromDb.Id == 0 {
 			return "", errors.New("no account found")
 		}
@@ -59,18 +68,50 @@ func authenticator() func(c *gin.Context) (interface{}, error) {
 		err := bcrypt.CompareHashAndPassword([]byte(userDataFromDb.Password), []byte(password))
 		if err != nil {
 			logger.Logger.Error("AUTH :: Error while comparing hash of password", zap.Error(err))
+			return nil, jwt.ErrFailedAuthentication
+		}
+		ReleaseLoginAttempt(c, userEmail)
+
+		if userDataFromDb.PasswordResetRequired {
+			return nil, errors.New("password reset required")
+		} else if core.Config.RequireEmailVerification && !userDataFromDb.EmailVerified {
+			return nil, errors.New("email not verified")
 		} else {
+			challenge, err := LoginMFAChallenge(c.Request.Context(), userDataFromDb)
+			if err != nil {
+				return nil, err
+			}
+			if challenge != nil {
+				// No session yet: the second factor is checked by POST /token/mfa.
+				return challenge, nil
+			}
+			ClearLoginFailures(c, userEmail)
+			if err := startSession(c, &userDataFromDb); err != nil {
+				logger.Logger.Error("AUTH :: Error while starting session", zap.Error(err))
+				return nil, jwt.ErrFailedTokenCreation
+			}
 			return &userDataFromDb, nil
 		}
-		return nil, jwt.ErrFailedAuthentication
 	}
 }
 
 func authorizator() func(data interface{}, c *gin.Context) bool {
 	return func(data interface{}, c *gin.Context) bool {
 		if v, ok := data.(*models.UserSchema); ok {
-			userDataFromDb := models.FetchUserForAuth(v.Email)
-			return userDataFromDb.Id != 0
+			if v.SessionId == "" {
+				return false
+			}
+			userDataFromDb := store.Default.Users.FetchUserIdentity(c.Request.Context(), v.Email)
+			if userDataFromDb.Id == 0 {
+				return false
+			}
+			active, err := store.Default.Sessions.IsSessionActive(c.Request.Context(), utils.GetUUID(), v.SessionId, userDataFromDb.Id)
+			if err != nil || !active {
+				return false
+			}
+			userDataFromDb.SessionId = v.SessionId
+			c.Set(authUserKey, userDataFromDb)
+			return true
 		}
 		return false
 	}
@@ -89,8 +130,8 @@ func GetAuthMiddleware() (*jwt.GinJWTMiddleware, error) {
 	authMiddleware, err := jwt.New(&jwt.GinJWTMiddleware{
 		Realm:       core.Config.AuthRealm,
 		Key:         []byte(core.Config.AuthSecretKey),
-		Timeout:     12 * time.Hour,
-		MaxRefresh:  12 * time.Hour,
+		Timeout:     time.Duration(core.Config.AccessTokenTTLMinutes) * time.Minute,
+		MaxRefresh:  time.Duration(core.Config.AccessTokenTTLMinutes) * time.Minute,
 		IdentityKey: identityKey,
 		PayloadFunc: payloadFunc(),
 
@@ -98,6 +139,7 @@ func GetAuthMiddleware() (*jwt.GinJWTMiddleware, error) {
 		Authenticator:   authenticator(),
 		Authorizator:    authorizator(),
 		Unauthorized:    unauthorized(),
+		LoginResponse:   loginResponse(),
 		TokenLookup:     "header: Authorization, query: token, cookie: jwt",
 		TokenHeadName:   "Bearer",
 		TimeFunc:        time.Now,
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/models"
+)
+
+// RequestContext returns the context database calls made for a request run
+// under. It is cancelled when the client disconnects or the request times out
+// and carries the request id for the query log. Once the request wrote, its
+// reads go to the primary instead of a possibly lagging replica; the request
+// keeps that state however often RequestContext is called.
+func RequestContext(c *gin.Context, uuidString string) context.Context {
+	if !models.TracksWrites(c.Request.Context()) {
+		c.Request = c.Request.WithContext(models.WithReadYourWrites(c.Request.Context()))
+	}
+	return models.WithRequestId(c.Request.Context(), uuidString)
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	mfaChallengeTTL = 5 * time.Minute
+)
+
+// MFAChallenge is what the authenticator returns instead of a user when the
+// password was right but a second factor is still needed. Its token proves
+// the first step and is exchanged at POST /token/mfa.
+type MFAChallenge struct {
+	Token              string
+	Expire             time.Time
+	EnrollmentRequired bool
+}
+
+func newMFAChallenge(userId int64, enrollmentRequired bool) (*MFAChallenge, error) {
+	token, expire, err := utils.SignToken([]byte(core.Config.AuthSecretKey), models.TOKENPURPOSEMFACHALLENGE, userId, mfaChallengeTTL)
+	if err != nil {
+		logger.Logger.Error("AUTH :: Error while signing mfa challenge", zap.Error(err))
+		return nil, jwt.ErrFailedTokenCreation
+	}
+	return &MFAChallenge{Token: token, Expire: expire, EnrollmentRequired: enrollmentRequired}, nil
+}
+
+// LoginMFAChallenge returns the challenge a user has to pass before a session
+// starts, or nil when they have no second factor and their role does not
+// require one. Every way of signing in asks for it, so single sign-on and LTI
+// launches do not get around a second factor a password login would need.
+func LoginMFAChallenge(ctx context.Context, userData models.UserSchema) (*MFAChallenge, error) {
+	mfaState, err := store.Default.MFA.FetchMFAState(ctx, utils.GetUUID(), userData.Id, userData.UserType())
+	if err != nil {
+		return nil, jwt.ErrFailedTokenCreation
+	}
+	if !mfaState.Enabled && !mfaState.Required {
+		return nil, nil
+	}
+	return newMFAChallenge(userData.Id, !mfaState.Enabled)
+}
+
+// MFAChallengeResponse writes the challenge the way POST /token answers when
+// a second factor is needed.
+func MFAChallengeResponse(c *gin.Context, challenge *MFAChallenge) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":                    http.StatusOK,
+		"mfa_required":            true,
+		"mfa_enrollment_required": challenge.EnrollmentRequired,
+		"mfa_token":               challenge.Token,
+		"mfa_expire":              challenge.Expire.Format(time.RFC3339),
+	})
+}
+
+// VerifyMFAChallenge returns the user a challenge token was issued to.
+func VerifyMFAChallenge(token string) (int64, error) {
+	return utils.VerifyToken([]byte(core.Config.AuthSecretKey), models.TOKENPURPOSEMFACHALLENGE, token)
+}
+
+// LoginHandler wraps the JWT login: when the authenticator answers with an
+// MFA challenge, the challenge is returned instead of an access token.
+// Application errors, such as throttled logins, keep their status code.
+func LoginHandler(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := authMiddleware.Authenticator(c)
+		if err != nil {
+			status := http.StatusUnauthorized
+			var appError *Error
+			if errors.As(err, &appError) {
+				status = appError.Status()
+			}
+			authMiddleware.Unauthorized(c, status, authMiddleware.HTTPStatusMessageFunc(err, c))
+			return
+		}
+
+		if challenge, ok := data.(*MFAChallenge); ok {
+			MFAChallengeResponse(c, challenge)
+			return
+		}
+
+		token, expire, err := authMiddleware.TokenGenerator(data)
+		if err != nil {
+			authMiddleware.Unauthorized(c, http.StatusUnauthorized, authMiddleware.HTTPStatusMessageFunc(jwt.ErrFailedTokenCreation, c))
+			return
+		}
+		authMiddleware.LoginResponse(c, http.StatusOK, token, expire)
+	}
+}
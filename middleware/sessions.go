@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	sessionIdClaim   = "jti"
+	refreshTokenKey  = "refreshToken"
+	refreshExpireKey = "refreshExpire"
+)
+
+// startSession creates the server side session for a successful login. The
+// session id becomes the jti claim of the access token, and the refresh token
+// is kept in the context for the login response.
+func startSession(c *gin.Context, userData *models.UserSchema) error {
+	uuidString := utils.GetUUID()
+	ctx := RequestContext(c, uuidString)
+
+	refreshToken, err := utils.RandomString(32)
+	if err != nil {
+		return err
+	}
+	sessionId := utils.GetUUID()
+	expiresAt := time.Now().Add(time.Duration(core.Config.RefreshTokenTTLHours) * time.Hour)
+
+	err = store.Default.Sessions.CreateSession(ctx, uuidString, sessionId, userData.Id, c.ClientIP(), c.Request.UserAgent(), utils.HashToken(refreshToken), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	userData.SessionId = sessionId
+	c.Set(refreshTokenKey, refreshToken)
+	c.Set(refreshExpireKey, expiresAt)
+	return nil
+}
+
+func loginResponse() func(c *gin.Context, code int, token string, expire time.Time) {
+	return func(c *gin.Context, code int, token string, expire time.Time) {
+		response := gin.H{
+			"code":   code,
+			"token":  token,
+			"expire": expire.Format(time.RFC3339),
+		}
+		if refreshToken, exists := c.Get(refreshTokenKey); exists {
+			response["refresh_token"] = refreshToken
+		}
+		if refreshExpire, exists := c.Get(refreshExpireKey); exists {
+			response["refresh_expire"] = refreshExpire.(time.Time).Format(time.RFC3339)
+		}
+		c.JSON(code, response)
+	}
+}
+
+// LoginTokens are the tokens handed out when a session starts.
+type LoginTokens struct {
+	Token         string
+	Expire        time.Time
+	RefreshToken  string
+	RefreshExpire time.Time
+}
+
+// IssueLoginTokens starts a session for a user that was authenticated outside
+// the login handler and returns its tokens.
+func IssueLoginTokens(c *gin.Context, authMiddleware *jwt.GinJWTMiddleware, userData models.UserSchema) (LoginTokens, error) {
+	var tokens LoginTokens
+	if err := startSession(c, &userData); err != nil {
+		logger.Logger.Error("AUTH :: Error while starting session", zap.Error(err))
+		return tokens, err
+	}
+
+	token, expire, err := authMiddleware.TokenGenerator(&userData)
+	if err != nil {
+		logger.Logger.Error("AUTH :: Error while generating token", zap.Error(err))
+		return tokens, err
+	}
+	tokens.Token = token
+	tokens.Expire = expire
+	tokens.RefreshToken = c.GetString(refreshTokenKey)
+	tokens.RefreshExpire = c.GetTime(refreshExpireKey)
+	return tokens, nil
+}
+
+// CompleteLogin starts a session and writes the same response as the login
+// handler, for flows that authenticate the user some other way.
+func CompleteLogin(c *gin.Context, authMiddleware *jwt.GinJWTMiddleware, userData models.UserSchema) {
+	tokens, err := IssueLoginTokens(c, authMiddleware, userData)
+	if err != nil {
+		e := NewInternal()
+		c.JSON(e.Status(), gin.H{"message": e.Message})
+		return
+	}
+	authMiddleware.LoginResponse(c, 200, tokens.Token, tokens.Expire)
+}
+
+// RefreshHandler exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token can only be used once.
+func RefreshHandler(authMiddleware *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uuidString := utils.GetUUID()
+		c.Header("X-REQUEST-ID", uuidString)
+		ctx := RequestContext(c, uuidString)
+
+		var refreshData models.RefreshTokenSchema
+		if err := c.ShouldBind(&refreshData); err != nil || refreshData.RefreshToken == "" {
+			e := NewBadRequest("refresh_token is required")
+			c.JSON(e.Status(), gin.H{"message": e.Message})
+			return
+		}
+
+		refreshToken, err := utils.RandomString(32)
+		if err != nil {
+			e := NewInternal()
+			c.JSON(e.Status(), gin.H{"message": e.Message})
+			return
+		}
+		expiresAt := time.Now().Add(time.Duration(core.Config.RefreshTokenTTLHours) * time.Hour)
+
+		sessionUser, err := store.Default.Sessions.RotateRefreshToken(ctx, uuidString, utils.HashToken(refreshData.RefreshToken), utils.HashToken(refreshToken), expiresAt)
+		if err == models.ErrSessionNotFound || err == models.ErrRefreshTokenReused {
+			e := NewAuthorization("invalid refresh token")
+			c.JSON(e.Status(), gin.H{"message": e.Message})
+			return
+		} else if err != nil {
+			e := NewInternal()
+			c.JSON(e.Status(), gin.H{"message": e.Message})
+			return
+		}
+
+		token, expire, err := authMiddleware.TokenGenerator(&models.UserSchema{
+			Id:        sessionUser.UserId,
+			Email:     sessionUser.Email,
+			SessionId: sessionUser.SessionId,
+		})
+		if err != nil {
+			logger.Logger.Error("AUTH :: Error while generating token", zap.String("requestId", uuidString), zap.Error(err))
+			e := NewInternal()
+			c.JSON(e.Status(), gin.H{"message": e.Message})
+			return
+		}
+
+		c.Set(refreshTokenKey, refreshToken)
+		c.Set(refreshExpireKey, expiresAt)
+		authMiddleware.LoginResponse(c, 200, token, expire)
+	}
+}
+
+// CurrentSessionId returns the session the request's access token belongs to.
+func CurrentSessionId(c *gin.Context) string {
+	if value, exists := c.Get(identityKey); exists {
+		if identity, ok := value.(*models.UserSchema); ok {
+			return identity.SessionId
+		}
+	}
+	return ""
+}
@@ -117,6 +117,10 @@ func (tw *timeoutWriter) Write(b []byte) (int, error) {
 
 // In http.ResponseWriter interface
 func (tw *timeoutWriter) WriteHeader(code int) {
+	// gin passes -1 to keep the current status, e.g. in c.Redirect
+	if code < 0 {
+		return
+	}
 	checkWriteHeaderCode(code)
 	tw.mu.Lock()
 	defer tw.mu.Unlock()
@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS test_questions_question_id_idx;
+DROP INDEX IF EXISTS test_question_submissions_test_user_idx;
+DROP INDEX IF EXISTS test_question_submissions_question_id_idx;
+
+ALTER TABLE test_question_submissions
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE test_question_submissions
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS test_question_submissions_question_id_idx ON test_question_submissions(question_id);
+CREATE INDEX IF NOT EXISTS test_question_submissions_test_user_idx ON test_question_submissions(test_id, user_id);
+CREATE INDEX IF NOT EXISTS test_questions_question_id_idx ON test_questions(question_id);
+
+COMMIT;
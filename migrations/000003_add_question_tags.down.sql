@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS test_question_submissions_user_id_idx;
+DROP INDEX IF EXISTS questions_tags_idx;
+
+ALTER TABLE questions
+    DROP COLUMN IF EXISTS tags;
+
+COMMIT;
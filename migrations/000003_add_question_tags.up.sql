@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE questions
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS questions_tags_idx ON questions USING GIN(tags);
+CREATE INDEX IF NOT EXISTS test_question_submissions_user_id_idx ON test_question_submissions(user_id);
+
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS question_time_tracking(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    test_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    question_id BIGINT NOT NULL,
+    first_seen_at TIMESTAMPTZ,
+    last_seen_at TIMESTAMPTZ,
+    last_answered_at TIMESTAMPTZ,
+    active_time_ms BIGINT NOT NULL DEFAULT 0,
+
+    CONSTRAINT question_time_tracking_unique
+        UNIQUE(test_id, user_id, question_id),
+
+    CONSTRAINT test_id
+        FOREIGN KEY(test_id)
+            REFERENCES tests(id) ON DELETE CASCADE,
+
+    CONSTRAINT question_id
+        FOREIGN KEY(question_id)
+            REFERENCES questions(id) ON DELETE CASCADE,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+COMMIT;
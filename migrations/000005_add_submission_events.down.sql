@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS submission_events;
+DROP FUNCTION IF EXISTS submission_events_prevent_update();
+
+COMMIT;
@@ -0,0 +1,47 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS submission_events(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    submission_id BIGINT NOT NULL,
+    test_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    question_id BIGINT NOT NULL,
+    submitted_data JSONB,
+    answer_status BOOLEAN DEFAULT false,
+    client_ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT submission_id
+        FOREIGN KEY(submission_id)
+            REFERENCES test_question_submissions(id) ON DELETE CASCADE,
+
+    CONSTRAINT test_id
+        FOREIGN KEY(test_id)
+            REFERENCES tests(id) ON DELETE CASCADE,
+
+    CONSTRAINT question_id
+        FOREIGN KEY(question_id)
+            REFERENCES questions(id) ON DELETE CASCADE,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS submission_events_lookup_idx ON submission_events(test_id, user_id, question_id, created_at);
+
+-- The log is append-only: rows can only go away together with their parent
+-- records, never be rewritten.
+CREATE OR REPLACE FUNCTION submission_events_prevent_update() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'submission_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS submission_events_no_update ON submission_events;
+CREATE TRIGGER submission_events_no_update
+    BEFORE UPDATE ON submission_events
+    FOR EACH ROW EXECUTE FUNCTION submission_events_prevent_update();
+
+COMMIT;
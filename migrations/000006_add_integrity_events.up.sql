@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS integrity_events(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    test_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    client_ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT test_id
+        FOREIGN KEY(test_id)
+            REFERENCES tests(id) ON DELETE CASCADE,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS integrity_events_test_user_idx ON integrity_events(test_id, user_id);
+
+COMMIT;
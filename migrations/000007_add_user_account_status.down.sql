@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS users_type_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS password_reset_required,
+    DROP COLUMN IF EXISTS deactivated_at,
+    DROP COLUMN IF EXISTS created_at;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS sessions(
+    id VARCHAR(36) NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    client_ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoked_reason VARCHAR(50),
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions(user_id);
+
+-- Every refresh token ever issued for a session is kept, so presenting one
+-- that was already rotated can be detected as reuse.
+CREATE TABLE IF NOT EXISTS session_refresh_tokens(
+    token_hash VARCHAR(64) NOT NULL PRIMARY KEY,
+    session_id VARCHAR(36) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    rotated_at TIMESTAMPTZ,
+
+    CONSTRAINT session_id
+        FOREIGN KEY(session_id)
+            REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS session_refresh_tokens_session_id_idx ON session_refresh_tokens(session_id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS lti_resource_links;
+DROP TABLE IF EXISTS lti_context_members;
+DROP TABLE IF EXISTS lti_contexts;
+DROP TABLE IF EXISTS lti_nonces;
+
+COMMIT;
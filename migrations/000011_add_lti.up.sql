@@ -0,0 +1,68 @@
+BEGIN;
+
+-- Launch nonces are kept until their id_token expires so a launch cannot be
+-- replayed.
+CREATE TABLE IF NOT EXISTS lti_nonces(
+    nonce VARCHAR(64) NOT NULL PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS lti_nonces_expires_at_idx ON lti_nonces(expires_at);
+
+-- A course on a platform.
+CREATE TABLE IF NOT EXISTS lti_contexts(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    issuer TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    context_id TEXT NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    title TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT lti_contexts_issuer_deployment_context_key UNIQUE(issuer, deployment_id, context_id)
+);
+
+CREATE TABLE IF NOT EXISTS lti_context_members(
+    context_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    last_launch_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(context_id, user_id),
+
+    CONSTRAINT context_id
+        FOREIGN KEY(context_id)
+            REFERENCES lti_contexts(id) ON DELETE CASCADE,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- A placement of a test in a course. lineitem_url is the AGS line item scores
+-- are published to.
+CREATE TABLE IF NOT EXISTS lti_resource_links(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    context_id BIGINT NOT NULL,
+    resource_link_id TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    test_id BIGINT,
+    lineitem_url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT lti_resource_links_context_resource_link_key UNIQUE(context_id, resource_link_id),
+
+    CONSTRAINT context_id
+        FOREIGN KEY(context_id)
+            REFERENCES lti_contexts(id) ON DELETE CASCADE,
+
+    CONSTRAINT test_id
+        FOREIGN KEY(test_id)
+            REFERENCES tests(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS lti_resource_links_test_id_idx ON lti_resource_links(test_id);
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+-- API keys let scripts and services call the API on behalf of a user without
+-- storing the user's password. Only the SHA-256 of a key is stored; the prefix
+-- is kept in clear so a key can be recognised in listings and logs.
+CREATE TABLE IF NOT EXISTS api_keys(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    last_used_ip TEXT NOT NULL DEFAULT '',
+    revoked_at TIMESTAMPTZ,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys(user_id);
+
+COMMIT;
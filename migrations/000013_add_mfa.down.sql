@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS role_settings;
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+
+COMMIT;
@@ -0,0 +1,39 @@
+BEGIN;
+
+-- One TOTP authenticator per user. The secret is encrypted with the server
+-- secret; enabled_at stays NULL until the first code has been verified.
+-- last_used_step stops a code from being used twice.
+CREATE TABLE IF NOT EXISTS user_mfa(
+    user_id BIGINT NOT NULL PRIMARY KEY,
+    secret TEXT NOT NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    enabled_at TIMESTAMPTZ,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_recovery_codes_user_id_idx ON user_recovery_codes(user_id);
+
+-- Settings that apply to every user of a role (users.type).
+CREATE TABLE IF NOT EXISTS role_settings(
+    user_type INT NOT NULL PRIMARY KEY,
+    mfa_required BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,39 @@
+BEGIN;
+
+-- Failed login counters, per account (lower case email) and per client IP.
+-- They live in the database so every server instance sees the same counts.
+CREATE TABLE IF NOT EXISTS login_throttles(
+    scope VARCHAR(10) NOT NULL,
+    key TEXT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    blocked_until TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(scope, key)
+);
+
+-- Audit trail of every lockout and of who lifted it.
+CREATE TABLE IF NOT EXISTS login_lockouts(
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    scope VARCHAR(10) NOT NULL,
+    key TEXT NOT NULL,
+    user_id BIGINT,
+    client_ip TEXT NOT NULL DEFAULT '',
+    failures INT NOT NULL,
+    locked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ NOT NULL,
+    unlocked_at TIMESTAMPTZ,
+    unlocked_by BIGINT,
+
+    CONSTRAINT user_id
+        FOREIGN KEY(user_id)
+            REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT unlocked_by
+        FOREIGN KEY(unlocked_by)
+            REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_lockouts_user_id_idx ON login_lockouts(user_id);
+CREATE INDEX IF NOT EXISTS login_lockouts_locked_at_idx ON login_lockouts(locked_at);
+
+COMMIT;
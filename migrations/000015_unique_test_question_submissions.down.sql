@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE test_question_submissions
+    DROP CONSTRAINT IF EXISTS test_question_submissions_test_user_question_key;
+
+COMMIT;
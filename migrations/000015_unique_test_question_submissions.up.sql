@@ -0,0 +1,34 @@
+BEGIN;
+
+-- Keep the most recent submission of every (test, user, question) and move the
+-- event history of the duplicates onto it before removing them.
+CREATE TEMPORARY TABLE test_question_submission_duplicates ON COMMIT DROP AS
+    SELECT
+        id,
+        FIRST_VALUE(id) OVER (
+            PARTITION BY test_id, user_id, question_id
+            ORDER BY updated_at DESC, id DESC
+        ) AS keep_id
+    FROM test_question_submissions;
+
+DELETE FROM test_question_submission_duplicates WHERE id = keep_id;
+
+-- Re-parenting the events is the one rewrite the append-only log allows, so the
+-- trigger of 000005 is switched off for this transaction only.
+ALTER TABLE submission_events DISABLE TRIGGER submission_events_no_update;
+
+UPDATE submission_events e
+    SET submission_id = d.keep_id
+    FROM test_question_submission_duplicates d
+    WHERE e.submission_id = d.id;
+
+ALTER TABLE submission_events ENABLE TRIGGER submission_events_no_update;
+
+DELETE FROM test_question_submissions s
+    USING test_question_submission_duplicates d
+    WHERE s.id = d.id;
+
+ALTER TABLE test_question_submissions
+    ADD CONSTRAINT test_question_submissions_test_user_question_key UNIQUE (test_id, user_id, question_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS test_question_submissions_test_user_id_idx;
+DROP INDEX IF EXISTS test_questions_test_id_id_idx;
+
+COMMIT;
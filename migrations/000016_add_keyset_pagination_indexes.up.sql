@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Keyset pages filter on the parent and walk the ids newest first.
+CREATE INDEX IF NOT EXISTS test_questions_test_id_id_idx ON test_questions(test_id, id);
+CREATE INDEX IF NOT EXISTS test_question_submissions_test_user_id_idx ON test_question_submissions(test_id, user_id, id);
+
+COMMIT;
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	USERSTATUSACTIVE      string = "active"
+	USERSTATUSDEACTIVATED string = "deactivated"
+)
+
+func ValidateUserStatus(status string) bool {
+	return status == "" || status == USERSTATUSACTIVE || status == USERSTATUSDEACTIVATED
+}
+
+type UserRoleUpdateSchema struct {
+	Type string `json:"type" form:"type"`
+}
+
+type AdminUserSchema struct {
+	Id                    int64      `json:"id"`
+	FirstName             string     `json:"first_name"`
+	LastName              string     `json:"last_name"`
+	Email                 string     `json:"email"`
+	Type                  string     `json:"type"`
+	Active                bool       `json:"active"`
+	DeactivatedAt         *time.Time `json:"deactivated_at"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+const adminUserColumns = `u.id,
+							u.first_name,
+							u.last_name,
+							u.email,
+							u.type,
+							u.deactivated_at,
+							u.password_reset_required,
+							u.created_at`
+
+func scanAdminUser(row pgx.Row, userData *AdminUserSchema, extra ...any) error {
+	var userType int
+	dest := []any{
+		&userData.Id,
+		&userData.FirstName,
+		&userData.LastName,
+		&userData.Email,
+		&userType,
+		&userData.DeactivatedAt,
+		&userData.PasswordResetRequired,
+		&userData.CreatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	userData.Type = ValidateUserType(userType)
+	userData.Active = userData.DeactivatedAt == nil
+	return nil
+}
+
+// FetchUserById returns any account, including deactivated ones, for admin
+// views. A zero Id means the user does not exist.
+func FetchUserById(ctx context.Context, uuidString string, userId int64) (AdminUserSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch user details ", zap.Int64("userId", userId), zap.String("requestId", uuidString))
+
+	var userData AdminUserSchema
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return userData, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT
+							` + adminUserColumns + `
+							FROM users u
+							WHERE u.id=$1 LIMIT 1`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+	err = scanAdminUser(tx.QueryRow(ctx, query, userId), &userData)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("query", query))
+			return userData, nil
+		}
+		logger.Logger.Error("MODELS :: Error while executing query.",
+			zap.Error(err),
+		)
+		return userData, err
+	}
+	return userData, nil
+}
+
+// SearchUsers lists accounts matching a free text search on name and email,
+// optionally narrowed down by user type and account status.
+func SearchUsers(ctx context.Context, uuidString string, search string, userType int, status string, limit int, offset int) ([]AdminUserSchema, int, error) {
+	logger.Logger.Info("MODELS :: Will search users ", zap.String("requestId", uuidString), zap.String("search", search), zap.Int("userType", userType), zap.String("status", status))
+
+	var data []AdminUserSchema
+	var count int
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, count, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// Escape LIKE wildcards so the search term is matched literally.
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search) + "%"
+
+	query := `SELECT
+							` + adminUserColumns + `,
+							COUNT(*) OVER() AS total
+							FROM users u
+							WHERE ($1 = '' OR u.email ILIKE $2 OR u.first_name ILIKE $2 OR u.last_name ILIKE $2)
+								AND ($3 = 0 OR u.type = $3)
+								AND ($4 = '' OR ($4 = 'active' AND u.deactivated_at IS NULL) OR ($4 = 'deactivated' AND u.deactivated_at IS NOT NULL))
+							ORDER BY u.id DESC LIMIT $5 OFFSET $6`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, search, pattern, userType, status, limit, offset)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while searching users", zap.String("requestId", uuidString), zap.String("query", query), zap.Error(err))
+		return data, count, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData AdminUserSchema
+		err = scanAdminUser(rows, &singleData, &count)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, count, err
+		}
+
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, count, err
+	}
+
+	return data, count, nil
+}
+
+// CountActiveUsersOfType is used to make sure the first admin is only
+// bootstrapped once.
+func CountActiveUsersOfType(ctx context.Context, uuidString string, userType int) (int64, error) {
+	var count int64
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return count, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT COUNT(*) FROM users u WHERE u.type = $1 AND u.deactivated_at IS NULL`
+	err = tx.QueryRow(ctx, query, userType).Scan(&count)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while executing query.", zap.String("requestId", uuidString), zap.Error(err))
+		return count, err
+	}
+	return count, nil
+}
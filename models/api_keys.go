@@ -0,0 +1,187 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	APIKEYPREFIX string = "olms"
+)
+
+type APIKeySchema struct {
+	Id         int64        `json:"id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []Permission `json:"scopes"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	LastUsedIp string       `json:"last_used_ip"`
+}
+
+type APIKeyCreateSchema struct {
+	Name          string       `json:"name" form:"name"`
+	Scopes        []Permission `json:"scopes" form:"scopes"`
+	ExpiresInDays int          `json:"expires_in_days" form:"expires_in_days"`
+}
+
+// APIKeyOwnerSchema is the key and owner found when authenticating a request.
+type APIKeyOwnerSchema struct {
+	Id     int64
+	Prefix string
+	UserId int64
+	Email  string
+	Scopes []Permission
+}
+
+// CreateAPIKey stores a new key for the user. Only the hash of the key is kept.
+func CreateAPIKey(ctx context.Context, uuidString string, userId int64, name string, prefix string, keyHash string, scopes []Permission, expiresAt time.Time) (int64, error) {
+	query := `INSERT INTO
+				api_keys
+					(user_id, name, prefix, key_hash, scopes, expires_at)
+				VALUES
+					($1, $2, $3, $4, $5, $6)
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	return queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userId, name, prefix, keyHash, scopes, expiresAt)
+}
+
+// AuthenticateAPIKey looks up an active, unexpired key of an active user by
+// its hash and records its use. It returns pgx.ErrNoRows for any other key.
+func AuthenticateAPIKey(ctx context.Context, uuidString string, keyHash string, clientIp string) (APIKeyOwnerSchema, error) {
+	var data APIKeyOwnerSchema
+	dbConnection := conn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `UPDATE
+				api_keys k
+					SET last_used_at=NOW(), last_used_ip=$2
+				FROM users u
+				WHERE k.key_hash=$1 AND k.user_id=u.id
+					AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())
+					AND u.deactivated_at IS NULL
+				RETURNING k.id, k.prefix, k.user_id, u.email, k.scopes`
+	err := dbConnection.QueryRow(ctx, query, keyHash, clientIp).Scan(
+		&data.Id,
+		&data.Prefix,
+		&data.UserId,
+		&data.Email,
+		&data.Scopes,
+	)
+	if err != nil && err != pgx.ErrNoRows {
+		logger.Logger.Error("MODELS :: Error while authenticating api key.", zap.String("requestId", uuidString), zap.Error(err))
+	}
+	return data, err
+}
+
+// FetchUserAPIKeys lists the user's keys that are neither revoked nor expired.
+func FetchUserAPIKeys(ctx context.Context, uuidString string, userId int64) ([]APIKeySchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch user api keys", zap.String("requestId", uuidString), zap.Int64("userId", userId))
+
+	var data []APIKeySchema
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT
+					k.id,
+					k.name,
+					k.prefix,
+					k.scopes,
+					k.created_at,
+					k.expires_at,
+					k.last_used_at,
+					k.last_used_ip
+				FROM api_keys k
+				WHERE k.user_id=$1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW())
+				ORDER BY k.created_at DESC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching api keys", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData APIKeySchema
+		err = rows.Scan(
+			&singleData.Id,
+			&singleData.Name,
+			&singleData.Prefix,
+			&singleData.Scopes,
+			&singleData.CreatedAt,
+			&singleData.ExpiresAt,
+			&singleData.LastUsedAt,
+			&singleData.LastUsedIp,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, err
+		}
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	return data, nil
+}
+
+// RevokeAPIKey revokes one key of the user. It returns 0 when the user has no
+// such active key.
+// RevokeUserAPIKeys revokes every active key of a user and returns how many
+// were revoked.
+func RevokeUserAPIKeys(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	logger.Logger.Info("MODELS :: Will revoke user api keys", zap.String("requestId", uuidString), zap.Int64("userId", userId))
+
+	dbConnection := writeConn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `UPDATE
+				api_keys
+					SET revoked_at=NOW()
+				WHERE user_id=$1 AND revoked_at IS NULL`
+	tag, err := dbConnection.Exec(ctx, query, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while revoking api keys.", zap.String("requestId", uuidString), zap.Error(err))
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func RevokeAPIKey(ctx context.Context, uuidString string, userId int64, apiKeyId int64) (int64, error) {
+	query := `UPDATE
+				api_keys
+					SET revoked_at=NOW()
+				WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, apiKeyId, userId)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
@@ -9,32 +9,35 @@ import (
 	"go.uber.org/zap"
 )
 
+// Statement is one SQL statement together with the arguments bound to its
+// placeholders. Values are never formatted into the SQL text itself.
+type Statement struct {
+	Query string
+	Args  []any
+}
+
 type QueryStructToExecute struct {
 	Query     string
-	QueryList []string
+	QueryList []Statement
 }
 
-func (query QueryStructToExecute) InsertOrUpdateOperations(uuidString string, args ...any) (int64, error) {
-	logger.Logger.Info("MODELS :: Will do insert operations", zap.String("requestId", uuidString), zap.String("query", query.Query), zap.Any("args", args))
+// InsertOrUpdateOperations runs one statement returning an id. Its arguments
+// are not logged: they include password hashes, token hashes and secrets.
+func (query QueryStructToExecute) InsertOrUpdateOperations(ctx context.Context, uuidString string, args ...any) (int64, error) {
+	logger.Logger.Info("MODELS :: Will do insert operations", zap.String("requestId", uuidString), zap.String("query", query.Query), zap.Int("args", len(args)))
 
 	var id int64
-	dbConnection := DbPool()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
 		return id, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
-		}
-	}()
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
 
 	err = tx.QueryRow(ctx, query.Query, args...).Scan(&id)
 	if err != nil {
@@ -44,57 +47,58 @@ func (query QueryStructToExecute) InsertOrUpdateOperations(uuidString string, ar
 		)
 		return id, err
 	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return id, err
+	}
 
 	return id, nil
 }
 
-func (query QueryStructToExecute) DeleteOperation(uuidString string) (bool, error) {
-	logger.Logger.Info("MODELS :: Will do delete operation.", zap.String("requestId", uuidString), zap.String("query", query.Query))
-	dbConnection := DbPool()
+func (query QueryStructToExecute) DeleteOperation(ctx context.Context, uuidString string, args ...any) (bool, error) {
+	logger.Logger.Info("MODELS :: Will do delete operation.", zap.String("requestId", uuidString), zap.String("query", query.Query), zap.Int("args", len(args)))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
 		return false, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
-		}
-	}()
-	tx.Exec(ctx, query.Query)
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, query.Query, args...)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while executing query.",
+			zap.String("requestId", uuidString),
+			zap.Error(err),
+		)
+		return false, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
 	return true, nil
 }
 
-func (query QueryStructToExecute) InsertOrUpdateMultipleQueries(uuidString string) (int64, error) {
-	logger.Logger.Info("MODELS :: Will do insert/update multiple operations", zap.String("requestId", uuidString))
+func (query QueryStructToExecute) InsertOrUpdateMultipleQueries(ctx context.Context, uuidString string) (int64, error) {
+	logger.Logger.Info("MODELS :: Will do insert/update multiple operations", zap.String("requestId", uuidString), zap.Int("statements", len(query.QueryList)))
 
 	var id int64
-	dbConnection := DbPool()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
 		return id, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
-		}
-	}()
+	defer tx.Rollback(ctx)
 
-	for _, singleQuery := range query.QueryList {
-		err = tx.QueryRow(ctx, singleQuery).Scan(&id)
+	for _, statement := range query.QueryList {
+		err = tx.QueryRow(ctx, statement.Query, statement.Args...).Scan(&id)
 		if err != nil {
 			logger.Logger.Error("MODELS :: Error while executing query.",
 				zap.String("requestId", uuidString),
@@ -103,6 +107,10 @@ func (query QueryStructToExecute) InsertOrUpdateMultipleQueries(uuidString strin
 			return id, err
 		}
 	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return id, err
+	}
 
 	return id, nil
 }
@@ -2,16 +2,9 @@ package models
 
 import (
 	"context"
-	"database/sql"
-	"flag"
-	"os"
-	"strings"
+	"fmt"
 	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -22,87 +15,82 @@ import (
 	"go.uber.org/zap"
 )
 
-var DBPoolConnection *pgxpool.Pool
+const (
+	DBPINGTIMEOUT     time.Duration = 5 * time.Second
+	DBRETRYBACKOFF    time.Duration = 1 * time.Second
+	DBRETRYMAXBACKOFF time.Duration = 10 * time.Second
+)
 
-func CreateConnection() {
-	databaseString := core.Config.DBString
-	connConf, err := pgxpool.ParseConfig(databaseString)
-	if err != nil {
-		logger.Logger.Error("DATABASE :: Could not able to parse db config.", zap.Error(err))
-	}
-	connConf.MaxConnIdleTime = 30 * time.Second
-	connConf.HealthCheckPeriod = 5 * time.Second
-	connConf.MaxConnLifetime = 300 * time.Second
-	connConf.MinConns = 20
-	connConf.MaxConns = 100
-	connConf.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol // For pgbouncer
-	connConf.ConnConfig.DescriptionCacheCapacity = 1024                        // For pgbouncer
+var DBPoolConnection *pgxpool.Pool
 
-	dbpool, err := pgxpool.NewWithConfig(context.Background(), connConf)
+// CreateConnection creates the pool of the primary and makes sure the database
+// answers, retrying with backoff up to DBConnectAttempts times, so the process
+// does not start serving without a database. Replicas are optional and are
+// only health checked.
+func CreateConnection() error {
+	dbpool, err := newPool(core.Config.DBString)
 	if err != nil {
 		logger.Logger.Error("DATABASE :: Could not create pool.", zap.Error(err))
+		return err
+	}
+	if err := pingDatabase(dbpool, core.Config.DBConnectAttempts); err != nil {
+		logger.Logger.Error("DATABASE :: Database is not reachable.", zap.Error(err))
+		dbpool.Close()
+		return err
 	}
 	DBPoolConnection = dbpool
-}
 
-func DbPool() *pgxpool.Pool {
-	return DBPoolConnection
+	createReplicaPools(core.Config.DBReplicaStrings, time.Duration(core.Config.DBReplicaMaxLagSeconds)*time.Second)
+	return nil
 }
 
-func RunMigrations() {
-	environment := core.Config.Environment
-	databaseString := core.Config.DBString
-
-	// Add connection timeout
-	queryParamExists := strings.Contains(databaseString, "?")
-	if queryParamExists {
-		databaseString = databaseString + "&connect_timeout=30"
-	} else {
-		databaseString = databaseString + "?connect_timeout=30"
+// CloseConnections stops the replica health checks and closes every pool,
+// waiting for acquired connections to be released.
+func CloseConnections() {
+	closeReplicaPools()
+	if DBPoolConnection != nil {
+		DBPoolConnection.Close()
 	}
+	logger.Logger.Info("DATABASE :: Connections closed")
+}
 
-	logger.Logger.Info("DATABASE :: run migrations ", zap.Any("environment", environment))
-	var migrationFilesLocation string
-	if environment == "local" || environment == "" {
-
-		cwd, err := os.Getwd()
-		if err != nil {
-			logger.Logger.Error("DATABASE :: directory not found", zap.Error(err))
+func pingDatabase(pool *pgxpool.Pool, attempts int) error {
+	backoff := DBRETRYBACKOFF
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), DBPINGTIMEOUT)
+		err := pool.Ping(ctx)
+		cancel()
+		if err == nil {
+			logger.Logger.Info("DATABASE :: Connected", zap.Int("attempt", attempt))
+			return nil
 		}
-		migrationFilesLocation = cwd + "/migrations"
-	} else {
-		migrationFilesLocation = "/usr/bin/migrations"
-	}
-	logger.Logger.Info("DATABASE :: SQL will open.", zap.Any("migration location", migrationFilesLocation))
-	db1, err := sql.Open("pgx", databaseString)
-	if err != nil {
-		logger.Logger.Error("DATABASE :: DB is not initialized", zap.Error(err))
-	}
-	logger.Logger.Info("DATABASE :: SQL opened", zap.Any("db1", db1), zap.Any("db connector", db1.Ping()))
-
-	driver, err := postgres.WithInstance(db1, &postgres.Config{})
-	if err != nil {
-		logger.Logger.Error("DATABASE :: Error while setup db driver", zap.Error(err))
-		return
+		if attempt >= attempts {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+		logger.Logger.Warn("DATABASE :: Database not reachable, retrying", zap.Int("attempt", attempt), zap.Duration("retryIn", backoff), zap.Error(err))
+		time.Sleep(backoff)
+		backoff = min(2*backoff, DBRETRYMAXBACKOFF)
 	}
-	logger.Logger.Info("DATABASE :: driver setup", zap.Any("driver", driver))
-	migrationDir := flag.String("migration.files", "./migrations", "./migrations")
-	logger.Logger.Info("DATABASE :: Migration dir", zap.String("dir", *migrationDir), zap.String("path", migrationFilesLocation))
+}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file:///"+migrationFilesLocation,
-		"postgres", driver)
+func newPool(databaseString string) (*pgxpool.Pool, error) {
+	connConf, err := pgxpool.ParseConfig(databaseString)
 	if err != nil {
-		logger.Logger.Error("DATABASE :: Error for new with db instance", zap.Error(err))
-		return
+		logger.Logger.Error("DATABASE :: Could not able to parse db config.", zap.Error(err))
+		return nil, err
 	}
+	connConf.MaxConnIdleTime = 30 * time.Second
+	connConf.HealthCheckPeriod = 5 * time.Second
+	connConf.MaxConnLifetime = 300 * time.Second
+	connConf.MinConns = 20
+	connConf.MaxConns = 100
+	connConf.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol // For pgbouncer
+	connConf.ConnConfig.DescriptionCacheCapacity = 1024                        // For pgbouncer
+	connConf.ConnConfig.Tracer = queryTracer{}
 
-	m.Up()
-	logger.Logger.Info("DATABASE :: Up command executed.")
+	return pgxpool.NewWithConfig(context.Background(), connConf)
+}
 
-	err = driver.Close()
-	if err != nil {
-		logger.Logger.Error("DATABASE :: Error while closing connection ", zap.Error(err))
-	}
-	logger.Logger.Info("DATABASE :: Driver connection closed successfully.")
+func DbPool() *pgxpool.Pool {
+	return DBPoolConnection
 }
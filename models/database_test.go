@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+// connectTestDatabase connects to the database of TEST_DB_STRING and migrates
+// it to the latest version. Tests that need Postgres are skipped without it.
+func connectTestDatabase(t *testing.T) {
+	t.Helper()
+	databaseString := os.Getenv("TEST_DB_STRING")
+	if databaseString == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+	core.Config.DBString = databaseString
+	core.Config.DBConnectAttempts = 1
+	core.Config.Environment = "local"
+
+	// The migrations are looked up relative to the working directory.
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	err = MigrateUp()
+	if chdirErr := os.Chdir(cwd); chdirErr != nil {
+		t.Fatal(chdirErr)
+	}
+	if err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	if err := CreateConnection(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(CloseConnections)
+}
+
+// hostileStrings are values that break SQL built by formatting strings. The
+// models must store and return them unchanged.
+var hostileStrings = []string{
+	`o'brien`,
+	`"double" and 'single' quotes`,
+	`'; DROP TABLE users; --`,
+	`' OR '1'='1`,
+	`back\slash \' escaped quote`,
+	`$1 $$dollar$$ %s %v`,
+	"new\nline\ttab",
+}
+
+// uniqueEmail returns an email, with local part local, that no earlier run
+// of the tests used.
+func uniqueEmail(local string) string {
+	return fmt.Sprintf("%s-%d@example.com", local, time.Now().UnixNano())
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	INTEGRITYEVENTTABBLUR        string = "tab_blur"
+	INTEGRITYEVENTTABFOCUS       string = "tab_focus"
+	INTEGRITYEVENTCOPY           string = "copy"
+	INTEGRITYEVENTPASTE          string = "paste"
+	INTEGRITYEVENTFULLSCREENEXIT string = "fullscreen_exit"
+	INTEGRITYEVENTIPCHANGE       string = "ip_change"
+)
+
+func ValidateIntegrityEventType(eventType string) string {
+	switch eventType {
+	case INTEGRITYEVENTTABBLUR, INTEGRITYEVENTTABFOCUS, INTEGRITYEVENTCOPY, INTEGRITYEVENTPASTE, INTEGRITYEVENTFULLSCREENEXIT, INTEGRITYEVENTIPCHANGE:
+		return eventType
+	default:
+		return ""
+	}
+}
+
+type IntegrityEventCreateSchema struct {
+	Type    string                 `json:"type"`
+	Details map[string]interface{} `json:"details"`
+}
+
+type IntegrityReportSchema struct {
+	UserId              int64    `json:"user_id"`
+	FirstName           string   `json:"first_name"`
+	LastName            string   `json:"last_name"`
+	Email               string   `json:"email"`
+	TabBlurCount        int64    `json:"tab_blur_count"`
+	CopyCount           int64    `json:"copy_count"`
+	PasteCount          int64    `json:"paste_count"`
+	FullscreenExitCount int64    `json:"fullscreen_exit_count"`
+	SuspiciousEvents    int64    `json:"suspicious_events"`
+	DistinctIPCount     int64    `json:"distinct_ip_count"`
+	IPChanges           int64    `json:"ip_changes"`
+	AnsweredQuestions   int64    `json:"answered_questions"`
+	CorrectAnswers      int64    `json:"correct_answers"`
+	Flagged             bool     `json:"flagged"`
+	FlagReasons         []string `json:"flag_reasons"`
+}
+
+// ApplyFlags sets IPChanges from the distinct addresses and the ip_change
+// events the client reported, and flags the student when the suspicious events
+// or IP changes exceed the limits.
+func (data *IntegrityReportSchema) ApplyFlags(reportedIPChanges int64, maxEvents int64, maxIPChanges int64) {
+	data.IPChanges = max(data.DistinctIPCount-1, reportedIPChanges, 0)
+	data.FlagReasons = make([]string, 0)
+	if data.SuspiciousEvents > maxEvents {
+		data.FlagReasons = append(data.FlagReasons, fmt.Sprintf("suspicious events %d exceed %d", data.SuspiciousEvents, maxEvents))
+	}
+	if data.IPChanges > maxIPChanges {
+		data.FlagReasons = append(data.FlagReasons, fmt.Sprintf("ip changes %d exceed %d", data.IPChanges, maxIPChanges))
+	}
+	data.Flagged = len(data.FlagReasons) > 0
+}
+
+func (data IntegrityEventCreateSchema) Insert(ctx context.Context, uuidString string, testId int64, userId int64, clientIp string, userAgent string) (int64, error) {
+	if data.Details == nil {
+		data.Details = map[string]interface{}{}
+	}
+	details, err := json.Marshal(data.Details)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while json marshalling integrity event details ", zap.String("requestId", uuidString), zap.Error(err))
+		return 0, err
+	}
+	query := `INSERT INTO
+				integrity_events
+					(test_id, user_id, event_type, details, client_ip, user_agent)
+				VALUES
+					($1, $2, $3, $4, $5, $6)
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, testId, userId, data.Type, string(details), clientIp, userAgent)
+	return id, err
+}
+
+// FetchIntegrityReport returns one row per student who answered or reported
+// events in the test. IP addresses are collected from both the integrity events
+// and the answer submissions, so IP changes are detected even when the client
+// does not report them itself.
+func FetchIntegrityReport(ctx context.Context, uuidString string, testId int64, maxEvents int64, maxIPChanges int64) ([]IntegrityReportSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch integrity report ", zap.Int64("testId", testId), zap.String("requestId", uuidString))
+
+	data := make([]IntegrityReportSchema, 0)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `WITH participants AS (
+					SELECT s.user_id FROM test_question_submissions s WHERE s.test_id = $1
+					UNION
+					SELECT ie.user_id FROM integrity_events ie WHERE ie.test_id = $1
+				),
+				event_counts AS (
+					SELECT
+						ie.user_id,
+						COUNT(*) FILTER (WHERE ie.event_type = 'tab_blur') AS tab_blur,
+						COUNT(*) FILTER (WHERE ie.event_type = 'copy') AS copy,
+						COUNT(*) FILTER (WHERE ie.event_type = 'paste') AS paste,
+						COUNT(*) FILTER (WHERE ie.event_type = 'fullscreen_exit') AS fullscreen_exit,
+						COUNT(*) FILTER (WHERE ie.event_type = 'ip_change') AS ip_change,
+						COUNT(*) FILTER (WHERE ie.event_type <> 'tab_focus') AS suspicious
+					FROM integrity_events ie
+					WHERE ie.test_id = $1
+					GROUP BY ie.user_id
+				),
+				ips AS (
+					SELECT x.user_id, COUNT(DISTINCT x.client_ip) AS ip_count
+					FROM (
+						SELECT ie.user_id, ie.client_ip FROM integrity_events ie WHERE ie.test_id = $1 AND ie.client_ip <> ''
+						UNION ALL
+						SELECT se.user_id, se.client_ip FROM submission_events se WHERE se.test_id = $1 AND se.client_ip <> ''
+					) x
+					GROUP BY x.user_id
+				),
+				results AS (
+					SELECT
+						s.user_id,
+						COUNT(*) AS answered,
+						COUNT(*) FILTER (WHERE s.answer_status) AS correct
+					FROM test_question_submissions s
+					WHERE s.test_id = $1
+					GROUP BY s.user_id
+				)
+				SELECT
+					p.user_id,
+					u.first_name,
+					u.last_name,
+					u.email,
+					COALESCE(ec.tab_blur, 0),
+					COALESCE(ec.copy, 0),
+					COALESCE(ec.paste, 0),
+					COALESCE(ec.fullscreen_exit, 0),
+					COALESCE(ec.ip_change, 0),
+					COALESCE(ec.suspicious, 0),
+					COALESCE(ips.ip_count, 0),
+					COALESCE(r.answered, 0),
+					COALESCE(r.correct, 0)
+				FROM participants p
+				JOIN users u on u.id = p.user_id
+				LEFT JOIN event_counts ec on ec.user_id = p.user_id
+				LEFT JOIN ips on ips.user_id = p.user_id
+				LEFT JOIN results r on r.user_id = p.user_id
+				ORDER BY p.user_id ASC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, testId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching integrity report", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData IntegrityReportSchema
+		var reportedIPChanges int64
+		err = rows.Scan(
+			&singleData.UserId,
+			&singleData.FirstName,
+			&singleData.LastName,
+			&singleData.Email,
+			&singleData.TabBlurCount,
+			&singleData.CopyCount,
+			&singleData.PasteCount,
+			&singleData.FullscreenExitCount,
+			&reportedIPChanges,
+			&singleData.SuspiciousEvents,
+			&singleData.DistinctIPCount,
+			&singleData.AnsweredQuestions,
+			&singleData.CorrectAnswers,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, err
+		}
+
+		singleData.ApplyFlags(reportedIPChanges, maxEvents, maxIPChanges)
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	LOGINTHROTTLEACCOUNT string = "account"
+	LOGINTHROTTLEIP      string = "ip"
+)
+
+// LoginThrottlePolicy decides how long a key is blocked after a failed login.
+type LoginThrottlePolicy struct {
+	AccountMaxFailures  int
+	AccountBackoffAfter int
+	IPMaxFailures       int
+	IPBackoffAfter      int
+	BackoffBase         time.Duration
+	BackoffMax          time.Duration
+	Lockout             time.Duration
+	Window              time.Duration
+}
+
+// Block returns how long a key with the given number of recent failures is
+// blocked, and whether that is a lockout.
+func (policy LoginThrottlePolicy) Block(scope string, failures int) (time.Duration, bool) {
+	maxFailures, backoffAfter := policy.AccountMaxFailures, policy.AccountBackoffAfter
+	if scope == LOGINTHROTTLEIP {
+		maxFailures, backoffAfter = policy.IPMaxFailures, policy.IPBackoffAfter
+	}
+	if failures >= maxFailures {
+		return policy.Lockout, true
+	}
+	if failures <= backoffAfter {
+		return 0, false
+	}
+	delay := policy.BackoffBase
+	for i := backoffAfter + 1; i < failures && delay < policy.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > policy.BackoffMax {
+		delay = policy.BackoffMax
+	}
+	return delay, false
+}
+
+type LoginLockoutSchema struct {
+	Id          int64      `json:"id"`
+	Scope       string     `json:"scope"`
+	Key         string     `json:"key"`
+	UserId      *int64     `json:"user_id"`
+	ClientIp    string     `json:"client_ip"`
+	Failures    int        `json:"failures"`
+	LockedAt    time.Time  `json:"locked_at"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at"`
+	UnlockedBy  *int64     `json:"unlocked_by"`
+}
+
+// ReserveLoginAttempt counts a login attempt against the account and the
+// client IP before its credentials are checked, and blocks them according to
+// the policy. It returns until when they are blocked, the zero time when the
+// attempt may go ahead, and whether a lockout started. The upserts lock both
+// counters until the block is written, so concurrent attempts cannot all pass
+// before the first one is counted. A refused attempt is not counted.
+func ReserveLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string, policy LoginThrottlePolicy) (time.Time, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return time.Time{}, false, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	countQuery := `INSERT INTO
+						login_throttles
+							(scope, key, failures)
+						VALUES
+							($1, $2, 1)
+						ON CONFLICT (scope, key) DO UPDATE
+							SET failures=CASE
+									WHEN login_throttles.blocked_until > NOW() THEN login_throttles.failures
+									WHEN login_throttles.last_failure_at < NOW() - make_interval(secs => $3) THEN 1
+									ELSE login_throttles.failures + 1
+								END,
+								last_failure_at=CASE
+									WHEN login_throttles.blocked_until > NOW() THEN login_throttles.last_failure_at
+									ELSE NOW()
+								END
+						RETURNING failures, blocked_until`
+	blockQuery := `UPDATE
+						login_throttles
+							SET blocked_until=NOW() + make_interval(secs => $3)
+						WHERE scope=$1 AND key=$2`
+	auditQuery := `INSERT INTO
+						login_lockouts
+							(scope, key, user_id, client_ip, failures, locked_until)
+						VALUES
+							($1, $2, (SELECT u.id FROM users u WHERE $1 = 'account' AND LOWER(u.email) = $2 LIMIT 1), $3, $4, NOW() + make_interval(secs => $5))`
+
+	lockedOut := false
+	for _, throttle := range [][2]string{{LOGINTHROTTLEACCOUNT, accountKey}, {LOGINTHROTTLEIP, clientIp}} {
+		scope, key := throttle[0], throttle[1]
+
+		var failures int
+		var blockedUntil time.Time
+		err = tx.QueryRow(ctx, countQuery, scope, key, policy.Window.Seconds()).Scan(&failures, &blockedUntil)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while counting login attempt.", zap.String("requestId", uuidString), zap.Error(err))
+			return time.Time{}, false, err
+		}
+		if blockedUntil.After(time.Now()) {
+			// Rolled back, so neither counter keeps the refused attempt.
+			return blockedUntil, false, nil
+		}
+
+		block, lockout := policy.Block(scope, failures)
+		if block <= 0 {
+			continue
+		}
+		_, err = tx.Exec(ctx, blockQuery, scope, key, block.Seconds())
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while blocking login.", zap.String("requestId", uuidString), zap.Error(err))
+			return time.Time{}, false, err
+		}
+		if !lockout {
+			continue
+		}
+
+		lockedOut = true
+		logger.Logger.Warn("MODELS :: Login locked out", zap.String("requestId", uuidString), zap.String("scope", scope), zap.String("key", key), zap.Int("failures", failures))
+		_, err = tx.Exec(ctx, auditQuery, scope, key, clientIp, failures, block.Seconds())
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while recording lockout.", zap.String("requestId", uuidString), zap.Error(err))
+			return time.Time{}, false, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return time.Time{}, false, err
+	}
+	return time.Time{}, lockedOut, nil
+}
+
+// ReleaseLoginAttempt uncounts an attempt reserved by ReserveLoginAttempt
+// whose credentials turned out to be right. A block the attempt started is
+// left to expire.
+func ReleaseLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string) error {
+	dbConnection := writeConn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `UPDATE
+					login_throttles
+						SET failures=GREATEST(failures - 1, 0)
+					WHERE (scope=$1 AND key=$2) OR (scope=$3 AND key=$4)`
+	_, err := dbConnection.Exec(ctx, query, LOGINTHROTTLEACCOUNT, accountKey, LOGINTHROTTLEIP, clientIp)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while releasing login attempt.", zap.String("requestId", uuidString), zap.Error(err))
+	}
+	return err
+}
+
+// ClearAccountLoginFailures forgets the failed logins of an account after a
+// successful one. IP counters are left to expire, so one known password does
+// not reset the limit for a whole address.
+func ClearAccountLoginFailures(ctx context.Context, uuidString string, accountKey string) error {
+	dbConnection := writeConn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err := dbConnection.Exec(ctx, `DELETE FROM login_throttles WHERE scope=$1 AND key=$2`, LOGINTHROTTLEACCOUNT, accountKey)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while clearing login failures.", zap.String("requestId", uuidString), zap.Error(err))
+	}
+	return err
+}
+
+// UnlockAccountLogin lifts the block of an account and records who did it on
+// its open lockouts. It reports whether the account was blocked.
+func UnlockAccountLogin(ctx context.Context, uuidString string, userId int64, accountKey string, unlockedBy int64) (bool, error) {
+	logger.Logger.Info("MODELS :: Will unlock account login", zap.String("requestId", uuidString), zap.Int64("userId", userId), zap.Int64("unlockedBy", unlockedBy))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return false, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	var blocked bool
+	err = tx.QueryRow(ctx, `DELETE FROM login_throttles WHERE scope=$1 AND key=$2 RETURNING blocked_until > NOW()`, LOGINTHROTTLEACCOUNT, accountKey).Scan(&blocked)
+	if err == pgx.ErrNoRows {
+		err = nil
+	} else if err != nil {
+		logger.Logger.Error("MODELS :: Error while clearing login throttle.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	auditQuery := `UPDATE
+						login_lockouts
+							SET unlocked_at=NOW(), unlocked_by=$3
+						WHERE scope=$1 AND (key=$2 OR user_id=$4) AND unlocked_at IS NULL AND locked_until > NOW()`
+	_, err = tx.Exec(ctx, auditQuery, LOGINTHROTTLEACCOUNT, accountKey, unlockedBy, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while recording unlock.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return blocked, err
+	}
+	return blocked, nil
+}
+
+// FetchLoginLockouts lists lockouts, newest first, optionally for one user.
+func FetchLoginLockouts(ctx context.Context, uuidString string, userId int64, limit int, offset int) ([]LoginLockoutSchema, int, error) {
+	logger.Logger.Info("MODELS :: Will fetch login lockouts", zap.String("requestId", uuidString), zap.Int64("userId", userId))
+
+	var data []LoginLockoutSchema
+	var count int
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, count, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	query := `SELECT
+					l.id,
+					l.scope,
+					l.key,
+					l.user_id,
+					l.client_ip,
+					l.failures,
+					l.locked_at,
+					l.locked_until,
+					l.unlocked_at,
+					l.unlocked_by,
+					COUNT(*) OVER() AS total
+				FROM login_lockouts l
+				WHERE ($1 = 0 OR l.user_id = $1)
+				ORDER BY l.locked_at DESC, l.id DESC LIMIT $2 OFFSET $3`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, userId, limit, offset)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching login lockouts", zap.String("requestId", uuidString), zap.Error(err))
+		return data, count, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData LoginLockoutSchema
+		err = rows.Scan(
+			&singleData.Id,
+			&singleData.Scope,
+			&singleData.Key,
+			&singleData.UserId,
+			&singleData.ClientIp,
+			&singleData.Failures,
+			&singleData.LockedAt,
+			&singleData.LockedUntil,
+			&singleData.UnlockedAt,
+			&singleData.UnlockedBy,
+			&count,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, count, err
+		}
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, count, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return data, count, err
+	}
+	return data, count, nil
+}
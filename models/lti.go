@@ -0,0 +1,219 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+// LTILaunchSchema is what a launch tells us about the course, the placement
+// and the launching user.
+type LTILaunchSchema struct {
+	Issuer            string
+	DeploymentId      string
+	ContextId         string
+	ContextLabel      string
+	ContextTitle      string
+	ResourceLinkId    string
+	ResourceLinkTitle string
+	TestId            int64
+	LineItemURL       string
+	UserId            int64
+	Roles             []string
+}
+
+type LTIScoreTargetSchema struct {
+	Issuer         string
+	LineItemURL    string
+	PlatformUserId string
+	UserId         int64
+	TotalQuestions int64
+	CorrectAnswers int64
+}
+
+// UseLTINonce records a launch nonce and reports whether it was unused.
+// Expired nonces are purged on the way.
+func UseLTINonce(ctx context.Context, uuidString string, nonce string, expiresAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return false, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM lti_nonces WHERE expires_at < NOW()`)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while purging lti nonces.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, `INSERT INTO lti_nonces (nonce, expires_at) VALUES ($1, $2) ON CONFLICT (nonce) DO NOTHING`, nonce, expiresAt)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while storing lti nonce.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Record provisions the course, the user's membership and the resource link
+// of a launch, and returns the test the resource link points to (0 if none).
+// A test id or line item already stored is kept when the launch has none.
+func (data LTILaunchSchema) Record(ctx context.Context, uuidString string) (int64, error) {
+	logger.Logger.Info("MODELS :: Will record lti launch", zap.String("requestId", uuidString), zap.String("issuer", data.Issuer), zap.String("contextId", data.ContextId), zap.String("resourceLinkId", data.ResourceLinkId))
+
+	var testId int64
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return testId, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	var contextPk int64
+	contextQuery := `INSERT INTO
+						lti_contexts
+							(issuer, deployment_id, context_id, label, title)
+						VALUES
+							($1, $2, $3, $4, $5)
+						ON CONFLICT (issuer, deployment_id, context_id) DO UPDATE
+							SET label=EXCLUDED.label, title=EXCLUDED.title, updated_at=NOW()
+						RETURNING id`
+	err = tx.QueryRow(ctx, contextQuery, data.Issuer, data.DeploymentId, data.ContextId, data.ContextLabel, data.ContextTitle).Scan(&contextPk)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while recording lti context.", zap.String("requestId", uuidString), zap.Error(err))
+		return testId, err
+	}
+
+	if data.Roles == nil {
+		data.Roles = make([]string, 0)
+	}
+	memberQuery := `INSERT INTO
+						lti_context_members
+							(context_id, user_id, roles)
+						VALUES
+							($1, $2, $3)
+						ON CONFLICT (context_id, user_id) DO UPDATE
+							SET roles=EXCLUDED.roles, last_launch_at=NOW()`
+	_, err = tx.Exec(ctx, memberQuery, contextPk, data.UserId, data.Roles)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while recording lti membership.", zap.String("requestId", uuidString), zap.Error(err))
+		return testId, err
+	}
+
+	if data.ResourceLinkId == "" {
+		if err = tx.Commit(ctx); err != nil {
+			logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+			return testId, err
+		}
+		return testId, nil
+	}
+	resourceLinkQuery := `INSERT INTO
+							lti_resource_links
+								(context_id, resource_link_id, title, test_id, lineitem_url)
+							VALUES
+								($1, $2, $3, NULLIF($4::BIGINT, 0), $5)
+							ON CONFLICT (context_id, resource_link_id) DO UPDATE
+								SET title=EXCLUDED.title,
+									test_id=COALESCE(EXCLUDED.test_id, lti_resource_links.test_id),
+									lineitem_url=CASE WHEN EXCLUDED.lineitem_url <> '' THEN EXCLUDED.lineitem_url ELSE lti_resource_links.lineitem_url END,
+									updated_at=NOW()
+							RETURNING COALESCE(test_id, 0)`
+	err = tx.QueryRow(ctx, resourceLinkQuery, contextPk, data.ResourceLinkId, data.ResourceLinkTitle, data.TestId, data.LineItemURL).Scan(&testId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while recording lti resource link.", zap.String("requestId", uuidString), zap.Error(err))
+		return testId, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return testId, err
+	}
+	return testId, nil
+}
+
+// FetchLTIScoreTargets lists, for every line item a test is placed at, the
+// course members who answered the test and their current score. Only answers
+// to questions still in the test count, as in the progress dashboard.
+func FetchLTIScoreTargets(ctx context.Context, uuidString string, testId int64) ([]LTIScoreTargetSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch lti score targets", zap.String("requestId", uuidString), zap.Int64("testId", testId))
+
+	var data []LTIScoreTargetSchema
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT
+					c.issuer,
+					rl.lineitem_url,
+					ui.subject,
+					m.user_id,
+					(SELECT COUNT(DISTINCT tq.question_id) FROM test_questions tq WHERE tq.test_id = rl.test_id),
+					COUNT(s.id) FILTER (WHERE s.answer_status AND EXISTS (
+						SELECT 1 FROM test_questions tq WHERE tq.test_id = s.test_id AND tq.question_id = s.question_id
+					))
+				FROM lti_resource_links rl
+				JOIN lti_contexts c ON c.id = rl.context_id
+				JOIN lti_context_members m ON m.context_id = rl.context_id
+				JOIN user_identities ui ON ui.user_id = m.user_id AND ui.issuer = c.issuer
+				JOIN test_question_submissions s ON s.test_id = rl.test_id AND s.user_id = m.user_id
+				WHERE rl.test_id = $1 AND rl.lineitem_url <> ''
+				GROUP BY c.issuer, rl.test_id, rl.lineitem_url, ui.subject, m.user_id
+				ORDER BY rl.lineitem_url, m.user_id`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, testId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching lti score targets", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData LTIScoreTargetSchema
+		err = rows.Scan(
+			&singleData.Issuer,
+			&singleData.LineItemURL,
+			&singleData.PlatformUserId,
+			&singleData.UserId,
+			&singleData.TotalQuestions,
+			&singleData.CorrectAnswers,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, err
+		}
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	return data, nil
+}
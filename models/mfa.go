@@ -0,0 +1,256 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+var ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+
+// MFAStateSchema is a user's second factor and whether their role requires
+// one. Secret is encrypted and empty when the user never enrolled.
+type MFAStateSchema struct {
+	Secret            string
+	Enabled           bool
+	Required          bool
+	RecoveryCodesLeft int64
+}
+
+type MFACodeSchema struct {
+	Code         string `json:"code" form:"code"`
+	RecoveryCode string `json:"recovery_code" form:"recovery_code"`
+}
+
+type MFALoginSchema struct {
+	MFAToken     string `json:"mfa_token" form:"mfa_token"`
+	Code         string `json:"code" form:"code"`
+	RecoveryCode string `json:"recovery_code" form:"recovery_code"`
+}
+
+type RoleSettingSchema struct {
+	Role        string    `json:"role"`
+	MFARequired bool      `json:"mfa_required"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RoleSettingUpdateSchema struct {
+	MFARequired *bool `json:"mfa_required" form:"mfa_required"`
+}
+
+// FetchMFAState loads the second factor of a user together with the setting of
+// the user's role.
+func FetchMFAState(ctx context.Context, uuidString string, userId int64, userType int) (MFAStateSchema, error) {
+	var data MFAStateSchema
+	dbConnection := conn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `SELECT
+					COALESCE(m.secret, ''),
+					m.enabled_at IS NOT NULL,
+					COALESCE(rs.mfa_required, FALSE),
+					(SELECT COUNT(*) FROM user_recovery_codes rc WHERE rc.user_id=$1 AND rc.used_at IS NULL)
+				FROM (SELECT $1::BIGINT AS user_id, $2::INT AS user_type) u
+				LEFT JOIN user_mfa m ON m.user_id = u.user_id
+				LEFT JOIN role_settings rs ON rs.user_type = u.user_type`
+	err := dbConnection.QueryRow(ctx, query, userId, userType).Scan(
+		&data.Secret,
+		&data.Enabled,
+		&data.Required,
+		&data.RecoveryCodesLeft,
+	)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching mfa state.", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	return data, nil
+}
+
+// StartMFAEnrollment stores a new, not yet enabled, secret for the user,
+// replacing any earlier unfinished enrollment. It returns ErrMFAAlreadyEnabled
+// when the user already has an enabled second factor.
+func StartMFAEnrollment(ctx context.Context, uuidString string, userId int64, encryptedSecret string) error {
+	query := `INSERT INTO
+				user_mfa
+					(user_id, secret)
+				VALUES
+					($1, $2)
+				ON CONFLICT (user_id) DO UPDATE
+					SET secret=EXCLUDED.secret, last_used_step=0, created_at=NOW()
+					WHERE user_mfa.enabled_at IS NULL
+				RETURNING user_id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	_, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userId, encryptedSecret)
+	if err == pgx.ErrNoRows {
+		return ErrMFAAlreadyEnabled
+	}
+	return err
+}
+
+// UseTOTPStep records that the code of a time step was used. It returns false
+// when that step or a later one was used before, which stops a code from being
+// replayed. With enable set it also completes a pending enrollment.
+func UseTOTPStep(ctx context.Context, uuidString string, userId int64, step int64, enable bool) (bool, error) {
+	query := `UPDATE
+				user_mfa
+					SET last_used_step=$2,
+						enabled_at=CASE WHEN enabled_at IS NULL AND $3 THEN NOW() ELSE enabled_at END
+				WHERE user_id=$1 AND last_used_step < $2 AND (enabled_at IS NOT NULL OR $3)
+				RETURNING user_id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	_, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userId, step, enable)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReplaceRecoveryCodes drops the user's recovery codes and stores new ones.
+func ReplaceRecoveryCodes(ctx context.Context, uuidString string, userId int64, codeHashes []string) error {
+	logger.Logger.Info("MODELS :: Will replace recovery codes", zap.String("requestId", uuidString), zap.Int64("userId", userId))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id=$1`, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while deleting recovery codes.", zap.String("requestId", uuidString), zap.Error(err))
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) SELECT $1, UNNEST($2::TEXT[])`, userId, codeHashes)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while storing recovery codes.", zap.String("requestId", uuidString), zap.Error(err))
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode marks one of the user's recovery codes as used. It returns
+// false when the code is unknown or was already used.
+func UseRecoveryCode(ctx context.Context, uuidString string, userId int64, codeHash string) (bool, error) {
+	query := `UPDATE
+				user_recovery_codes
+					SET used_at=NOW()
+				WHERE id=(
+					SELECT id FROM user_recovery_codes
+					WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL
+					LIMIT 1
+				) AND used_at IS NULL
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	_, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userId, codeHash)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DisableMFA removes the user's second factor and recovery codes. It returns
+// false when the user had none.
+func DisableMFA(ctx context.Context, uuidString string, userId int64) (bool, error) {
+	logger.Logger.Info("MODELS :: Will disable mfa", zap.String("requestId", uuidString), zap.Int64("userId", userId))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return false, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id=$1`, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while deleting recovery codes.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM user_mfa WHERE user_id=$1`, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while deleting mfa.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// FetchRoleSettings lists the settings of every role, with defaults for roles
+// that were never configured.
+func FetchRoleSettings(ctx context.Context, uuidString string) ([]RoleSettingSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch role settings", zap.String("requestId", uuidString))
+
+	var data []RoleSettingSchema
+	dbConnection := conn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `SELECT
+					r.user_type,
+					COALESCE(rs.mfa_required, FALSE),
+					COALESCE(rs.updated_at, 'epoch'::TIMESTAMPTZ)
+				FROM UNNEST($1::INT[]) AS r(user_type)
+				LEFT JOIN role_settings rs ON rs.user_type = r.user_type
+				ORDER BY r.user_type`
+	rows, err := dbConnection.Query(ctx, query, []int{STUDENT, TEACHER, ADMIN, TEACHINGASSISTANT})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching role settings", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			singleData RoleSettingSchema
+			userType   int
+		)
+		err = rows.Scan(&userType, &singleData.MFARequired, &singleData.UpdatedAt)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, err
+		}
+		singleData.Role = ValidateUserType(userType)
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	return data, nil
+}
+
+// UpdateRoleMFARequired turns the second factor requirement of a role on or off.
+func UpdateRoleMFARequired(ctx context.Context, uuidString string, userType int, required bool) (int64, error) {
+	query := `INSERT INTO
+				role_settings
+					(user_type, mfa_required)
+				VALUES
+					($1, $2)
+				ON CONFLICT (user_type) DO UPDATE
+					SET mfa_required=EXCLUDED.mfa_required, updated_at=NOW()
+				RETURNING user_type`
+	queryToExecute := QueryStructToExecute{Query: query}
+	return queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userType, required)
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrSchemaDirty  = errors.New("database schema is dirty: a migration failed halfway, fix it by hand and run migrate force")
+	ErrSchemaBehind = errors.New("database schema is behind the migrations of this build, run migrate up")
+)
+
+// MigrationSchema is one migration file and whether the database has it.
+type MigrationSchema struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// MigrationStatusSchema compares the database schema with the migrations
+// shipped with the build.
+type MigrationStatusSchema struct {
+	Version    uint // 0 when no migration was applied
+	Dirty      bool
+	Latest     uint
+	Migrations []MigrationSchema
+}
+
+// Pending reports whether migrations of the build were not applied yet.
+func (status MigrationStatusSchema) Pending() bool {
+	return status.Version < status.Latest
+}
+
+// migrationsLocation returns the directory of the migration files: the
+// working directory locally, the path the Dockerfile copies them to otherwise.
+func migrationsLocation() string {
+	environment := core.Config.Environment
+	if environment == "local" || environment == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			logger.Logger.Error("DATABASE :: directory not found", zap.Error(err))
+		}
+		return cwd + "/migrations"
+	}
+	return "/usr/bin/migrations"
+}
+
+// migrateLogger prints the progress of golang-migrate to the application log.
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	logger.Logger.Info("DATABASE :: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
+
+// newMigrate opens the migration files and a database connection for
+// golang-migrate. The caller must Close it.
+func newMigrate() (*migrate.Migrate, error) {
+	databaseString := core.Config.DBString
+	if strings.Contains(databaseString, "?") {
+		databaseString = databaseString + "&connect_timeout=30"
+	} else {
+		databaseString = databaseString + "?connect_timeout=30"
+	}
+
+	location := migrationsLocation()
+	logger.Logger.Info("DATABASE :: Migrations", zap.String("environment", core.Config.Environment), zap.String("path", location))
+
+	db, err := sql.Open("pgx", databaseString)
+	if err != nil {
+		logger.Logger.Error("DATABASE :: DB is not initialized", zap.Error(err))
+		return nil, err
+	}
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		logger.Logger.Error("DATABASE :: Error while setup db driver", zap.Error(err))
+		db.Close()
+		return nil, err
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://"+location, "postgres", driver)
+	if err != nil {
+		logger.Logger.Error("DATABASE :: Error for new with db instance", zap.Error(err))
+		driver.Close()
+		return nil, err
+	}
+	m.Log = migrateLogger{}
+	return m, nil
+}
+
+// runMigrate runs fn with a migrate instance and closes it. ErrNoChange is
+// not an error: the schema already is where it was asked to be.
+func runMigrate(fn func(m *migrate.Migrate) error) error {
+	m, err := newMigrate()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if sourceErr, databaseErr := m.Close(); sourceErr != nil || databaseErr != nil {
+			logger.Logger.Error("DATABASE :: Error while closing migrations", zap.NamedError("sourceError", sourceErr), zap.NamedError("databaseError", databaseErr))
+		}
+	}()
+	err = fn(m)
+	if errors.Is(err, migrate.ErrNoChange) {
+		logger.Logger.Info("DATABASE :: No migration to apply")
+		return nil
+	}
+	return err
+}
+
+// MigrateUp applies every pending migration.
+func MigrateUp() error {
+	return runMigrate(func(m *migrate.Migrate) error {
+		return m.Up()
+	})
+}
+
+// MigrateDown reverts the last steps migrations.
+func MigrateDown(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("number of migrations to revert must be positive, got %d", steps)
+	}
+	return runMigrate(func(m *migrate.Migrate) error {
+		return m.Steps(-steps)
+	})
+}
+
+// MigrateGoto migrates up or down to the given version.
+func MigrateGoto(version uint) error {
+	return runMigrate(func(m *migrate.Migrate) error {
+		return m.Migrate(version)
+	})
+}
+
+// MigrateForce records version as applied and clears the dirty flag without
+// running anything, after a failed migration was repaired by hand. Version -1
+// means no migration is applied.
+func MigrateForce(version int) error {
+	return runMigrate(func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+}
+
+// FetchMigrationStatus reads the schema version of the database and lists the
+// migrations of the build.
+func FetchMigrationStatus() (MigrationStatusSchema, error) {
+	var status MigrationStatusSchema
+	err := runMigrate(func(m *migrate.Migrate) error {
+		version, dirty, err := m.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return err
+		}
+		status.Version, status.Dirty = version, dirty
+
+		status.Migrations, err = listMigrations(migrationsLocation())
+		if err != nil {
+			return err
+		}
+		for i := range status.Migrations {
+			status.Migrations[i].Applied = status.Migrations[i].Version <= status.Version
+			status.Latest = status.Migrations[i].Version
+		}
+		return nil
+	})
+	return status, err
+}
+
+// listMigrations returns the up migrations in the directory, oldest first.
+func listMigrations(location string) ([]MigrationSchema, error) {
+	driver, err := source.Open("file://" + location)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	var migrations []MigrationSchema
+	version, err := driver.First()
+	for err == nil {
+		migration := MigrationSchema{Version: version}
+		if reader, identifier, readErr := driver.ReadUp(version); readErr == nil {
+			reader.Close()
+			migration.Name = identifier
+		}
+		migrations = append(migrations, migration)
+		version, err = driver.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+// CheckMigrations returns ErrSchemaDirty or ErrSchemaBehind unless the
+// database has every migration of the build applied cleanly. A schema ahead
+// of the build, e.g. while an older build is still rolling out, is accepted.
+func CheckMigrations() error {
+	status, err := FetchMigrationStatus()
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("%w (version %d)", ErrSchemaDirty, status.Version)
+	}
+	if status.Pending() {
+		return fmt.Errorf("%w (version %d, latest %d)", ErrSchemaBehind, status.Version, status.Latest)
+	}
+	if status.Version > status.Latest {
+		logger.Logger.Warn("DATABASE :: Schema is ahead of this build", zap.Uint("version", status.Version), zap.Uint("latest", status.Latest))
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+// Page selects one page of a list ordered by id, newest first. With After
+// set the page starts below that id (keyset pagination), which stays fast on
+// large tables and does not skip or repeat rows while rows are added;
+// otherwise Offset rows are skipped. The total number of rows is only counted
+// when WithCount is set.
+type Page struct {
+	Limit     int
+	Offset    int
+	After     int64
+	WithCount bool
+}
+
+// offset returns the OFFSET of the page; keyset pages never skip rows.
+func (p Page) offset() int {
+	if p.After > 0 {
+		return 0
+	}
+	return p.Offset
+}
+
+// countRows runs a SELECT COUNT(*) query for the total of a paged list, or
+// returns 0 when the page does not ask for it.
+func countRows(ctx context.Context, tx pgx.Tx, uuidString string, page Page, query string, args ...any) (int, error) {
+	var count int
+	if !page.WithCount {
+		return count, nil
+	}
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+	err := tx.QueryRow(ctx, query, args...).Scan(&count)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while counting rows", zap.String("requestId", uuidString), zap.String("query", query), zap.Error(err))
+	}
+	return count, err
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+type TestProgressSchema struct {
+	TestId            int64     `json:"test_id"`
+	TestTitle         string    `json:"test_title"`
+	TotalQuestions    int64     `json:"total_questions"`
+	AnsweredQuestions int64     `json:"answered_questions"`
+	CorrectAnswers    int64     `json:"correct_answers"`
+	CompletionPercent float64   `json:"completion_percentage"`
+	ScorePercent      float64   `json:"score_percentage"`
+	LastActivityAt    time.Time `json:"last_activity_at"`
+}
+
+type TagMasterySchema struct {
+	Tag               string  `json:"tag"`
+	AnsweredQuestions int64   `json:"answered_questions"`
+	CorrectAnswers    int64   `json:"correct_answers"`
+	Mastery           float64 `json:"mastery"`
+}
+
+type UserProgressSchema struct {
+	UserId int64                `json:"user_id"`
+	Tests  []TestProgressSchema `json:"tests"`
+	Tags   []TagMasterySchema   `json:"tags"`
+}
+
+func Percentage(part int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	value := float64(part) * 100 / float64(total)
+	if value > 100 {
+		return 100
+	}
+	return value
+}
+
+func FetchUserProgress(ctx context.Context, uuidString string, userId int64) (UserProgressSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch user progress ", zap.Int64("userId", userId), zap.String("requestId", uuidString))
+
+	progressData := UserProgressSchema{
+		UserId: userId,
+		Tests:  make([]TestProgressSchema, 0),
+		Tags:   make([]TagMasterySchema, 0),
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return progressData, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// Only answers to questions that are still part of the test count towards
+	// completion, so removing a question from a test never pushes a student
+	// above 100%.
+	testsQuery := `WITH question_counts AS (
+						SELECT tq.test_id, COUNT(DISTINCT tq.question_id) AS total
+						FROM test_questions tq
+						GROUP BY tq.test_id
+					)
+					SELECT
+						t.id,
+						t.title,
+						COALESCE(qc.total, 0),
+						COUNT(s.id) FILTER (WHERE EXISTS (
+							SELECT 1 FROM test_questions tq WHERE tq.test_id = s.test_id AND tq.question_id = s.question_id
+						)),
+						COUNT(s.id) FILTER (WHERE s.answer_status AND EXISTS (
+							SELECT 1 FROM test_questions tq WHERE tq.test_id = s.test_id AND tq.question_id = s.question_id
+						)),
+						MAX(s.updated_at)
+					FROM test_question_submissions s
+					JOIN tests t on t.id = s.test_id
+					LEFT JOIN question_counts qc on qc.test_id = s.test_id
+					WHERE s.user_id = $1
+					GROUP BY t.id, t.title, qc.total
+					ORDER BY MAX(s.updated_at) DESC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", testsQuery), zap.String("requestId", uuidString))
+	rows, err := tx.Query(ctx, testsQuery, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching test progress", zap.String("requestId", uuidString), zap.Error(err))
+		return progressData, err
+	}
+	for rows.Next() {
+		var singleData TestProgressSchema
+		err = rows.Scan(
+			&singleData.TestId,
+			&singleData.TestTitle,
+			&singleData.TotalQuestions,
+			&singleData.AnsweredQuestions,
+			&singleData.CorrectAnswers,
+			&singleData.LastActivityAt,
+		)
+		if err != nil {
+			rows.Close()
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return progressData, err
+		}
+		singleData.CompletionPercent = Percentage(singleData.AnsweredQuestions, singleData.TotalQuestions)
+		singleData.ScorePercent = Percentage(singleData.CorrectAnswers, singleData.TotalQuestions)
+		progressData.Tests = append(progressData.Tests, singleData)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return progressData, err
+	}
+
+	tagsQuery := `SELECT
+						tag,
+						COUNT(*),
+						COUNT(*) FILTER (WHERE s.answer_status)
+					FROM test_question_submissions s
+					JOIN questions q on q.id = s.question_id
+					CROSS JOIN LATERAL UNNEST(q.tags) AS tag
+					WHERE s.user_id = $1
+					GROUP BY tag
+					ORDER BY tag ASC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", tagsQuery), zap.String("requestId", uuidString))
+	rows, err = tx.Query(ctx, tagsQuery, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching tag mastery", zap.String("requestId", uuidString), zap.Error(err))
+		return progressData, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var singleData TagMasterySchema
+		err = rows.Scan(
+			&singleData.Tag,
+			&singleData.AnsweredQuestions,
+			&singleData.CorrectAnswers,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return progressData, err
+		}
+		singleData.Mastery = CorrectRate(singleData.CorrectAnswers, singleData.AnsweredQuestions)
+		progressData.Tags = append(progressData.Tags, singleData)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return progressData, err
+	}
+
+	return progressData, nil
+}
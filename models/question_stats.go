@@ -0,0 +1,210 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	STATSBUCKETDAY   string = "day"
+	STATSBUCKETWEEK  string = "week"
+	STATSBUCKETMONTH string = "month"
+)
+
+func ValidateStatsBucket(bucket string) string {
+	if bucket == STATSBUCKETDAY || bucket == STATSBUCKETWEEK || bucket == STATSBUCKETMONTH {
+		return bucket
+	}
+	return ""
+}
+
+type QuestionTestStatsSchema struct {
+	TestId             int64   `json:"test_id"`
+	TestTitle          string  `json:"test_title"`
+	Attempts           int64   `json:"attempts"`
+	CorrectAttempts    int64   `json:"correct_attempts"`
+	CorrectRate        float64 `json:"correct_rate"`
+	AvgSecondsToAnswer float64 `json:"avg_seconds_to_answer"`
+}
+
+type QuestionStatsBucketSchema struct {
+	Bucket             time.Time `json:"bucket"`
+	Attempts           int64     `json:"attempts"`
+	CorrectAttempts    int64     `json:"correct_attempts"`
+	CorrectRate        float64   `json:"correct_rate"`
+	AvgSecondsToAnswer float64   `json:"avg_seconds_to_answer"`
+}
+
+type QuestionStatsSchema struct {
+	QuestionId         int64                       `json:"question_id"`
+	TestCount          int64                       `json:"test_count"`
+	Attempts           int64                       `json:"attempts"`
+	CorrectAttempts    int64                       `json:"correct_attempts"`
+	CorrectRate        float64                     `json:"correct_rate"`
+	AvgSecondsToAnswer float64                     `json:"avg_seconds_to_answer"`
+	Tests              []QuestionTestStatsSchema   `json:"tests"`
+	TimeSeries         []QuestionStatsBucketSchema `json:"time_series"`
+}
+
+// questionAnswersCTE yields one row per submission of the question ($1) with the
+// seconds elapsed since the same student's previous answer in that test. The
+// first answer a student gives in a test has no predecessor and is left NULL so
+// that it does not skew the averages.
+const questionAnswersCTE = `WITH answers AS (
+								SELECT
+									s.test_id,
+									s.question_id,
+									s.answer_status,
+									s.created_at,
+									EXTRACT(EPOCH FROM s.created_at - LAG(s.created_at) OVER (
+										PARTITION BY s.test_id, s.user_id ORDER BY s.created_at
+									)) AS seconds_to_answer
+								FROM test_question_submissions s
+								WHERE s.test_id IN (
+									SELECT DISTINCT s2.test_id FROM test_question_submissions s2 WHERE s2.question_id = $1
+								)
+							)`
+
+func CorrectRate(correct int64, attempts int64) float64 {
+	if attempts == 0 {
+		return 0
+	}
+	return float64(correct) / float64(attempts)
+}
+
+func FetchQuestionStats(ctx context.Context, uuidString string, questionId int64, bucket string) (QuestionStatsSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch question stats ", zap.Int64("questionId", questionId), zap.String("bucket", bucket), zap.String("requestId", uuidString))
+
+	statsData := QuestionStatsSchema{
+		QuestionId: questionId,
+		Tests:      make([]QuestionTestStatsSchema, 0),
+		TimeSeries: make([]QuestionStatsBucketSchema, 0),
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return statsData, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	testCountQuery := `SELECT COUNT(DISTINCT tq.test_id) FROM test_questions tq WHERE tq.question_id = $1`
+	err = tx.QueryRow(ctx, testCountQuery, questionId).Scan(&statsData.TestCount)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while executing query.", zap.String("requestId", uuidString), zap.String("query", testCountQuery), zap.Error(err))
+		return statsData, err
+	}
+
+	totalQuery := questionAnswersCTE + `
+							SELECT
+								COUNT(*),
+								COUNT(*) FILTER (WHERE a.answer_status),
+								COALESCE(AVG(a.seconds_to_answer), 0)
+							FROM answers a
+							WHERE a.question_id = $1`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", totalQuery), zap.String("requestId", uuidString))
+	err = tx.QueryRow(ctx, totalQuery, questionId).Scan(
+		&statsData.Attempts,
+		&statsData.CorrectAttempts,
+		&statsData.AvgSecondsToAnswer,
+	)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while executing query.", zap.String("requestId", uuidString), zap.Error(err))
+		return statsData, err
+	}
+	statsData.CorrectRate = CorrectRate(statsData.CorrectAttempts, statsData.Attempts)
+
+	perTestQuery := questionAnswersCTE + `
+							SELECT
+								a.test_id,
+								t.title,
+								COUNT(*),
+								COUNT(*) FILTER (WHERE a.answer_status),
+								COALESCE(AVG(a.seconds_to_answer), 0)
+							FROM answers a
+							JOIN tests t on t.id = a.test_id
+							WHERE a.question_id = $1
+							GROUP BY a.test_id, t.title
+							ORDER BY a.test_id DESC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", perTestQuery), zap.String("requestId", uuidString))
+	rows, err := tx.Query(ctx, perTestQuery, questionId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching per test question stats", zap.String("requestId", uuidString), zap.Error(err))
+		return statsData, err
+	}
+	for rows.Next() {
+		var singleData QuestionTestStatsSchema
+		err = rows.Scan(
+			&singleData.TestId,
+			&singleData.TestTitle,
+			&singleData.Attempts,
+			&singleData.CorrectAttempts,
+			&singleData.AvgSecondsToAnswer,
+		)
+		if err != nil {
+			rows.Close()
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return statsData, err
+		}
+		singleData.CorrectRate = CorrectRate(singleData.CorrectAttempts, singleData.Attempts)
+		statsData.Tests = append(statsData.Tests, singleData)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return statsData, err
+	}
+
+	timeSeriesQuery := questionAnswersCTE + `
+							SELECT
+								DATE_TRUNC($2, a.created_at) AS bucket,
+								COUNT(*),
+								COUNT(*) FILTER (WHERE a.answer_status),
+								COALESCE(AVG(a.seconds_to_answer), 0)
+							FROM answers a
+							WHERE a.question_id = $1
+							GROUP BY bucket
+							ORDER BY bucket ASC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", timeSeriesQuery), zap.String("requestId", uuidString))
+	rows, err = tx.Query(ctx, timeSeriesQuery, questionId, bucket)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching question stats time series", zap.String("requestId", uuidString), zap.Error(err))
+		return statsData, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var singleData QuestionStatsBucketSchema
+		err = rows.Scan(
+			&singleData.Bucket,
+			&singleData.Attempts,
+			&singleData.CorrectAttempts,
+			&singleData.AvgSecondsToAnswer,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return statsData, err
+		}
+		singleData.CorrectRate = CorrectRate(singleData.CorrectAttempts, singleData.Attempts)
+		statsData.TimeSeries = append(statsData.TimeSeries, singleData)
+	}
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return statsData, err
+	}
+
+	return statsData, nil
+}
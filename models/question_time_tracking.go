@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	QUESTIONEVENTVIEW      string = "view"
+	QUESTIONEVENTHEARTBEAT string = "heartbeat"
+	QUESTIONEVENTANSWER    string = "answer"
+
+	// Upper bound of active time a single event may report, so a buggy or
+	// malicious client cannot inflate the cumulative time in one call.
+	MAXQUESTIONEVENTACTIVEMS int64 = 10 * 60 * 1000
+)
+
+func ValidateQuestionEventType(eventType string) string {
+	if eventType == QUESTIONEVENTVIEW || eventType == QUESTIONEVENTHEARTBEAT || eventType == QUESTIONEVENTANSWER {
+		return eventType
+	}
+	return ""
+}
+
+type QuestionEventCreateSchema struct {
+	Type     string `json:"type" form:"type"`
+	ActiveMs int64  `json:"active_ms" form:"active_ms"`
+}
+
+type QuestionTimingReportSchema struct {
+	UserId          int64   `json:"user_id"`
+	FirstName       string  `json:"first_name"`
+	LastName        string  `json:"last_name"`
+	Email           string  `json:"email"`
+	AnsweredCount   int64   `json:"answered_count"`
+	TrackedCount    int64   `json:"tracked_count"`
+	UntrackedCount  int64   `json:"untracked_count"`
+	FastAnswerCount int64   `json:"fast_answer_count"`
+	AvgActiveTimeMs float64 `json:"avg_active_time_ms"`
+	Flagged         bool    `json:"flagged"`
+}
+
+// GetActiveMs returns the reported active time clamped to a sane range.
+func (data QuestionEventCreateSchema) GetActiveMs() int64 {
+	if data.ActiveMs < 0 {
+		return 0
+	}
+	if data.ActiveMs > MAXQUESTIONEVENTACTIVEMS {
+		return MAXQUESTIONEVENTACTIVEMS
+	}
+	return data.ActiveMs
+}
+
+func (data QuestionEventCreateSchema) Record(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64) (int64, error) {
+	query := `INSERT INTO
+				question_time_tracking
+					(test_id, user_id, question_id, first_seen_at, last_seen_at, last_answered_at, active_time_ms)
+				VALUES
+					($1, $2, $3, NOW(), NOW(), CASE WHEN $4::boolean THEN NOW() END, $5)
+				ON CONFLICT (test_id, user_id, question_id) DO UPDATE
+					SET first_seen_at = COALESCE(question_time_tracking.first_seen_at, EXCLUDED.first_seen_at),
+						last_seen_at = EXCLUDED.last_seen_at,
+						last_answered_at = COALESCE(EXCLUDED.last_answered_at, question_time_tracking.last_answered_at),
+						active_time_ms = question_time_tracking.active_time_ms + EXCLUDED.active_time_ms
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, testId, userId, questionId, data.Type == QUESTIONEVENTANSWER, data.GetActiveMs())
+	return id, err
+}
+
+func FetchQuestionTimingReport(ctx context.Context, uuidString string, testId int64, thresholdMs int64, flagCount int64) ([]QuestionTimingReportSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch question timing report ", zap.Int64("testId", testId), zap.String("requestId", uuidString))
+
+	data := make([]QuestionTimingReportSchema, 0)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	// The active time is reported by the client, which can under-report it or
+	// send no events at all. An answer is also fast when the server saw less
+	// than the threshold between the first view and the first submission, and
+	// an answer without any tracking counts as fast.
+	query := `SELECT
+					a.user_id,
+					u.first_name,
+					u.last_name,
+					u.email,
+					COUNT(*),
+					COUNT(*) FILTER (WHERE a.tracked),
+					COUNT(*) FILTER (WHERE NOT a.tracked),
+					COUNT(*) FILTER (WHERE a.fast),
+					COALESCE(AVG(a.active_time_ms), 0)
+				FROM (
+					SELECT
+						s.user_id,
+						qt.id IS NOT NULL AS tracked,
+						qt.active_time_ms,
+						qt.id IS NULL
+							OR qt.active_time_ms < $2
+							OR qt.first_seen_at IS NULL
+							OR s.created_at < qt.first_seen_at + $2 * INTERVAL '1 millisecond' AS fast
+					FROM test_question_submissions s
+					LEFT JOIN question_time_tracking qt
+						on qt.test_id = s.test_id AND qt.user_id = s.user_id AND qt.question_id = s.question_id
+					WHERE s.test_id = $1
+				) a
+				JOIN users u on u.id = a.user_id
+				GROUP BY a.user_id, u.first_name, u.last_name, u.email
+				ORDER BY COUNT(*) FILTER (WHERE a.fast) DESC, a.user_id ASC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, testId, thresholdMs)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching question timing report", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData QuestionTimingReportSchema
+		err = rows.Scan(
+			&singleData.UserId,
+			&singleData.FirstName,
+			&singleData.LastName,
+			&singleData.Email,
+			&singleData.AnsweredCount,
+			&singleData.TrackedCount,
+			&singleData.UntrackedCount,
+			&singleData.FastAnswerCount,
+			&singleData.AvgActiveTimeMs,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, err
+		}
+		singleData.Flagged = singleData.FastAnswerCount >= flagCount
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+
+	return data, nil
+}
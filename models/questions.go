@@ -3,7 +3,6 @@ package models
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
@@ -42,6 +41,7 @@ type QuestionCreateSchema struct {
 	Type         string                 `json:"type"`
 	QuestionData map[string]interface{} `json:"question_data"`
 	AnswerData   map[string]interface{} `json:"answer_data"`
+	Tags         []string               `json:"tags"`
 }
 
 type QuestionResponseSchemaForTakeTest struct {
@@ -51,13 +51,23 @@ type QuestionResponseSchemaForTakeTest struct {
 }
 
 type QuestionResponseSchema struct {
-	Id           int64  `json:"id"`
-	Type         string `json:"type"`
-	QuestionData string `json:"question_data"`
-	AnswerData   string `json:"answer_data"`
+	Id           int64    `json:"id"`
+	Type         string   `json:"type"`
+	QuestionData string   `json:"question_data"`
+	AnswerData   string   `json:"answer_data"`
+	Tags         []string `json:"tags"`
+}
+
+// GetTags returns the question tags, never nil, so they can be stored in the
+// NOT NULL tags column.
+func (data QuestionCreateSchema) GetTags() []string {
+	if data.Tags == nil {
+		return []string{}
+	}
+	return data.Tags
 }
 
-func (data QuestionCreateSchema) Insert(uuidString string) (int64, error) {
+func (data QuestionCreateSchema) Insert(ctx context.Context, uuidString string) (int64, error) {
 	questionData, err := json.Marshal(data.QuestionData)
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while json marshalling question data ", zap.String("requestId", uuidString), zap.Error(err))
@@ -71,16 +81,16 @@ func (data QuestionCreateSchema) Insert(uuidString string) (int64, error) {
 	questionType := GetQuestionType(data.Type)
 	query := `INSERT INTO
 				questions
-					(type, question_data, answer_data)
+					(type, question_data, answer_data, tags)
 				VALUES
-					($1, $2, $3)
+					($1, $2, $3, $4)
 				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	id, err := queryToExecute.InsertOrUpdateOperations(uuidString, questionType, string(questionData), string(answerData))
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, questionType, string(questionData), string(answerData), data.GetTags())
 	return id, err
 }
 
-func (data QuestionCreateSchema) Update(uuidString string, questionId int64) (int64, error) {
+func (data QuestionCreateSchema) Update(ctx context.Context, uuidString string, questionId int64) (int64, error) {
 	questionData, err := json.Marshal(data.QuestionData)
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while json marshalling question data ", zap.String("requestId", uuidString), zap.Error(err))
@@ -94,30 +104,29 @@ func (data QuestionCreateSchema) Update(uuidString string, questionId int64) (in
 	questionType := GetQuestionType(data.Type)
 	query := `UPDATE
 				questions
-					set type=$1, question_data=$2, answer_data=$3
-				WHERE id= $4
+					set type=$1, question_data=$2, answer_data=$3, tags=$4
+				WHERE id= $5
 				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	id, err := queryToExecute.InsertOrUpdateOperations(uuidString, questionType, questionData, answerData, questionId)
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, questionType, string(questionData), string(answerData), data.GetTags(), questionId)
 	return id, err
 }
 
-func DeleteQuestion(uuidString string, questionId int64) (bool, error) {
-	query := fmt.Sprintf(`DELETE FROM questions WHERE id=%d`, questionId)
+func DeleteQuestion(ctx context.Context, uuidString string, questionId int64) (bool, error) {
+	query := `DELETE FROM questions WHERE id=$1`
 	queryToExecute := QueryStructToExecute{Query: query}
-	status, err := queryToExecute.DeleteOperation(uuidString)
+	status, err := queryToExecute.DeleteOperation(ctx, uuidString, questionId)
 	return status, err
 }
 
-func FetchQuestion(uuidString string, questionId int64) (QuestionResponseSchema, error) {
+func FetchQuestion(ctx context.Context, uuidString string, questionId int64) (QuestionResponseSchema, error) {
 	logger.Logger.Info("MODELS :: Will fetch test details ", zap.Int64("questionId", questionId), zap.String("requestId", uuidString))
 
 	var questionData QuestionResponseSchema
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return questionData, err
@@ -130,19 +139,21 @@ func FetchQuestion(uuidString string, questionId int64) (QuestionResponseSchema,
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	query := `SELECT
 							q.id,
 							q.type,
 							q.question_data,
-							q.answer_data
+							q.answer_data,
+							q.tags
 							FROM questions q
-							WHERE q.id=%d LIMIT 1`, questionId)
+							WHERE q.id=$1 LIMIT 1`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
-	err = tx.QueryRow(ctx, query).Scan(
+	err = tx.QueryRow(ctx, query, questionId).Scan(
 		&questionData.Id,
 		&questionData.Type,
 		&questionData.QuestionData,
 		&questionData.AnswerData,
+		&questionData.Tags,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -157,16 +168,17 @@ func FetchQuestion(uuidString string, questionId int64) (QuestionResponseSchema,
 	return questionData, nil
 }
 
-func FetchQuestions(uuidString string, limit int, offset int, random bool) ([]QuestionResponseSchema, int, error) {
+// FetchQuestions returns a page of the question bank. With random set the
+// questions are shuffled and page.After is ignored.
+func FetchQuestions(ctx context.Context, uuidString string, page Page, random bool) ([]QuestionResponseSchema, int, error) {
 	logger.Logger.Info("MODELS :: Will fetch tests ", zap.String("requestId", uuidString))
 
 	var questionsData []QuestionResponseSchema
 	var count int
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return questionsData, count, err
@@ -178,31 +190,40 @@ func FetchQuestions(uuidString string, limit int, offset int, random bool) ([]Qu
 			tx.Commit(ctx)
 		}
 	}()
+	count, err = countRows(ctx, tx, uuidString, page, `SELECT COUNT(*) FROM questions`)
+	if err != nil {
+		return questionsData, count, err
+	}
+
 	var query string
+	var args []any
 
 	if random {
-		query = fmt.Sprintf(`SELECT
+		query = `SELECT
 		q.id,
 		q.type,
 		q.question_data,
 		q.answer_data,
-		COUNT(*) OVER() AS total
+		q.tags
 		FROM questions q
-		ORDER BY RANDOM() LIMIT %d OFFSET %d`, limit, offset)
+		ORDER BY RANDOM() LIMIT $1 OFFSET $2`
+		args = []any{page.Limit, page.Offset}
 	} else {
-		query = fmt.Sprintf(`SELECT
+		query = `SELECT
 							q.id,
 							q.type,
 							q.question_data,
 							q.answer_data,
-							COUNT(*) OVER() AS total
+							q.tags
 							FROM questions q
-							ORDER BY id DESC LIMIT %d OFFSET %d`, limit, offset)
+							WHERE ($1::bigint = 0 OR q.id < $1)
+							ORDER BY q.id DESC LIMIT $2 OFFSET $3`
+		args = []any{page.After, page.Limit, page.offset()}
 	}
 
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
 
-	rows, err := tx.Query(ctx, query)
+	rows, err := tx.Query(ctx, query, args...)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("requestId", uuidString), zap.String("query", query))
@@ -220,7 +241,7 @@ func FetchQuestions(uuidString string, limit int, offset int, random bool) ([]Qu
 			&singleQuestionData.Type,
 			&singleQuestionData.QuestionData,
 			&singleQuestionData.AnswerData,
-			&count,
+			&singleQuestionData.Tags,
 		)
 		if err != nil {
 			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
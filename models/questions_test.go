@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestQuestionRoundTripsHostileInput(t *testing.T) {
+	connectTestDatabase(t)
+	ctx := context.Background()
+	uuidString := "test"
+
+	question := QuestionCreateSchema{
+		Type:         "multiple_choice",
+		QuestionData: map[string]interface{}{"question": hostileStrings[2], "choices": hostileStrings},
+		AnswerData:   map[string]interface{}{"choices": hostileStrings[:2]},
+		Tags:         hostileStrings,
+	}
+	id, err := question.Insert(ctx, uuidString)
+	if err != nil {
+		t.Fatalf("create question: %v", err)
+	}
+	stored, err := FetchQuestion(ctx, uuidString, id)
+	if err != nil {
+		t.Fatalf("fetch question: %v", err)
+	}
+	assertQuestionEqual(t, stored, question)
+
+	question.QuestionData = map[string]interface{}{"question": hostileStrings[4], "choices": hostileStrings[3:]}
+	question.Tags = hostileStrings[3:]
+	if _, err := question.Update(ctx, uuidString, id); err != nil {
+		t.Fatalf("update question: %v", err)
+	}
+	stored, err = FetchQuestion(ctx, uuidString, id)
+	if err != nil {
+		t.Fatalf("fetch question: %v", err)
+	}
+	assertQuestionEqual(t, stored, question)
+}
+
+// assertQuestionEqual compares the stored JSON by value, as jsonb does not keep
+// the formatting of the document.
+func assertQuestionEqual(t *testing.T, stored QuestionResponseSchema, want QuestionCreateSchema) {
+	t.Helper()
+	for name, pair := range map[string][2]any{
+		"question_data": {stored.QuestionData, want.QuestionData},
+		"answer_data":   {stored.AnswerData, want.AnswerData},
+	} {
+		var got, expected any
+		if err := json.Unmarshal([]byte(pair[0].(string)), &got); err != nil {
+			t.Fatalf("decode stored %s %q: %v", name, pair[0], err)
+		}
+		wantBytes, err := json.Marshal(pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(wantBytes, &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s = %v, want %v", name, got, expected)
+		}
+	}
+	if !reflect.DeepEqual(stored.Tags, want.Tags) {
+		t.Errorf("tags = %q, want %q", stored.Tags, want.Tags)
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	REPLICAHEALTHCHECKPERIOD  time.Duration = 5 * time.Second
+	REPLICAHEALTHCHECKTIMEOUT time.Duration = 2 * time.Second
+)
+
+// replica is a read replica of the primary. Read-only transactions only use it
+// while healthy: reachable and at most maxLag behind the primary.
+type replica struct {
+	host    string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+var (
+	replicas       []*replica
+	replicaCounter atomic.Uint64
+	replicaMaxLag  time.Duration
+
+	replicaStop   chan struct{}
+	replicaChecks sync.WaitGroup
+)
+
+// replicaLagQuery returns how far the replica's replayed data is behind. A
+// replica that has replayed everything it received is not behind, however old
+// the last replayed transaction is, since the primary may just be idle.
+const replicaLagQuery = `SELECT
+							CASE WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+							ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+							END::FLOAT8`
+
+// createReplicaPools opens a pool per replica, checks them once so healthy
+// replicas serve reads right away, and keeps checking them in the background.
+func createReplicaPools(databaseStrings []string, maxLag time.Duration) {
+	replicaMaxLag = maxLag
+	for _, databaseString := range databaseStrings {
+		pool, err := newPool(databaseString)
+		if err != nil {
+			logger.Logger.Error("DATABASE :: Could not create replica pool.", zap.Error(err))
+			continue
+		}
+		replicas = append(replicas, &replica{host: replicaHost(databaseString), pool: pool})
+	}
+	if len(replicas) == 0 {
+		return
+	}
+	checkReplicas()
+	replicaStop = make(chan struct{})
+	replicaChecks.Add(1)
+	go func() {
+		defer replicaChecks.Done()
+		ticker := time.NewTicker(REPLICAHEALTHCHECKPERIOD)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				checkReplicas()
+			case <-replicaStop:
+				return
+			}
+		}
+	}()
+}
+
+// closeReplicaPools stops the health checks and closes the replica pools.
+// Replicas are marked unhealthy first so late reads go to the primary.
+func closeReplicaPools() {
+	if replicaStop != nil {
+		close(replicaStop)
+		replicaChecks.Wait()
+		replicaStop = nil
+	}
+	for _, r := range replicas {
+		r.healthy.Store(false)
+		r.pool.Close()
+	}
+}
+
+func replicaHost(databaseString string) string {
+	parsed, err := url.Parse(databaseString)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+func checkReplicas() {
+	for _, r := range replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), REPLICAHEALTHCHECKTIMEOUT)
+		var lagSeconds float64
+		err := r.pool.QueryRow(ctx, replicaLagQuery).Scan(&lagSeconds)
+		cancel()
+
+		lag := time.Duration(lagSeconds * float64(time.Second))
+		switch {
+		case err != nil:
+			r.setHealthy(false, zap.Error(err))
+		case lag > replicaMaxLag:
+			r.setHealthy(false, zap.Duration("lag", lag))
+		default:
+			r.setHealthy(true, zap.Duration("lag", lag))
+		}
+	}
+}
+
+// setHealthy records the state of the replica and logs when it changes.
+func (r *replica) setHealthy(healthy bool, fields ...zap.Field) {
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	fields = append(fields, zap.String("host", r.host))
+	if healthy {
+		logger.Logger.Info("DATABASE :: Replica is serving reads", fields...)
+	} else {
+		logger.Logger.Warn("DATABASE :: Replica is unhealthy, reading from primary", fields...)
+	}
+}
+
+// readReplica returns a healthy replica for a read-only transaction of ctx,
+// taking turns between them, or nil when reads must go to the primary.
+func readReplica(ctx context.Context, options pgx.TxOptions) *replica {
+	if len(replicas) == 0 || hasWritten(ctx) {
+		return nil
+	}
+	// Hot standbys cannot run serializable transactions.
+	if options.IsoLevel == pgx.Serializable {
+		return nil
+	}
+	start := replicaCounter.Add(1)
+	for i := range uint64(len(replicas)) {
+		if r := replicas[(start+i)%uint64(len(replicas))]; r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites returns a context whose read-only transactions go to the
+// primary once a transaction of the context wrote, so a request sees its own
+// writes however far the replicas lag. Contexts derived from it share the
+// state.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if TracksWrites(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, readYourWritesKey{}, new(atomic.Bool))
+}
+
+// TracksWrites reports whether ctx comes from WithReadYourWrites.
+func TracksWrites(ctx context.Context) bool {
+	_, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool)
+	return ok
+}
+
+func markWritten(ctx context.Context) {
+	if written, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool); ok {
+		written.Store(true)
+	}
+}
+
+func hasWritten(ctx context.Context) bool {
+	written, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool)
+	return ok && written.Load()
+}
@@ -0,0 +1,108 @@
+package models
+
+// Permission names a single capability that a role may be granted. Routes
+// declare the permissions they need with middleware.RequirePermission.
+type Permission string
+
+const (
+	PermissionTestRead      Permission = "test:read"
+	PermissionTestWrite     Permission = "test:write"
+	PermissionTestTake      Permission = "test:take"
+	PermissionTestGrade     Permission = "test:grade"
+	PermissionQuestionRead  Permission = "question:read"
+	PermissionQuestionWrite Permission = "question:write"
+	PermissionUserManage    Permission = "user:manage"
+)
+
+// allPermissions lists every permission; no role is granted all of them.
+var allPermissions = []Permission{
+	PermissionTestRead,
+	PermissionTestWrite,
+	PermissionTestTake,
+	PermissionTestGrade,
+	PermissionQuestionRead,
+	PermissionQuestionWrite,
+	PermissionUserManage,
+}
+
+var rolePermissions = map[int][]Permission{
+	STUDENT: {
+		PermissionTestRead,
+		PermissionTestTake,
+	},
+	TEACHER: {
+		PermissionTestRead,
+		PermissionTestWrite,
+		PermissionTestGrade,
+		PermissionQuestionRead,
+		PermissionQuestionWrite,
+	},
+	TEACHINGASSISTANT: {
+		PermissionTestRead,
+		PermissionTestGrade,
+		PermissionQuestionRead,
+	},
+	ADMIN: {
+		PermissionTestRead,
+		PermissionTestWrite,
+		PermissionTestGrade,
+		PermissionQuestionRead,
+		PermissionQuestionWrite,
+		PermissionUserManage,
+	},
+}
+
+// GetPermissions returns the permissions granted to a user type.
+func GetPermissions(userType int) []Permission {
+	return rolePermissions[userType]
+}
+
+// HasPermission reports whether a user type has been granted the permission.
+func HasPermission(userType int, permission Permission) bool {
+	for _, granted := range rolePermissions[userType] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePermission reports whether permission is one of the known names.
+func ValidatePermission(permission Permission) bool {
+	for _, known := range allPermissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns what the user may do on this request: the permissions of
+// the user's role, narrowed to the key's scopes when the request was
+// authenticated with an API key.
+func (data UserSchema) Permissions() []Permission {
+	permissions := GetPermissions(data.UserType())
+	if data.APIKeyId == 0 {
+		return permissions
+	}
+	var scoped []Permission
+	for _, permission := range permissions {
+		for _, scope := range data.APIKeyScopes {
+			if scope == permission {
+				scoped = append(scoped, permission)
+				break
+			}
+		}
+	}
+	return scoped
+}
+
+// HasPermission reports whether the user may use permission on this request.
+func (data UserSchema) HasPermission(permission Permission) bool {
+	for _, granted := range data.Permissions() {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
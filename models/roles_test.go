@@ -0,0 +1,19 @@
+package models
+
+import "testing"
+
+func TestValidatePermissionKnowsEveryRolesPermissions(t *testing.T) {
+	for userType, permissions := range rolePermissions {
+		for _, permission := range permissions {
+			if !ValidatePermission(permission) {
+				t.Errorf("permission %q of user type %d is not valid", permission, userType)
+			}
+		}
+	}
+	if !ValidatePermission(PermissionTestTake) {
+		t.Errorf("permission %q is not valid", PermissionTestTake)
+	}
+	if ValidatePermission("test:delete") {
+		t.Error("unknown permission is valid")
+	}
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	SESSIONREVOKEDLOGOUT         string = "logout"
+	SESSIONREVOKEDBYUSER         string = "revoked_by_user"
+	SESSIONREVOKEDBYADMIN        string = "revoked_by_admin"
+	SESSIONREVOKEDPASSWORDCHANGE string = "password_change"
+	SESSIONREVOKEDDEACTIVATED    string = "deactivated"
+	SESSIONREVOKEDROLECHANGE     string = "role_change"
+	SESSIONREVOKEDTOKENREUSE     string = "refresh_token_reuse"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+type SessionSchema struct {
+	Id         string    `json:"id"`
+	ClientIp   string    `json:"client_ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type RefreshTokenSchema struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+// SessionUserSchema is the session owner returned after a refresh token rotation.
+type SessionUserSchema struct {
+	SessionId string
+	UserId    int64
+	Email     string
+}
+
+// CreateSession starts a session and stores the hash of its first refresh token.
+func CreateSession(ctx context.Context, uuidString string, sessionId string, userId int64, clientIp string, userAgent string, refreshTokenHash string, expiresAt time.Time) error {
+	logger.Logger.Info("MODELS :: Will create session", zap.String("requestId", uuidString), zap.Int64("userId", userId), zap.String("sessionId", sessionId))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	sessionQuery := `INSERT INTO
+						sessions
+							(id, user_id, client_ip, user_agent, expires_at)
+						VALUES
+							($1, $2, $3, $4, $5)`
+	_, err = tx.Exec(ctx, sessionQuery, sessionId, userId, clientIp, userAgent, expiresAt)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while creating session.", zap.String("requestId", uuidString), zap.Error(err))
+		return err
+	}
+
+	tokenQuery := `INSERT INTO
+						session_refresh_tokens
+							(token_hash, session_id)
+						VALUES
+							($1, $2)`
+	_, err = tx.Exec(ctx, tokenQuery, refreshTokenHash, sessionId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while storing refresh token.", zap.String("requestId", uuidString), zap.Error(err))
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// IsSessionActive reports whether the session exists for the user and has
+// neither been revoked nor expired.
+func IsSessionActive(ctx context.Context, uuidString string, sessionId string, userId int64) (bool, error) {
+	var active bool
+	dbConnection := conn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `SELECT EXISTS(
+				SELECT 1 FROM sessions s
+				WHERE s.id=$1 AND s.user_id=$2 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+			)`
+	err := dbConnection.QueryRow(ctx, query, sessionId, userId).Scan(&active)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while checking session.", zap.String("requestId", uuidString), zap.Error(err))
+		return false, err
+	}
+	return active, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one and extends the
+// session. Presenting a token that was already rotated means it was copied,
+// so the whole session is revoked and ErrRefreshTokenReused is returned.
+func RotateRefreshToken(ctx context.Context, uuidString string, refreshTokenHash string, newRefreshTokenHash string, expiresAt time.Time) (SessionUserSchema, error) {
+	logger.Logger.Info("MODELS :: Will rotate refresh token", zap.String("requestId", uuidString))
+
+	var sessionUser SessionUserSchema
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return sessionUser, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	var (
+		rotatedAt *time.Time
+		usable    bool
+	)
+	query := `SELECT
+					s.id,
+					s.user_id,
+					u.email,
+					rt.rotated_at,
+					s.revoked_at IS NULL AND s.expires_at > NOW() AND u.deactivated_at IS NULL
+				FROM session_refresh_tokens rt
+				INNER JOIN sessions s ON s.id = rt.session_id
+				INNER JOIN users u ON u.id = s.user_id
+				WHERE rt.token_hash=$1
+				FOR UPDATE OF rt, s`
+	err = tx.QueryRow(ctx, query, refreshTokenHash).Scan(&sessionUser.SessionId, &sessionUser.UserId, &sessionUser.Email, &rotatedAt, &usable)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return SessionUserSchema{}, ErrSessionNotFound
+		}
+		logger.Logger.Error("MODELS :: Error while fetching refresh token.", zap.String("requestId", uuidString), zap.Error(err))
+		return SessionUserSchema{}, err
+	}
+
+	if rotatedAt != nil {
+		logger.Logger.Warn("MODELS :: Refresh token reuse detected, revoking session", zap.String("requestId", uuidString), zap.String("sessionId", sessionUser.SessionId))
+		_, err = tx.Exec(ctx, `UPDATE sessions SET revoked_at=NOW(), revoked_reason=$2 WHERE id=$1 AND revoked_at IS NULL`, sessionUser.SessionId, SESSIONREVOKEDTOKENREUSE)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while revoking session.", zap.String("requestId", uuidString), zap.Error(err))
+			return SessionUserSchema{}, err
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return SessionUserSchema{}, err
+		}
+		return SessionUserSchema{}, ErrRefreshTokenReused
+	}
+	if !usable {
+		return SessionUserSchema{}, ErrSessionNotFound
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE session_refresh_tokens SET rotated_at=NOW() WHERE token_hash=$1`, refreshTokenHash)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while rotating refresh token.", zap.String("requestId", uuidString), zap.Error(err))
+		return SessionUserSchema{}, err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO session_refresh_tokens (token_hash, session_id) VALUES ($1, $2)`, newRefreshTokenHash, sessionUser.SessionId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while storing refresh token.", zap.String("requestId", uuidString), zap.Error(err))
+		return SessionUserSchema{}, err
+	}
+	_, err = tx.Exec(ctx, `UPDATE sessions SET last_used_at=NOW(), expires_at=$2 WHERE id=$1`, sessionUser.SessionId, expiresAt)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while extending session.", zap.String("requestId", uuidString), zap.Error(err))
+		return SessionUserSchema{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while commit transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return SessionUserSchema{}, err
+	}
+	return sessionUser, nil
+}
+
+// FetchUserSessions lists the sessions of a user that can still be used.
+func FetchUserSessions(ctx context.Context, uuidString string, userId int64) ([]SessionSchema, error) {
+	logger.Logger.Info("MODELS :: Will fetch user sessions", zap.String("requestId", uuidString), zap.Int64("userId", userId))
+
+	var data []SessionSchema
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT
+					s.id,
+					s.client_ip,
+					s.user_agent,
+					s.created_at,
+					s.last_used_at,
+					s.expires_at
+				FROM sessions s
+				WHERE s.user_id=$1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+				ORDER BY s.last_used_at DESC`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, userId)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching sessions", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData SessionSchema
+		err = rows.Scan(
+			&singleData.Id,
+			&singleData.ClientIp,
+			&singleData.UserAgent,
+			&singleData.CreatedAt,
+			&singleData.LastUsedAt,
+			&singleData.ExpiresAt,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, err
+		}
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, err
+	}
+	return data, nil
+}
+
+// RevokeSession revokes one session of the user. It returns 0 when there is no
+// such active session.
+func RevokeSession(ctx context.Context, uuidString string, userId int64, sessionId string, reason string) (int64, error) {
+	query := `UPDATE
+				sessions
+					SET revoked_at=NOW(), revoked_reason=$3
+				WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL
+				RETURNING user_id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, sessionId, userId, reason)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// RevokeUserSessions revokes every active session of the user and returns how
+// many were revoked.
+func RevokeUserSessions(ctx context.Context, uuidString string, userId int64, reason string) (int64, error) {
+	logger.Logger.Info("MODELS :: Will revoke user sessions", zap.String("requestId", uuidString), zap.Int64("userId", userId), zap.String("reason", reason))
+
+	dbConnection := writeConn(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `UPDATE
+				sessions
+					SET revoked_at=NOW(), revoked_reason=$2
+				WHERE user_id=$1 AND revoked_at IS NULL`
+	tag, err := dbConnection.Exec(ctx, query, userId, reason)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while revoking sessions.", zap.String("requestId", uuidString), zap.Error(err))
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
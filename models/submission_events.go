@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+type SubmissionEventSchema struct {
+	Id            int64     `json:"id"`
+	SubmissionId  int64     `json:"submission_id"`
+	TestId        int64     `json:"test_id"`
+	UserId        int64     `json:"user_id"`
+	QuestionId    int64     `json:"question_id"`
+	SubmittedData string    `json:"submitted_data"`
+	AnswerStatus  bool      `json:"answer_status"`
+	ClientIp      string    `json:"client_ip"`
+	UserAgent     string    `json:"user_agent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func FetchSubmissionEvents(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, limit int, offset int) ([]SubmissionEventSchema, int, error) {
+	logger.Logger.Info("MODELS :: Will fetch submission events ", zap.String("requestId", uuidString), zap.Int64("testId", testId), zap.Int64("userId", userId), zap.Int64("questionId", questionId))
+
+	var data []SubmissionEventSchema
+	var count int
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
+		return data, count, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback(ctx)
+		} else {
+			tx.Commit(ctx)
+		}
+	}()
+
+	query := `SELECT
+					se.id,
+					se.submission_id,
+					se.test_id,
+					se.user_id,
+					se.question_id,
+					se.submitted_data,
+					se.answer_status,
+					se.client_ip,
+					se.user_agent,
+					se.created_at,
+					COUNT(*) OVER() AS total
+				FROM submission_events se
+				WHERE se.test_id = $1 AND se.user_id = $2 AND se.question_id = $3
+				ORDER BY se.created_at ASC, se.id ASC LIMIT $4 OFFSET $5`
+	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
+
+	rows, err := tx.Query(ctx, query, testId, userId, questionId, limit, offset)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while fetching submission events", zap.String("requestId", uuidString), zap.Error(err))
+		return data, count, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var singleData SubmissionEventSchema
+		err = rows.Scan(
+			&singleData.Id,
+			&singleData.SubmissionId,
+			&singleData.TestId,
+			&singleData.UserId,
+			&singleData.QuestionId,
+			&singleData.SubmittedData,
+			&singleData.AnswerStatus,
+			&singleData.ClientIp,
+			&singleData.UserAgent,
+			&singleData.CreatedAt,
+			&count,
+		)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
+			return data, count, err
+		}
+
+		data = append(data, singleData)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while at rows level", zap.String("requestId", uuidString), zap.Error(err))
+		return data, count, err
+	}
+
+	return data, count, nil
+}
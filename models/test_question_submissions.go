@@ -3,7 +3,6 @@ package models
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
@@ -12,24 +11,26 @@ import (
 )
 
 type TestQuestionSubmissionSchema struct {
-	Id            int64                  `json:"id"`
-	UserId        int64                  `json:"user_id"`
-	TestId        int64                  `json:"test_id"`
-	QuestionData  QuestionResponseSchema `json:"question"`
-	SubmittedData string                 `json:"submitted_data"`
-	AnswerStatus  bool                   `json:"answer_status"`
+	Id             int64                  `json:"id"`
+	UserId         int64                  `json:"user_id"`
+	TestId         int64                  `json:"test_id"`
+	QuestionData   QuestionResponseSchema `json:"question"`
+	SubmittedData  string                 `json:"submitted_data"`
+	AnswerStatus   bool                   `json:"answer_status"`
+	FirstSeenAt    *time.Time             `json:"first_seen_at"`
+	LastAnsweredAt *time.Time             `json:"last_answered_at"`
+	ActiveTimeMs   int64                  `json:"active_time_ms"`
 }
 
-func FetchTestQuestionSubmissions(uuidString string, testId int64, userId int64, limit int, offset int) ([]TestQuestionSubmissionSchema, int, error) {
+func FetchTestQuestionSubmissions(ctx context.Context, uuidString string, testId int64, userId int64, page Page) ([]TestQuestionSubmissionSchema, int, error) {
 	logger.Logger.Info("MODELS :: Will fetch test question submissions ", zap.String("requestId", uuidString), zap.Int64("testId", testId), zap.Int64("userId", userId))
 
 	var data []TestQuestionSubmissionSchema
 	var count int
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return data, count, err
@@ -42,7 +43,12 @@ func FetchTestQuestionSubmissions(uuidString string, testId int64, userId int64,
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	count, err = countRows(ctx, tx, uuidString, page, `SELECT COUNT(*) FROM test_question_submissions WHERE test_id = $1 AND user_id = $2`, testId, userId)
+	if err != nil {
+		return data, count, err
+	}
+
+	query := `SELECT
 							tq.id,
 							tq.test_id,
 							tq.user_id,
@@ -52,14 +58,19 @@ func FetchTestQuestionSubmissions(uuidString string, testId int64, userId int64,
 							q.type,
 							q.question_data,
 							q.answer_data,
-							COUNT(*) OVER() AS total
+							q.tags,
+							qt.first_seen_at,
+							qt.last_answered_at,
+							COALESCE(qt.active_time_ms, 0)
 							FROM test_question_submissions tq
 							JOIN questions q on q.id = tq.question_id
-							WHERE tq.test_id = %d AND tq.user_id = %d
-							ORDER BY tq.id DESC LIMIT %d OFFSET %d`, testId, userId, limit, offset)
+							LEFT JOIN question_time_tracking qt
+								on qt.test_id = tq.test_id AND qt.user_id = tq.user_id AND qt.question_id = tq.question_id
+							WHERE tq.test_id = $1 AND tq.user_id = $2 AND ($3::bigint = 0 OR tq.id < $3)
+							ORDER BY tq.id DESC LIMIT $4 OFFSET $5`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
 
-	rows, err := tx.Query(ctx, query)
+	rows, err := tx.Query(ctx, query, testId, userId, page.After, page.Limit, page.offset())
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("requestId", uuidString), zap.String("query", query))
@@ -82,7 +93,10 @@ func FetchTestQuestionSubmissions(uuidString string, testId int64, userId int64,
 			&singleData.QuestionData.Type,
 			&singleData.QuestionData.QuestionData,
 			&singleData.QuestionData.AnswerData,
-			&count,
+			&singleData.QuestionData.Tags,
+			&singleData.FirstSeenAt,
+			&singleData.LastAnsweredAt,
+			&singleData.ActiveTimeMs,
 		)
 		if err != nil {
 			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
@@ -101,35 +115,45 @@ func FetchTestQuestionSubmissions(uuidString string, testId int64, userId int64,
 	return data, count, nil
 }
 
-func CreateOrUpdateTestQuestionSubmission(uuidString string, testId int64, userId int64, questionId int64, answerData map[string][]string, questionData QuestionResponseSchema) (int64, error) {
+// GradeSubmission reports whether the submitted choices contain every correct
+// choice of the question's answer data.
+func GradeSubmission(answerData map[string][]string, questionAnswerData string) bool {
+	var testQuestionUnmarshal map[string][]string
+	_ = json.Unmarshal([]byte(questionAnswerData), &testQuestionUnmarshal)
+
+	answerDataChoices := answerData["answer_data"]
+	questionAnswerChoices := testQuestionUnmarshal["choices"]
+	var correctAnswer int
+	for _, givenAnswer := range answerDataChoices {
+		for _, actualAnswer := range questionAnswerChoices {
+			if givenAnswer == actualAnswer {
+				correctAnswer += 1
+			}
+		}
+	}
+	return correctAnswer == len(questionAnswerChoices)
+}
+
+func CreateOrUpdateTestQuestionSubmission(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, answerData map[string][]string, questionData QuestionResponseSchema, clientIp string, userAgent string) (int64, error) {
 	logger.Logger.Info("MODELS :: Will create or update test question submission data for student", zap.String("requestId", uuidString), zap.Int64("testId", testId), zap.Int64("userId", userId), zap.Int64("questionId", questionId), zap.Any("answerData", answerData), zap.Any("questionanswer data", questionData.AnswerData))
 
 	var id int64
-	var testQuestionSubmissionQuery string
 	var answerStatus bool
 	var questionAnswerData string
-	var answerDataChoices []string
-	var questionAnswerChoices []string
-	dbConnection := DbPool()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
 		return id, err
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx)
-		} else {
-			tx.Commit(ctx)
-		}
-	}()
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
 
-	questionAnswerDataQuery := fmt.Sprintf(`SELECT q.answer_data FROM questions q WHERE q.id=%d`, questionId)
-	err = tx.QueryRow(ctx, questionAnswerDataQuery).Scan(&questionAnswerData)
+	questionAnswerDataQuery := `SELECT q.answer_data FROM questions q WHERE q.id=$1`
+	err = tx.QueryRow(ctx, questionAnswerDataQuery, questionId).Scan(&questionAnswerData)
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while executing fetch question answer data query.",
 			zap.String("requestId", uuidString),
@@ -138,47 +162,8 @@ func CreateOrUpdateTestQuestionSubmission(uuidString string, testId int64, userI
 		return id, err
 	}
 
-	var testQuestionUnmarshal map[string][]string
-	_ = json.Unmarshal([]byte(questionAnswerData), &testQuestionUnmarshal)
-	logger.Logger.Debug("MODELS :: question answer data ", zap.Any("data", questionAnswerData), zap.Any("unmarshal", testQuestionUnmarshal))
-
-	answerDataChoices = answerData["answer_data"]
-	questionAnswerChoices = testQuestionUnmarshal["choices"]
-	logger.Logger.Debug("MODELS :: question choices", zap.Any("choices ", answerDataChoices), zap.Any("raw", answerData["answer_data"]))
-	var correctAnswer int
-	for _, givenAnswer := range answerDataChoices {
-		for _, actualAnswer := range questionAnswerChoices {
-			logger.Logger.Debug("MODELS :: checking ", zap.Any("given", givenAnswer), zap.Any("actual", actualAnswer))
-			if givenAnswer == actualAnswer {
-				correctAnswer += 1
-			}
-		}
-	}
-	if correctAnswer == len(questionAnswerChoices) {
-		answerStatus = true
-	}
-
-	logger.Logger.Debug("MODELS :: question answer", zap.Any("answer ", answerStatus), zap.Any("count", correctAnswer), zap.Any("a", len(questionAnswerChoices)))
-
-	selectTestQuestionSubmissionQuery := fmt.Sprintf(`SELECT
-														id
-													FROM
-														test_question_submissions
-													WHERE test_id=%d AND user_id = %d AND question_id = %d`, testId, userId, questionId)
-
-	err = tx.QueryRow(ctx, selectTestQuestionSubmissionQuery).Scan(&id)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("query", selectTestQuestionSubmissionQuery))
-		} else {
-			logger.Logger.Error("MODELS :: Error while executing query.",
-				zap.String("requestId", uuidString),
-				zap.Error(err),
-			)
-			return id, err
-		}
-
-	}
+	answerStatus = GradeSubmission(answerData, questionAnswerData)
+	logger.Logger.Debug("MODELS :: question answer", zap.Any("answer ", answerStatus))
 
 	answerDatJson, err := json.Marshal(answerData)
 	if err != nil {
@@ -186,23 +171,18 @@ func CreateOrUpdateTestQuestionSubmission(uuidString string, testId int64, userI
 		return id, err
 	}
 
-	if id > 0 {
-		testQuestionSubmissionQuery = fmt.Sprintf(`UPDATE
-													test_question_submissions
-												SET submitted_data='%s', answer_status=%t
-												WHERE test_id = %d AND user_id = %d AND question_id = %d
-												RETURNING id`, answerDatJson, answerStatus, testId, userId, questionId)
-
-	} else {
-		testQuestionSubmissionQuery = fmt.Sprintf(`INSERT INTO
-													test_question_submissions
-														(test_id, user_id, question_id, submitted_data, answer_status)
-													VALUES
-														(%d, %d, %d, '%s', %t) RETURNING id`,
-			testId, userId, questionId, answerDatJson, answerStatus)
-	}
-
-	err = tx.QueryRow(ctx, testQuestionSubmissionQuery).Scan(&id)
+	// A single upsert on the (test, user, question) unique key, so concurrent
+	// submissions of the same answer update one row instead of racing to insert.
+	testQuestionSubmissionQuery := `INSERT INTO
+										test_question_submissions
+											(test_id, user_id, question_id, submitted_data, answer_status)
+										VALUES
+											($1, $2, $3, $4, $5)
+										ON CONFLICT (test_id, user_id, question_id) DO UPDATE
+											SET submitted_data=EXCLUDED.submitted_data, answer_status=EXCLUDED.answer_status, updated_at=NOW()
+										RETURNING id`
+
+	err = tx.QueryRow(ctx, testQuestionSubmissionQuery, testId, userId, questionId, string(answerDatJson), answerStatus).Scan(&id)
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while executing query.",
 			zap.String("requestId", uuidString),
@@ -212,5 +192,23 @@ func CreateOrUpdateTestQuestionSubmission(uuidString string, testId int64, userI
 		)
 		return id, err
 	}
+
+	submissionEventQuery := `INSERT INTO
+								submission_events
+									(submission_id, test_id, user_id, question_id, submitted_data, answer_status, client_ip, user_agent)
+								VALUES
+									($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.Exec(ctx, submissionEventQuery, id, testId, userId, questionId, string(answerDatJson), answerStatus, clientIp, userAgent)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while recording submission event.",
+			zap.String("requestId", uuidString),
+			zap.Error(err),
+		)
+		return id, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return id, err
+	}
 	return id, nil
 }
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// seedTestQuestion creates a student and a test holding one question.
+func seedTestQuestion(t *testing.T, ctx context.Context, name string, questionData QuestionCreateSchema) (int64, int64, QuestionResponseSchema) {
+	t.Helper()
+	uuidString := "test"
+	userId, err := UserCreateSchema{
+		FirstName: "Test",
+		LastName:  "Student",
+		Email:     uniqueEmail(name),
+		Password:  "not-a-hash",
+		Type:      "student",
+	}.Insert(ctx, uuidString)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	testId, err := TestCreateSchema{Title: name}.Insert(ctx, uuidString)
+	if err != nil {
+		t.Fatalf("create test: %v", err)
+	}
+	questionId, err := questionData.Insert(ctx, uuidString)
+	if err != nil {
+		t.Fatalf("create question: %v", err)
+	}
+	if _, err := CreateTestQuestionary(ctx, uuidString, testId, []int64{questionId}); err != nil {
+		t.Fatalf("add question to test: %v", err)
+	}
+	question, err := FetchQuestion(ctx, uuidString, questionId)
+	if err != nil {
+		t.Fatalf("fetch question: %v", err)
+	}
+	return userId, testId, question
+}
+
+func TestSubmissionRoundTripsHostileAnswers(t *testing.T) {
+	connectTestDatabase(t)
+	ctx := context.Background()
+	uuidString := "test"
+
+	userId, testId, question := seedTestQuestion(t, ctx, "hostile-answers", QuestionCreateSchema{
+		Type:         "multiple_choice",
+		QuestionData: map[string]interface{}{"question": hostileStrings[0], "choices": hostileStrings},
+		AnswerData:   map[string]interface{}{"choices": hostileStrings[1:3]},
+	})
+
+	answerData := map[string][]string{"answer_data": hostileStrings[1:3]}
+	if _, err := CreateOrUpdateTestQuestionSubmission(ctx, uuidString, testId, userId, question.Id, answerData, question, `127.0.0.1`, hostileStrings[2]); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	submissions, _, err := FetchTestQuestionSubmissions(ctx, uuidString, testId, userId, Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("fetch submissions: %v", err)
+	}
+	if len(submissions) != 1 {
+		t.Fatalf("got %d submissions, want 1", len(submissions))
+	}
+	var submitted map[string][]string
+	if err := json.Unmarshal([]byte(submissions[0].SubmittedData), &submitted); err != nil {
+		t.Fatalf("decode submitted data %q: %v", submissions[0].SubmittedData, err)
+	}
+	if !reflect.DeepEqual(submitted, answerData) {
+		t.Errorf("submitted data = %v, want %v", submitted, answerData)
+	}
+	if !submissions[0].AnswerStatus {
+		t.Errorf("hostile answer was not graded correct")
+	}
+}
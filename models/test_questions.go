@@ -2,7 +2,6 @@ package models
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
@@ -22,44 +21,48 @@ type TestQuestionSchemaForTakeTest struct {
 	QuestionData QuestionResponseSchemaForTakeTest `json:"question_data"`
 }
 
-func CreateTestQuestionary(uuidString string, testId int64, questionIds []int64) (int64, error) {
-	var listOfQuestions []string
+func CreateTestQuestionary(ctx context.Context, uuidString string, testId int64, questionIds []int64) (int64, error) {
+	var listOfQuestions []Statement
 
 	if len(questionIds) == 0 {
 		return 0, nil
 	}
 	for _, questionId := range questionIds {
-		query := fmt.Sprintf(`INSERT INTO
+		query := `INSERT INTO
 				test_questions
 					(test_id, question_id)
 				VALUES
-					(%d, %d) RETURNING id`, testId, questionId)
-		listOfQuestions = append(listOfQuestions, query)
+					($1, $2) RETURNING id`
+		listOfQuestions = append(listOfQuestions, Statement{Query: query, Args: []any{testId, questionId}})
 	}
 	queryToExecute := QueryStructToExecute{QueryList: listOfQuestions}
-	id, err := queryToExecute.InsertOrUpdateMultipleQueries(uuidString)
+	id, err := queryToExecute.InsertOrUpdateMultipleQueries(ctx, uuidString)
 	return id, err
 
 }
 
-func DeleteTestQuestionary(uuidString string, testId int64, questionId int64) (bool, error) {
-	query := fmt.Sprintf(`DELETE FROM test_questions WHERE test_id = %d AND question_id = %d`, testId, questionId)
+func DeleteTestQuestionary(ctx context.Context, uuidString string, testId int64, questionId int64) (bool, error) {
+	query := `DELETE FROM test_questions WHERE test_id = $1 AND question_id = $2`
 	queryToExecute := QueryStructToExecute{Query: query}
-	status, err := queryToExecute.DeleteOperation(uuidString)
+	status, err := queryToExecute.DeleteOperation(ctx, uuidString, testId, questionId)
 	return status, err
 }
 
-func FetchTestQuestionaryForTeacher(uuidString string, testId int64, limit int, offset int) ([]TestQuestionsSchema, int, error) {
+const testQuestionaryCountQuery = `SELECT COUNT(*)
+							FROM test_questions tq
+							JOIN questions q on q.id = tq.question_id
+							WHERE tq.test_id = $1`
+
+func FetchTestQuestionaryForTeacher(ctx context.Context, uuidString string, testId int64, page Page) ([]TestQuestionsSchema, int, error) {
 	logger.Logger.Info("MODELS :: Will fetch questions for teacher ", zap.String("requestId", uuidString))
 
 	var data []TestQuestionsSchema
 	var count int
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 	// ctx := context.Background()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return data, count, err
@@ -72,22 +75,27 @@ func FetchTestQuestionaryForTeacher(uuidString string, testId int64, limit int,
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	count, err = countRows(ctx, tx, uuidString, page, testQuestionaryCountQuery, testId)
+	if err != nil {
+		return data, count, err
+	}
+
+	query := `SELECT
 							tq.id,
 							tq.test_id,
 							q.id,
 							q.type,
 							q.question_data,
 							q.answer_data,
-							COUNT(*) OVER() AS total
+							q.tags
 							FROM test_questions tq
 							JOIN tests t on t.id = tq.test_id
 							JOIN questions q on q.id = tq.question_id
-							WHERE tq.test_id = %d
-							ORDER BY tq.id DESC LIMIT %d OFFSET %d`, testId, limit, offset)
+							WHERE tq.test_id = $1 AND ($2::bigint = 0 OR tq.id < $2)
+							ORDER BY tq.id DESC LIMIT $3 OFFSET $4`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
 
-	rows, err := tx.Query(ctx, query)
+	rows, err := tx.Query(ctx, query, testId, page.After, page.Limit, page.offset())
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("requestId", uuidString), zap.String("query", query))
@@ -107,7 +115,7 @@ func FetchTestQuestionaryForTeacher(uuidString string, testId int64, limit int,
 			&singleData.QuestionData.Type,
 			&singleData.QuestionData.QuestionData,
 			&singleData.QuestionData.AnswerData,
-			&count,
+			&singleData.QuestionData.Tags,
 		)
 		if err != nil {
 			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
@@ -126,17 +134,16 @@ func FetchTestQuestionaryForTeacher(uuidString string, testId int64, limit int,
 	return data, count, nil
 }
 
-func FetchTestQuestionaryForStrudent(uuidString string, testId int64, limit int, offset int) ([]TestQuestionSchemaForTakeTest, int, error) {
+func FetchTestQuestionaryForStrudent(ctx context.Context, uuidString string, testId int64, page Page) ([]TestQuestionSchemaForTakeTest, int, error) {
 	logger.Logger.Info("MODELS :: Will fetch questions for student ", zap.String("requestId", uuidString))
 
 	var data []TestQuestionSchemaForTakeTest
 	var count int
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 	// ctx := context.Background()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return data, count, err
@@ -149,21 +156,25 @@ func FetchTestQuestionaryForStrudent(uuidString string, testId int64, limit int,
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	count, err = countRows(ctx, tx, uuidString, page, testQuestionaryCountQuery, testId)
+	if err != nil {
+		return data, count, err
+	}
+
+	query := `SELECT
 							tq.id,
 							tq.test_id,
 							q.id,
 							q.type,
-							q.question_data,
-							COUNT(*) OVER() AS total
+							q.question_data
 							FROM test_questions tq
 							JOIN tests t on t.id = tq.test_id
 							JOIN questions q on q.id = tq.question_id
-							WHERE tq.test_id = %d
-							ORDER BY tq.id DESC LIMIT %d OFFSET %d`, testId, limit, offset)
+							WHERE tq.test_id = $1 AND ($2::bigint = 0 OR tq.id < $2)
+							ORDER BY tq.id DESC LIMIT $3 OFFSET $4`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
 
-	rows, err := tx.Query(ctx, query)
+	rows, err := tx.Query(ctx, query, testId, page.After, page.Limit, page.offset())
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("requestId", uuidString), zap.String("query", query))
@@ -182,7 +193,6 @@ func FetchTestQuestionaryForStrudent(uuidString string, testId int64, limit int,
 			&singleData.QuestionData.Id,
 			&singleData.QuestionData.Type,
 			&singleData.QuestionData.QuestionData,
-			&count,
 		)
 		if err != nil {
 			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
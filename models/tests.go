@@ -2,7 +2,6 @@ package models
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
@@ -19,7 +18,7 @@ type TestResponseSchema struct {
 	Title string `json:"title"`
 }
 
-func (data TestCreateSchema) Insert(uuidString string) (int64, error) {
+func (data TestCreateSchema) Insert(ctx context.Context, uuidString string) (int64, error) {
 	query := `INSERT INTO
 				tests
 					(title)
@@ -27,37 +26,36 @@ func (data TestCreateSchema) Insert(uuidString string) (int64, error) {
 					($1)
 				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	id, err := queryToExecute.InsertOrUpdateOperations(uuidString, data.Title)
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, data.Title)
 	return id, err
 }
 
-func (data TestCreateSchema) Update(uuidString string, testId int64) (int64, error) {
+func (data TestCreateSchema) Update(ctx context.Context, uuidString string, testId int64) (int64, error) {
 	query := `UPDATE
 				tests
 					set title=$1
 				WHERE id=$2
 				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	id, err := queryToExecute.InsertOrUpdateOperations(uuidString, data.Title, testId)
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, data.Title, testId)
 	return id, err
 }
 
-func DeleteTest(uuidString string, testId int64) (bool, error) {
-	query := fmt.Sprintf(`DELETE FROM tests WHERE id=%d`, testId)
+func DeleteTest(ctx context.Context, uuidString string, testId int64) (bool, error) {
+	query := `DELETE FROM tests WHERE id=$1`
 	queryToExecute := QueryStructToExecute{Query: query}
-	status, err := queryToExecute.DeleteOperation(uuidString)
+	status, err := queryToExecute.DeleteOperation(ctx, uuidString, testId)
 	return status, err
 }
 
-func FetchTest(uuidString string, testId int64) (TestResponseSchema, error) {
+func FetchTest(ctx context.Context, uuidString string, testId int64) (TestResponseSchema, error) {
 	logger.Logger.Info("MODELS :: Will fetch test details ", zap.Int64("testId", testId), zap.String("requestId", uuidString))
 
 	var testData TestResponseSchema
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return testData, err
@@ -70,13 +68,13 @@ func FetchTest(uuidString string, testId int64) (TestResponseSchema, error) {
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	query := `SELECT
 							t.id,
 							t.title
 							FROM tests t
-							WHERE t.id=%d LIMIT 1`, testId)
+							WHERE t.id=$1 LIMIT 1`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
-	err = tx.QueryRow(ctx, query).Scan(
+	err = tx.QueryRow(ctx, query, testId).Scan(
 		&testData.Id,
 		&testData.Title,
 	)
@@ -93,16 +91,15 @@ func FetchTest(uuidString string, testId int64) (TestResponseSchema, error) {
 	return testData, nil
 }
 
-func FetchTests(uuidString string, limit int, offset int) ([]TestResponseSchema, int, error) {
+func FetchTests(ctx context.Context, uuidString string, page Page) ([]TestResponseSchema, int, error) {
 	logger.Logger.Info("MODELS :: Will fetch tests ", zap.String("requestId", uuidString))
 
 	var testData []TestResponseSchema
 	var count int
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return testData, count, err
@@ -115,15 +112,20 @@ func FetchTests(uuidString string, limit int, offset int) ([]TestResponseSchema,
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	count, err = countRows(ctx, tx, uuidString, page, `SELECT COUNT(*) FROM tests`)
+	if err != nil {
+		return testData, count, err
+	}
+
+	query := `SELECT
 							t.id,
-							t.title,
-							COUNT(*) OVER() AS total 
+							t.title
 							FROM tests t
-							ORDER BY id DESC LIMIT %d OFFSET %d`, limit, offset)
+							WHERE ($1::bigint = 0 OR t.id < $1)
+							ORDER BY t.id DESC LIMIT $2 OFFSET $3`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query), zap.String("requestId", uuidString))
 
-	rows, err := tx.Query(ctx, query)
+	rows, err := tx.Query(ctx, query, page.After, page.Limit, page.offset())
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			logger.Logger.Info("MODELS :: Query - No rows found. ", zap.String("requestId", uuidString), zap.String("query", query))
@@ -139,7 +141,6 @@ func FetchTests(uuidString string, limit int, offset int) ([]TestResponseSchema,
 		err := rows.Scan(
 			&singleTestData.Id,
 			&singleTestData.Title,
-			&count,
 		)
 		if err != nil {
 			logger.Logger.Error("MODELS :: Error while iterating rows", zap.String("requestId", uuidString), zap.Error(err))
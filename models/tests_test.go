@@ -0,0 +1,26 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTestTitleRoundTripsHostileInput(t *testing.T) {
+	connectTestDatabase(t)
+	ctx := context.Background()
+	uuidString := "test"
+
+	for _, hostile := range hostileStrings {
+		id, err := TestCreateSchema{Title: hostile}.Insert(ctx, uuidString)
+		if err != nil {
+			t.Fatalf("create test %q: %v", hostile, err)
+		}
+		stored, err := FetchTest(ctx, uuidString, id)
+		if err != nil {
+			t.Fatalf("fetch test %q: %v", hostile, err)
+		}
+		if stored.Title != hostile {
+			t.Errorf("title %q came back as %q", hostile, stored.Title)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+type requestIdKey struct{}
+
+type queryStartKey struct{}
+
+// WithRequestId returns a copy of ctx carrying the id of the HTTP request it
+// belongs to, so statements run for that request can be told apart in logs.
+func WithRequestId(ctx context.Context, uuidString string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, uuidString)
+}
+
+// RequestId returns the request id stored by WithRequestId, or "".
+func RequestId(ctx context.Context) string {
+	uuidString, _ := ctx.Value(requestIdKey{}).(string)
+	return uuidString
+}
+
+// queryTracer logs every statement with its duration and request id. Queries
+// stopped because the client went away or the request timed out are logged as
+// cancelled rather than failed.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, time.Now())
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	startedAt, _ := ctx.Value(queryStartKey{}).(time.Time)
+	fields := []zap.Field{
+		zap.String("requestId", RequestId(ctx)),
+		zap.Duration("duration", time.Since(startedAt)),
+		zap.String("commandTag", data.CommandTag.String()),
+	}
+	switch {
+	case data.Err == nil, errors.Is(data.Err, pgx.ErrNoRows):
+		logger.Logger.Debug("DATABASE :: Query executed", fields...)
+	case errors.Is(data.Err, context.Canceled), errors.Is(data.Err, context.DeadlineExceeded):
+		logger.Logger.Warn("DATABASE :: Query cancelled", append(fields, zap.Error(data.Err))...)
+	default:
+		logger.Logger.Debug("DATABASE :: Query failed", append(fields, zap.Error(data.Err))...)
+	}
+}
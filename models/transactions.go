@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	TXMAXATTEMPTS int = 5
+)
+
+type txKey struct{}
+
+// querier runs statements on the pool or on the transaction of a WithTx call.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns what the statements of ctx run on: the transaction started by
+// WithTx, or the primary pool.
+func conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return DbPool()
+}
+
+// writeConn is conn for statements that write, so later reads of the request
+// see them.
+func writeConn(ctx context.Context) querier {
+	markWritten(ctx)
+	return conn(ctx)
+}
+
+// beginTx starts the transaction of a model function. Inside WithTx it is a
+// savepoint of the surrounding transaction, so the function's commit only
+// takes effect when the whole unit of work commits. Otherwise read-only
+// transactions run on a healthy replica when there is one, and fall back to
+// the primary when the replica cannot be reached.
+func beginTx(ctx context.Context, options pgx.TxOptions) (pgx.Tx, error) {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx.Begin(ctx)
+	}
+	if options.AccessMode != pgx.ReadOnly {
+		markWritten(ctx)
+	} else if r := readReplica(ctx, options); r != nil {
+		tx, err := r.pool.BeginTx(ctx, options)
+		if err == nil {
+			return tx, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		r.setHealthy(false, zap.Error(err))
+	}
+	return DbPool().BeginTx(ctx, options)
+}
+
+// isRetryableTxError reports whether a transaction failed only because it
+// conflicted with a concurrent one and can safely be run again.
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	// serialization_failure and deadlock_detected
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
+
+// WithTx runs fn in one serializable transaction: every model function called
+// with the context passed to fn takes part in it. The transaction commits when
+// fn returns nil and rolls back when it returns an error or panics. When it
+// conflicts with a concurrent transaction, fn is run again, up to
+// TXMAXATTEMPTS times, so fn must not have side effects outside the database.
+// Called inside another WithTx, fn runs in a savepoint of the outer
+// transaction and retries are left to the outer call. A transaction is one
+// connection, so fn must not call models from several goroutines at once.
+func WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTx(ctx, pgx.Serializable, fn)
+}
+
+// WithReadCommittedTx is WithTx at READ COMMITTED, for units of work whose
+// statements already stay consistent under concurrent calls, such as an
+// upsert on a unique key. Concurrent calls wait for each other's row locks
+// instead of failing with serialization errors; deadlocks are still retried.
+func WithReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withTx(ctx, pgx.ReadCommitted, fn)
+}
+
+func withTx(ctx context.Context, isoLevel pgx.TxIsoLevel, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		return runTx(ctx, savepoint, fn)
+	}
+
+	markWritten(ctx)
+	var err error
+	for attempt := 1; attempt <= TXMAXATTEMPTS; attempt++ {
+		var tx pgx.Tx
+		tx, err = DbPool().BeginTx(ctx, pgx.TxOptions{IsoLevel: isoLevel, AccessMode: pgx.ReadWrite})
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", RequestId(ctx)))
+			return err
+		}
+		err = runTx(ctx, tx, fn)
+		if err == nil || !isRetryableTxError(err) || ctx.Err() != nil {
+			return err
+		}
+		logger.Logger.Info("MODELS :: Retrying transaction after conflict", zap.String("requestId", RequestId(ctx)), zap.Int("attempt", attempt), zap.Error(err))
+		select {
+		case <-time.After(time.Duration(attempt*10+rand.Intn(20)) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func runTx(ctx context.Context, tx pgx.Tx, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(ctx)
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback(ctx)
+			return
+		}
+		if err = tx.Commit(ctx); err != nil {
+			err = fmt.Errorf("commit transaction: %w", err)
+		}
+	}()
+	return fn(context.WithValue(ctx, txKey{}, tx))
+}
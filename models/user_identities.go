@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+var ErrIdentityEmailConflict = errors.New("an account with this email already exists")
+
+// ExternalIdentitySchema is a user as asserted by an external identity
+// provider. Type is the user type mapped from the provider's claims.
+type ExternalIdentitySchema struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	Type          int
+}
+
+// Provision returns the local user linked to the external identity, creating
+// it just in time on first login. An existing account is linked by email only
+// when the provider has verified the address; otherwise ErrIdentityEmailConflict
+// is returned. With syncRole the mapped type overwrites the stored one.
+func (data ExternalIdentitySchema) Provision(ctx context.Context, uuidString string, syncRole bool) (int64, error) {
+	logger.Logger.Info("MODELS :: Will provision external identity", zap.String("requestId", uuidString), zap.String("issuer", data.Issuer), zap.String("subject", data.Subject))
+
+	var userId int64
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return userId, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	identityQuery := `UPDATE
+						user_identities
+							SET last_login_at=NOW(), email=$3
+						WHERE issuer=$1 AND subject=$2
+						RETURNING user_id`
+	err = tx.QueryRow(ctx, identityQuery, data.Issuer, data.Subject, data.Email).Scan(&userId)
+	if err != nil && err != pgx.ErrNoRows {
+		logger.Logger.Error("MODELS :: Error while fetching identity.", zap.String("requestId", uuidString), zap.Error(err))
+		return userId, err
+	}
+
+	if err == pgx.ErrNoRows {
+		var emailVerified bool
+		userQuery := `SELECT u.id, u.email_verified_at IS NOT NULL FROM users u WHERE u.email=$1 FOR UPDATE`
+		err = tx.QueryRow(ctx, userQuery, data.Email).Scan(&userId, &emailVerified)
+		if err != nil && err != pgx.ErrNoRows {
+			logger.Logger.Error("MODELS :: Error while fetching user by email.", zap.String("requestId", uuidString), zap.Error(err))
+			return userId, err
+		}
+
+		if err == pgx.ErrNoRows {
+			insertUserQuery := `INSERT INTO
+									users
+										(first_name, last_name, email, password, type, email_verified_at)
+									VALUES
+										($1, $2, $3, '', $4, CASE WHEN $5::BOOLEAN THEN NOW() END)
+									RETURNING id`
+			err = tx.QueryRow(ctx, insertUserQuery, data.FirstName, data.LastName, data.Email, data.Type, data.EmailVerified).Scan(&userId)
+			if err != nil {
+				logger.Logger.Error("MODELS :: Error while creating user.", zap.String("requestId", uuidString), zap.Error(err))
+				return userId, err
+			}
+		} else if !data.EmailVerified {
+			return 0, ErrIdentityEmailConflict
+		} else {
+			if !emailVerified {
+				_, err = tx.Exec(ctx, `UPDATE users SET email_verified_at=NOW() WHERE id=$1`, userId)
+				if err != nil {
+					logger.Logger.Error("MODELS :: Error while verifying email.", zap.String("requestId", uuidString), zap.Error(err))
+					return userId, err
+				}
+			}
+			if syncRole {
+				_, err = tx.Exec(ctx, `UPDATE users SET type=$2 WHERE id=$1`, userId, data.Type)
+				if err != nil {
+					logger.Logger.Error("MODELS :: Error while updating user type.", zap.String("requestId", uuidString), zap.Error(err))
+					return userId, err
+				}
+			}
+		}
+
+		insertIdentityQuery := `INSERT INTO
+									user_identities
+										(user_id, issuer, subject, email)
+									VALUES
+										($1, $2, $3, $4)`
+		_, err = tx.Exec(ctx, insertIdentityQuery, userId, data.Issuer, data.Subject, data.Email)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while linking identity.", zap.String("requestId", uuidString), zap.Error(err))
+			return userId, err
+		}
+		if err = tx.Commit(ctx); err != nil {
+			logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+			return userId, err
+		}
+		return userId, nil
+	}
+
+	if syncRole {
+		_, err = tx.Exec(ctx, `UPDATE users SET type=$2 WHERE id=$1`, userId, data.Type)
+		if err != nil {
+			logger.Logger.Error("MODELS :: Error while updating user type.", zap.String("requestId", uuidString), zap.Error(err))
+			return userId, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return userId, err
+	}
+	return userId, nil
+}
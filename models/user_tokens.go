@@ -0,0 +1,124 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	TOKENPURPOSEEMAILVERIFICATION string = "email_verification"
+	TOKENPURPOSEPASSWORDRESET     string = "password_reset"
+	TOKENPURPOSEMFACHALLENGE      string = "mfa_challenge"
+)
+
+type EmailSchema struct {
+	Email string `json:"email" form:"email"`
+}
+
+type TokenSchema struct {
+	Token string `json:"token" form:"token"`
+}
+
+type PasswordResetConfirmSchema struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+type PasswordChangeSchema struct {
+	CurrentPassword string `json:"current_password" form:"current_password"`
+	NewPassword     string `json:"new_password" form:"new_password"`
+}
+
+// CreateUserToken stores the hash of a new single use token. Tokens of the same
+// purpose that are still outstanding for the user are invalidated, so only the
+// most recently sent link works.
+func CreateUserToken(ctx context.Context, uuidString string, userId int64, purpose string, tokenHash string, expiresAt time.Time) (int64, error) {
+	logger.Logger.Info("MODELS :: Will create user token", zap.String("requestId", uuidString), zap.Int64("userId", userId), zap.String("purpose", purpose))
+
+	var id int64
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadWrite})
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err), zap.String("requestId", uuidString))
+		return id, err
+	}
+	// Rolling back after a successful commit is a no-op.
+	defer tx.Rollback(ctx)
+
+	invalidateQuery := `UPDATE
+							user_tokens
+								SET used_at=NOW()
+							WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL`
+	_, err = tx.Exec(ctx, invalidateQuery, userId, purpose)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while invalidating user tokens.", zap.String("requestId", uuidString), zap.Error(err))
+		return id, err
+	}
+
+	insertQuery := `INSERT INTO
+						user_tokens
+							(user_id, purpose, token_hash, expires_at)
+						VALUES
+							($1, $2, $3, $4)
+						RETURNING id`
+	err = tx.QueryRow(ctx, insertQuery, userId, purpose, tokenHash, expiresAt).Scan(&id)
+	if err != nil {
+		logger.Logger.Error("MODELS :: Error while executing query.", zap.String("requestId", uuidString), zap.Error(err))
+		return id, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		logger.Logger.Error("MODELS :: Error while committing transaction", zap.String("requestId", uuidString), zap.Error(err))
+		return id, err
+	}
+	return id, nil
+}
+
+// consumeUserTokenAndUpdate marks a token as used and applies updateSet to the
+// user it was issued to, in a single statement. It returns 0 when the token is
+// unknown, already used or expired.
+func consumeUserTokenAndUpdate(ctx context.Context, uuidString string, purpose string, tokenHash string, updateSet string, args ...any) (int64, error) {
+	query := `WITH consumed AS (
+					UPDATE user_tokens
+						SET used_at=NOW()
+					WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > NOW()
+					RETURNING user_id
+				)
+				UPDATE
+					users u
+						SET ` + updateSet + `
+				FROM consumed c
+				WHERE u.id = c.user_id AND u.deactivated_at IS NULL
+				RETURNING u.id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, append([]any{tokenHash, purpose}, args...)...)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+func VerifyEmailWithToken(ctx context.Context, uuidString string, tokenHash string) (int64, error) {
+	return consumeUserTokenAndUpdate(ctx, uuidString, TOKENPURPOSEEMAILVERIFICATION, tokenHash, `email_verified_at=COALESCE(u.email_verified_at, NOW())`)
+}
+
+func ResetPasswordWithToken(ctx context.Context, uuidString string, tokenHash string, passwordHash string) (int64, error) {
+	return consumeUserTokenAndUpdate(ctx, uuidString, TOKENPURPOSEPASSWORDRESET, tokenHash, `password=$3, password_reset_required=false`, passwordHash)
+}
+
+func UpdateUserPassword(ctx context.Context, uuidString string, userId int64, passwordHash string) (int64, error) {
+	query := `UPDATE
+				users
+					SET password=$1, password_reset_required=false
+				WHERE id=$2
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, passwordHash, userId)
+	return id, err
+}
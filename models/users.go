@@ -2,7 +2,7 @@ package models
 
 import (
 	"context"
-	"fmt"
+	"strconv"
 	"time"
 
 	pgx "github.com/jackc/pgx/v5"
@@ -11,8 +11,10 @@ import (
 )
 
 const (
-	STUDENT int = 1
-	TEACHER int = 2
+	STUDENT           int = 1
+	TEACHER           int = 2
+	ADMIN             int = 3
+	TEACHINGASSISTANT int = 4
 )
 
 func ValidateUserType(userType int) string {
@@ -20,6 +22,10 @@ func ValidateUserType(userType int) string {
 		return "teacher"
 	} else if userType == STUDENT {
 		return "student"
+	} else if userType == ADMIN {
+		return "admin"
+	} else if userType == TEACHINGASSISTANT {
+		return "teaching_assistant"
 	} else {
 		return ""
 	}
@@ -30,11 +36,22 @@ func GetUserType(userType string) int {
 		return TEACHER
 	} else if userType == "student" {
 		return STUDENT
+	} else if userType == "admin" {
+		return ADMIN
+	} else if userType == "teaching_assistant" {
+		return TEACHINGASSISTANT
 	} else {
 		return 0
 	}
 }
 
+// IsSelfRegistrableUserType reports whether an account of the given type can be
+// created through the open registration endpoint. Privileged roles are only
+// granted by an admin.
+func IsSelfRegistrableUserType(userType int) bool {
+	return userType == STUDENT || userType == TEACHER
+}
+
 type UserCreateSchema struct {
 	FirstName string `json:"first_name" form:"first_name"`
 	LastName  string `json:"last_name" form:"last_name"`
@@ -44,12 +61,26 @@ type UserCreateSchema struct {
 }
 
 type UserSchema struct {
-	Id        int64  `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-	Type      string `json:"type"`
+	Id                    int64        `json:"id"`
+	FirstName             string       `json:"first_name"`
+	LastName              string       `json:"last_name"`
+	Email                 string       `json:"email"`
+	Password              string       `json:"password"`
+	Type                  string       `json:"type"`
+	PasswordResetRequired bool         `json:"password_reset_required"`
+	EmailVerified         bool         `json:"email_verified"`
+	SessionId             string       `json:"-"`
+	APIKeyId              int64        `json:"-"`
+	APIKeyScopes          []Permission `json:"-"`
+}
+
+// UserType returns the numeric role stored in the users.type column.
+func (data UserSchema) UserType() int {
+	userType, err := strconv.Atoi(data.Type)
+	if err != nil {
+		return 0
+	}
+	return userType
 }
 
 type UserResponseSchema struct {
@@ -60,7 +91,7 @@ type UserResponseSchema struct {
 	Type      string `json:"type"`
 }
 
-func (data UserCreateSchema) Insert(uuidString string) (int64, error) {
+func (data UserCreateSchema) Insert(ctx context.Context, uuidString string) (int64, error) {
 	query := `INSERT INTO 
 				users
 					(first_name, last_name, email, password, type)
@@ -68,39 +99,78 @@ func (data UserCreateSchema) Insert(uuidString string) (int64, error) {
 					($1, $2, $3, $4, $5)
 				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	id, err := queryToExecute.InsertOrUpdateOperations(uuidString, data.FirstName, data.LastName, data.Email, data.Password, GetUserType(data.Type))
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, data.FirstName, data.LastName, data.Email, data.Password, GetUserType(data.Type))
 	return id, err
 }
 
-func (data UserCreateSchema) Update(uuidString string, id int64) (int64, error) {
+func (data UserCreateSchema) Update(ctx context.Context, uuidString string, id int64) (int64, error) {
 	query := `UPDATE 
 				users
 					SET first_name=$1, last_name=$2
 				WHERE id=$3
 				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	id, err := queryToExecute.InsertOrUpdateOperations(uuidString, data.FirstName, data.LastName, id)
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, data.FirstName, data.LastName, id)
 	return id, err
 
 }
 
-func DeleteUserFromDB(uuidString string, userId int64) (bool, error) {
-	query := fmt.Sprintf(`DELETE FROM users WHERE id=%d`, userId)
+// DeactivateUser soft deletes an account. The row and everything that
+// references it (submissions, history) are kept, the user just can no longer
+// authenticate.
+func DeactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	query := `UPDATE
+				users
+					SET deactivated_at=COALESCE(deactivated_at, NOW())
+				WHERE id=$1
+				RETURNING id`
 	queryToExecute := QueryStructToExecute{Query: query}
-	status, err := queryToExecute.DeleteOperation(uuidString)
-	return status, err
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userId)
+	return id, err
+}
+
+func ReactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	query := `UPDATE
+				users
+					SET deactivated_at=NULL
+				WHERE id=$1
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userId)
+	return id, err
+}
+
+func UpdateUserType(ctx context.Context, uuidString string, userId int64, userType int) (int64, error) {
+	query := `UPDATE
+				users
+					SET type=$1
+				WHERE id=$2
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, userType, userId)
+	return id, err
+}
+
+func SetPasswordResetRequired(ctx context.Context, uuidString string, userId int64, required bool) (int64, error) {
+	query := `UPDATE
+				users
+					SET password_reset_required=$1
+				WHERE id=$2
+				RETURNING id`
+	queryToExecute := QueryStructToExecute{Query: query}
+	id, err := queryToExecute.InsertOrUpdateOperations(ctx, uuidString, required, userId)
+	return id, err
 }
 
-func FetchUserForAuth(email string) UserSchema {
+func FetchUserForAuth(ctx context.Context, email string) UserSchema {
 	logger.Logger.Info("MODELS :: Will fetch user details for auth", zap.String("email", email))
 
 	var userData UserSchema
-	dbConnection := DbPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 	// ctx := context.Background()
 
-	tx, err := dbConnection.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	tx, err := beginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
 	if err != nil {
 		logger.Logger.Error("MODELS :: Error while begin transaction", zap.Error(err))
 		return userData
@@ -113,23 +183,27 @@ func FetchUserForAuth(email string) UserSchema {
 		}
 	}()
 
-	query := fmt.Sprintf(`SELECT
+	query := `SELECT
 							u.id,
 							u.first_name, 
 							u.last_name, 
 							u.email, 
 							u.password,
-							u.type
+							u.type,
+							u.password_reset_required,
+							u.email_verified_at IS NOT NULL
 							FROM users u
-							WHERE u.email='%s' LIMIT 1`, email)
+							WHERE u.email=$1 AND u.deactivated_at IS NULL LIMIT 1`
 	logger.Logger.Info("MODELS :: Query", zap.String("query", query))
-	err = tx.QueryRow(ctx, query).Scan(
+	err = tx.QueryRow(ctx, query, email).Scan(
 		&userData.Id,
 		&userData.FirstName,
 		&userData.LastName,
 		&userData.Email,
 		&userData.Password,
 		&userData.Type,
+		&userData.PasswordResetRequired,
+		&userData.EmailVerified,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestUserRoundTripsHostileInput(t *testing.T) {
+	connectTestDatabase(t)
+	ctx := context.Background()
+	uuidString := "test"
+
+	for _, hostile := range hostileStrings {
+		user := UserCreateSchema{
+			FirstName: hostile,
+			LastName:  hostile,
+			Email:     uniqueEmail(hostile),
+			Password:  hostile,
+			Type:      "student",
+		}
+		id, err := user.Insert(ctx, uuidString)
+		if err != nil {
+			t.Fatalf("create user %q: %v", hostile, err)
+		}
+
+		stored := FetchUserForAuth(ctx, user.Email)
+		if stored.Id != id || stored.Email != user.Email || stored.FirstName != hostile || stored.LastName != hostile || stored.Password != hostile {
+			t.Errorf("user %q came back as %+v", hostile, stored)
+		}
+	}
+
+	// An injected condition must not match an account.
+	for _, email := range []string{`' OR '1'='1`, `' OR 1=1 --`, `x@example.com' OR 'a'='a`} {
+		if stored := FetchUserForAuth(ctx, email); stored.Id != 0 {
+			t.Errorf("FetchUserForAuth(%q) found user %d", email, stored.Id)
+		}
+	}
+}
+
+func TestPasswordHashesAreNotLogged(t *testing.T) {
+	connectTestDatabase(t)
+	ctx := context.Background()
+	uuidString := "test"
+
+	observed, logs := observer.New(zap.DebugLevel)
+	previousLogger := logger.Logger
+	logger.Logger = zap.New(observed)
+	t.Cleanup(func() { logger.Logger = previousLogger })
+
+	const createdHash, changedHash = "$2a$04$created-hash", "$2a$04$changed-hash"
+	id, err := UserCreateSchema{
+		FirstName: "Logged",
+		LastName:  "User",
+		Email:     uniqueEmail("logged"),
+		Password:  createdHash,
+		Type:      "student",
+	}.Insert(ctx, uuidString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdateUserPassword(ctx, uuidString, id, changedHash); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range logs.All() {
+		for key, value := range entry.ContextMap() {
+			text := fmt.Sprint(value)
+			if strings.Contains(text, createdHash) || strings.Contains(text, changedHash) {
+				t.Errorf("log %q has a password hash in %s: %s", entry.Message, key, text)
+			}
+		}
+	}
+}
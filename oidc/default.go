@@ -0,0 +1,46 @@
+package oidc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	defaultProviderMu sync.Mutex
+	defaultProvider   *Provider
+)
+
+// DefaultProvider returns the provider from the oidc settings. Discovery runs
+// on first use rather than at start up, so an unreachable identity provider
+// only breaks SSO login and is retried on the next attempt.
+func DefaultProvider(ctx context.Context) (*Provider, error) {
+	oidcConfig := core.Config.OIDC
+	if !oidcConfig.Enabled {
+		return nil, ErrDisabled
+	}
+
+	defaultProviderMu.Lock()
+	defer defaultProviderMu.Unlock()
+	if defaultProvider != nil {
+		return defaultProvider, nil
+	}
+
+	provider, err := NewProvider(ctx, Config{
+		Issuer:       oidcConfig.Issuer,
+		ClientID:     oidcConfig.ClientID,
+		ClientSecret: oidcConfig.ClientSecret,
+		RedirectURL:  oidcConfig.RedirectURL,
+		Scopes:       oidcConfig.Scopes,
+	})
+	if err != nil {
+		logger.Logger.Error("OIDC :: Error while loading provider configuration", zap.String("issuer", oidcConfig.Issuer), zap.Error(err))
+		return nil, err
+	}
+	logger.Logger.Info("OIDC :: Provider configured Successfully", zap.String("issuer", oidcConfig.Issuer))
+	defaultProvider = provider
+	return defaultProvider, nil
+}
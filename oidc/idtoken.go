@@ -0,0 +1,106 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+// Claims are the ID token claims used for login and provisioning. Raw keeps
+// every claim so that a configurable claim can be used for role mapping.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Name          string
+	Raw           map[string]any
+}
+
+// VerifyIDToken validates the signature of an ID token against the provider's
+// JWKS and checks issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, nonce string) (Claims, error) {
+	var claims Claims
+
+	mapClaims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	_, err := parser.ParseWithClaims(rawIDToken, mapClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return claims, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+
+	now := time.Now().Unix()
+	if !mapClaims.VerifyIssuer(p.Issuer, true) {
+		return claims, errors.New("oidc: id token issuer mismatch")
+	}
+	if !mapClaims.VerifyAudience(p.ClientID, true) {
+		return claims, errors.New("oidc: id token audience mismatch")
+	}
+	if audiences, ok := mapClaims["aud"].([]interface{}); ok && len(audiences) > 1 {
+		if azp, _ := mapClaims["azp"].(string); azp != p.ClientID {
+			return claims, errors.New("oidc: id token authorized party mismatch")
+		}
+	}
+	if !mapClaims.VerifyExpiresAt(now, true) {
+		return claims, errors.New("oidc: id token expired")
+	}
+	if tokenNonce, _ := mapClaims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return claims, errors.New("oidc: id token nonce mismatch")
+	}
+
+	claims.Subject, _ = mapClaims["sub"].(string)
+	if claims.Subject == "" {
+		return claims, errors.New("oidc: id token has no subject")
+	}
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.GivenName, _ = mapClaims["given_name"].(string)
+	claims.FamilyName, _ = mapClaims["family_name"].(string)
+	claims.Name, _ = mapClaims["name"].(string)
+	switch verified := mapClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+	claims.Raw = map[string]any(mapClaims)
+	return claims, nil
+}
+
+// ClaimValues returns the string values of a claim, which may be a single
+// string or an array. Nested claims are addressed with dots, for example
+// "realm_access.roles".
+func (c Claims) ClaimValues(name string) []string {
+	if name == "" {
+		return nil
+	}
+	var value any = c.Raw
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/open-lms-test-functionality/utils"
+)
+
+// NewCodeVerifier returns a PKCE code verifier (RFC 7636). 32 random bytes
+// encode to 43 characters, the minimum allowed length.
+func NewCodeVerifier() (string, error) {
+	return utils.RandomString(32)
+}
+
+// CodeChallengeS256 derives the S256 code challenge sent with the
+// authorization request.
+func CodeChallengeS256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
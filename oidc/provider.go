@@ -0,0 +1,155 @@
+// Package oidc implements the relying party side of the OpenID Connect
+// authorization code flow with PKCE: discovery, the token exchange and ID
+// token validation against the provider's JWKS.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrDisabled = errors.New("oidc login is not enabled")
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an identity provider whose endpoints were read from its
+// discovery document.
+type Provider struct {
+	Config
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+
+	httpClient *http.Client
+	keys       *KeySet
+}
+
+// TokenResponse is the token endpoint's answer to an authorization code
+// exchange.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// NewProvider fetches the discovery document of config.Issuer. The issuer in
+// the document must match the configured one exactly.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var document discoveryDocument
+	if err := doJSON(httpClient, req, &document); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if document.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match configured issuer %q", document.Issuer, config.Issuer)
+	}
+	if document.AuthorizationEndpoint == "" || document.TokenEndpoint == "" || document.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: document is missing required endpoints")
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Config:                config,
+		AuthorizationEndpoint: document.AuthorizationEndpoint,
+		TokenEndpoint:         document.TokenEndpoint,
+		JWKSURI:               document.JWKSURI,
+		httpClient:            httpClient,
+		keys:                  NewKeySet(document.JWKSURI, httpClient),
+	}, nil
+}
+
+// AuthCodeURL returns the URL the browser is sent to for login.
+func (p *Provider) AuthCodeURL(state string, nonce string, codeChallenge string) string {
+	values := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// Exchange trades an authorization code for tokens, proving possession of the
+// PKCE code verifier.
+func (p *Provider) Exchange(ctx context.Context, code string, codeVerifier string) (TokenResponse, error) {
+	var tokenResponse TokenResponse
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	if err := doJSON(p.httpClient, req, &tokenResponse); err != nil {
+		return tokenResponse, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return tokenResponse, errors.New("oidc token exchange: response has no id_token")
+	}
+	return tokenResponse, nil
+}
+
+func doJSON(httpClient *http.Client, req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
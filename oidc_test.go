@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/oidc/oidctest"
+	"github.com/open-lms-test-functionality/schemas"
+)
+
+var (
+	testIdPOnce sync.Once
+	testIdP     *oidctest.Server
+)
+
+// identityProvider enables single sign-on against a mock provider. The oidc
+// package keeps the provider it discovered first, so all tests share one.
+func (s *testServer) identityProvider(claims map[string]any) *oidctest.Server {
+	s.t.Helper()
+	testIdPOnce.Do(func() {
+		testIdP = oidctest.NewServer("lms", "lms-secret")
+	})
+	core.Config.OIDC = schemas.OIDCConfig{
+		Enabled:      true,
+		Issuer:       testIdP.Issuer(),
+		ClientID:     testIdP.ClientID,
+		ClientSecret: testIdP.ClientSecret,
+		RedirectURL:  "http://lms.test/oidc/callback",
+		DefaultRole:  "student",
+	}
+	testIdP.SetClaims(claims)
+	return testIdP
+}
+
+// oidcLogin starts a login and follows the provider's redirect. It returns the
+// callback URL the browser would be sent to and the state cookie it holds.
+func (s *testServer) oidcLogin() (*url.URL, *http.Cookie) {
+	s.t.Helper()
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	if recorder.Code != http.StatusFound {
+		s.t.Fatalf("GET /oidc/login: status %d, %s", recorder.Code, recorder.Body.String())
+	}
+	var stateCookie *http.Cookie
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == "oidc_login" {
+			stateCookie = cookie
+		}
+	}
+	if stateCookie == nil {
+		s.t.Fatalf("GET /oidc/login: no state cookie in %v", recorder.Result().Cookies())
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(recorder.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatalf("authorize: %v", err)
+	}
+	response.Body.Close()
+	callback, err := response.Location()
+	if err != nil {
+		s.t.Fatalf("authorize: status %d, %v", response.StatusCode, err)
+	}
+	return callback, stateCookie
+}
+
+// oidcCallback requests the callback URL with the state cookie, when it is
+// not nil.
+func (s *testServer) oidcCallback(callback *url.URL, stateCookie *http.Cookie) (int, map[string]any) {
+	s.t.Helper()
+	request := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	if stateCookie != nil {
+		request.AddCookie(stateCookie)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	var response map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		s.t.Fatalf("GET /oidc/callback: decoding %q: %v", recorder.Body.String(), err)
+	}
+	return recorder.Code, response
+}
+
+func TestOIDCCallbackSignsIn(t *testing.T) {
+	s := newTestServer(t)
+	s.identityProvider(map[string]any{"sub": "sso-1", "email": "sso@example.com", "email_verified": true})
+
+	callback, stateCookie := s.oidcLogin()
+	code, response := s.oidcCallback(callback, stateCookie)
+	if code != http.StatusOK {
+		t.Fatalf("GET /oidc/callback: status %d, %v", code, response)
+	}
+	code, response = s.do(http.MethodGet, "/auth/me", response["token"].(string), nil)
+	if code != http.StatusOK || response["message"].(map[string]any)["email"] != "sso@example.com" {
+		t.Fatalf("GET /auth/me: status %d, %v", code, response)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	s := newTestServer(t)
+	s.identityProvider(map[string]any{"sub": "sso-1", "email": "sso@example.com", "email_verified": true})
+
+	callback, _ := s.oidcLogin()
+	if code, response := s.oidcCallback(callback, nil); code != http.StatusBadRequest {
+		t.Fatalf("callback without state cookie: status %d, want 400, %v", code, response)
+	}
+
+	// A callback for a login started in another browser.
+	_, otherCookie := s.oidcLogin()
+	if code, response := s.oidcCallback(callback, otherCookie); code != http.StatusBadRequest {
+		t.Fatalf("callback with another login's state: status %d, want 400, %v", code, response)
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidIDTokens(t *testing.T) {
+	for name, claims := range map[string]map[string]any{
+		"nonce mismatch": {"nonce": "another-login"},
+		"expired":        {"exp": time.Now().Add(-time.Minute).Unix()},
+		"wrong audience": {"aud": "another-client"},
+		"wrong issuer":   {"iss": "https://idp.example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t)
+			claims["sub"] = "sso-1"
+			claims["email"] = "sso@example.com"
+			claims["email_verified"] = true
+			s.identityProvider(claims)
+
+			callback, stateCookie := s.oidcLogin()
+			if code, response := s.oidcCallback(callback, stateCookie); code != http.StatusUnauthorized {
+				t.Fatalf("GET /oidc/callback: status %d, want 401, %v", code, response)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackDoesNotLinkUnverifiedEmail(t *testing.T) {
+	s := newTestServer(t)
+	s.register("owner@example.com", "secret-password", "student")
+	s.identityProvider(map[string]any{"sub": "sso-1", "email": "owner@example.com", "email_verified": false})
+
+	callback, stateCookie := s.oidcLogin()
+	if code, response := s.oidcCallback(callback, stateCookie); code != http.StatusConflict {
+		t.Fatalf("callback with an unverified existing email: status %d, want 409, %v", code, response)
+	}
+
+	// With a verified email the provider vouches for the address and the
+	// account is linked.
+	s.identityProvider(map[string]any{"sub": "sso-1", "email": "owner@example.com", "email_verified": true})
+	callback, stateCookie = s.oidcLogin()
+	code, response := s.oidcCallback(callback, stateCookie)
+	if code != http.StatusOK {
+		t.Fatalf("callback with a verified existing email: status %d, %v", code, response)
+	}
+}
+
+func TestOIDCCallbackAsksForRoleSecondFactor(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.stores.MFA.UpdateRoleMFARequired(context.Background(), "", models.STUDENT, true); err != nil {
+		t.Fatal(err)
+	}
+	s.identityProvider(map[string]any{"sub": "sso-1", "email": "sso@example.com", "email_verified": true})
+
+	callback, stateCookie := s.oidcLogin()
+	code, response := s.oidcCallback(callback, stateCookie)
+	if code != http.StatusOK || response["mfa_required"] != true || response["mfa_enrollment_required"] != true || response["token"] != nil {
+		t.Fatalf("GET /oidc/callback: status %d, %v, want an mfa challenge", code, response)
+	}
+	code, response = s.do(http.MethodPost, "/token/mfa/enroll", "", map[string]string{"mfa_token": response["mfa_token"].(string)})
+	if code != http.StatusOK {
+		t.Fatalf("POST /token/mfa/enroll: status %d, %v", code, response)
+	}
+}
@@ -9,11 +9,125 @@ type DBConfig struct {
 	DBSSLMode  string `json:"db_ssl_mode"`
 }
 
+// DBReplicaConfig is a read replica of the primary database. Settings left
+// empty are taken from the primary, so usually only the host is set.
+type DBReplicaConfig = DBConfig
+
+type MailConfig struct {
+	Driver       string `json:"driver"` // "smtp" or "log"
+	From         string `json:"from"`
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     string `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"smtp_password"`
+	LogDirectory string `json:"log_directory"`
+}
+
+// CacheConfig selects where cached users and questionaries are kept: "memory"
+// (an LRU of Size entries inside the process), "redis" (a shared server at
+// Addr) or "none". Entries expire after TTLSeconds.
+type CacheConfig struct {
+	Driver     string `json:"driver"`
+	Addr       string `json:"addr"`
+	Password   string `json:"password"`
+	DB         int    `json:"db"`
+	Size       int    `json:"size"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider.
+// RoleMapping maps values of RoleClaim to user types ("student", "teacher",
+// ...); users without a mapped value get DefaultRole.
+type OIDCConfig struct {
+	Enabled      bool              `json:"enabled"`
+	Issuer       string            `json:"issuer"`
+	ClientID     string            `json:"client_id"`
+	ClientSecret string            `json:"client_secret"`
+	RedirectURL  string            `json:"redirect_url"`
+	Scopes       []string          `json:"scopes"`
+	RoleClaim    string            `json:"role_claim"`
+	RoleMapping  map[string]string `json:"role_mapping"`
+	DefaultRole  string            `json:"default_role"`
+	SyncRole     bool              `json:"sync_role"` // re-apply the mapped role on every login
+}
+
+// LoginProtectionConfig limits failed logins per account and per client IP.
+// After BackoffAfter failures every further failure blocks the key for an
+// exponentially growing delay; MaxFailures locks it for LockoutMinutes.
+// Failures older than FailureWindowMinutes are forgotten.
+type LoginProtectionConfig struct {
+	AccountMaxFailures   int `json:"account_max_failures"`
+	AccountBackoffAfter  int `json:"account_backoff_after"`
+	IPMaxFailures        int `json:"ip_max_failures"`
+	IPBackoffAfter       int `json:"ip_backoff_after"`
+	BackoffBaseSeconds   int `json:"backoff_base_seconds"`
+	BackoffMaxSeconds    int `json:"backoff_max_seconds"`
+	LockoutMinutes       int `json:"lockout_minutes"`
+	FailureWindowMinutes int `json:"failure_window_minutes"`
+}
+
+// LTIPlatformConfig is a learning platform (Canvas, Moodle, ...) the tool is
+// registered with. AuthTokenAudience defaults to AuthTokenURL.
+type LTIPlatformConfig struct {
+	Issuer            string   `json:"issuer"`
+	ClientID          string   `json:"client_id"`
+	DeploymentIDs     []string `json:"deployment_ids"`
+	AuthLoginURL      string   `json:"auth_login_url"`
+	AuthTokenURL      string   `json:"auth_token_url"`
+	AuthTokenAudience string   `json:"auth_token_audience"`
+	JWKSURL           string   `json:"jwks_url"`
+}
+
+// LTIConfig configures the LTI 1.3 tool. Without PrivateKeyPath a key is
+// generated at start up, which only suits local development because platforms
+// cache the tool's public key.
+type LTIConfig struct {
+	Enabled        bool                `json:"enabled"`
+	LaunchURL      string              `json:"launch_url"`
+	PrivateKeyPath string              `json:"private_key_path"`
+	KeyId          string              `json:"key_id"`
+	Platforms      []LTIPlatformConfig `json:"platforms"`
+}
+
 type ProjectConfiguration struct {
-	Environment      string   `json:"environment"`
-	DBConfig         DBConfig `json:"database"`
-	DBString         string   `json:"database_string,omitempty"`
-	PasswordHashCost int      `json:"password_hash_cost"`
-	AuthRealm        string   `json:"auth_realm"`
-	AuthSecretKey    string   `json:"auth_secret_key"`
+	Environment           string   `json:"environment"`
+	DBConfig              DBConfig `json:"database"`
+	DBString              string   `json:"database_string,omitempty"`
+	PasswordHashCost      int      `json:"password_hash_cost"`
+	AuthRealm             string   `json:"auth_realm"`
+	AuthSecretKey         string   `json:"auth_secret_key"`
+	FastAnswerThresholdMs int64    `json:"fast_answer_threshold_ms"`
+	FastAnswerFlagCount   int64    `json:"fast_answer_flag_count"`
+	IntegrityMaxEvents    int64    `json:"integrity_max_events"`
+	IntegrityMaxIPChanges int64    `json:"integrity_max_ip_changes"`
+
+	DBReplicas             []DBReplicaConfig `json:"database_replicas"`
+	DBReplicaStrings       []string          `json:"-"`
+	DBReplicaMaxLagSeconds int               `json:"database_replica_max_lag_seconds"` // replicas further behind are skipped
+	DBConnectAttempts      int               `json:"database_connect_attempts"`        // startup gives up after these pings failed
+
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds"` // in-flight requests get this long to finish on SIGTERM
+
+	// Addresses or CIDRs of the reverse proxies in front of the service. Only
+	// their X-Forwarded-For header is believed for the client IP.
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	AppBaseURL                string     `json:"app_base_url"`
+	Mail                      MailConfig `json:"mail"`
+	RequireEmailVerification  bool       `json:"require_email_verification"`
+	EmailVerificationTTLHours int        `json:"email_verification_ttl_hours"`
+	PasswordResetTTLMinutes   int        `json:"password_reset_ttl_minutes"`
+
+	AccessTokenTTLMinutes int `json:"access_token_ttl_minutes"`
+	RefreshTokenTTLHours  int `json:"refresh_token_ttl_hours"`
+	APIKeyMaxTTLDays      int `json:"api_key_max_ttl_days"`
+
+	MFAIssuer string `json:"mfa_issuer"` // name shown in authenticator apps
+
+	LoginProtection LoginProtectionConfig `json:"login_protection"`
+
+	OIDC OIDCConfig `json:"oidc"`
+	LTI  LTIConfig  `json:"lti"`
+
+	Cache CacheConfig `json:"cache"`
 }
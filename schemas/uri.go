@@ -1,8 +1,11 @@
 package schemas
 
 type URI struct {
-	UserId            int64 `uri:"userId"`
-	TestId            int64 `uri:"testId"`
-	QuestionId        int64 `uri:"questionId"`
-	TestQuestionaryId int64 `uri:"testQuestionaryId"`
+	UserId            int64  `uri:"userId"`
+	TestId            int64  `uri:"testId"`
+	QuestionId        int64  `uri:"questionId"`
+	TestQuestionaryId int64  `uri:"testQuestionaryId"`
+	SessionId         string `uri:"sessionId"`
+	APIKeyId          int64  `uri:"apiKeyId"`
+	Role              string `uri:"role"`
 }
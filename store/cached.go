@@ -0,0 +1,339 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/open-lms-test-functionality/cache"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/utils"
+	"go.uber.org/zap"
+)
+
+// Cached keeps user identities and test questionaries of the stores it wraps
+// in a cache. Writes made through it invalidate the entries they affect;
+// writes made elsewhere must call Users.InvalidateUser. Cache failures are
+// logged and the wrapped store is used instead, so a cache outage only costs
+// speed.
+//
+// Questionary entries are looked up under version keys: a change to a test's
+// questions replaces the test's version and a change to any question replaces
+// the global one, which orphans every entry read before it. A user entry read
+// just before a concurrent change can still be stored after the change
+// deleted it; it lives at most one TTL.
+type Cached struct {
+	UserStore
+	TestStore
+	QuestionStore
+
+	unitOfWork UnitOfWork
+	cache      cache.Cache
+	ttl        time.Duration
+}
+
+type cachedTxKey struct{}
+
+// cachedTx collects the invalidations made inside WithTx. They are applied
+// right away and again after the commit, since a reader outside the
+// transaction could have cached the old rows in between.
+type cachedTx struct {
+	mu            sync.Mutex
+	invalidations []func(ctx context.Context)
+}
+
+type cachedQuestionary[T any] struct {
+	Data  []T `json:"data"`
+	Count int `json:"count"`
+}
+
+// NewCached returns stores that serve user identities and questionaries from
+// c, keeping entries for ttl.
+func NewCached(stores Stores, c cache.Cache, ttl time.Duration) Stores {
+	cached := &Cached{
+		UserStore:     stores.Users,
+		TestStore:     stores.Tests,
+		QuestionStore: stores.Questions,
+		unitOfWork:    stores.UnitOfWork,
+		cache:         c,
+		ttl:           ttl,
+	}
+	return Stores{
+		UnitOfWork:  cached,
+		Users:       cached,
+		Tests:       cached,
+		Questions:   cached,
+		Submissions: stores.Submissions,
+
+		Sessions:       stores.Sessions,
+		Tokens:         stores.Tokens,
+		MFA:            stores.MFA,
+		APIKeys:        stores.APIKeys,
+		LoginThrottles: stores.LoginThrottles,
+		Identities:     stores.Identities,
+		Events:         stores.Events,
+		Reports:        stores.Reports,
+	}
+}
+
+// WithTx runs fn in a transaction of the wrapped stores. Reads inside it
+// bypass the cache, so rows the transaction has not committed yet are never
+// cached.
+func (s *Cached) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.withTx(ctx, s.unitOfWork.WithTx, fn)
+}
+
+// WithReadCommittedTx is WithTx in a read committed transaction of the
+// wrapped stores.
+func (s *Cached) WithReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.withTx(ctx, s.unitOfWork.WithReadCommittedTx, fn)
+}
+
+func (s *Cached) withTx(ctx context.Context, begin func(ctx context.Context, fn func(ctx context.Context) error) error, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(cachedTxKey{}).(*cachedTx); ok {
+		return begin(ctx, fn)
+	}
+	tx := &cachedTx{}
+	err := begin(context.WithValue(ctx, cachedTxKey{}, tx), fn)
+	tx.mu.Lock()
+	invalidations := tx.invalidations
+	tx.mu.Unlock()
+	for _, invalidate := range invalidations {
+		invalidate(ctx)
+	}
+	return err
+}
+
+func inCachedTx(ctx context.Context) bool {
+	_, ok := ctx.Value(cachedTxKey{}).(*cachedTx)
+	return ok
+}
+
+// invalidate runs fn now and, inside WithTx, again once the transaction ends.
+func (s *Cached) invalidate(ctx context.Context, fn func(ctx context.Context)) {
+	fn(ctx)
+	if tx, ok := ctx.Value(cachedTxKey{}).(*cachedTx); ok {
+		tx.mu.Lock()
+		tx.invalidations = append(tx.invalidations, fn)
+		tx.mu.Unlock()
+	}
+}
+
+func (s *Cached) get(ctx context.Context, key string, value any) bool {
+	data, found, err := s.cache.Get(ctx, key)
+	if err != nil {
+		logger.Logger.Warn("CACHE :: Error while reading", zap.String("key", key), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+		return false
+	}
+	if !found {
+		return false
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		logger.Logger.Warn("CACHE :: Error while decoding", zap.String("key", key), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+		return false
+	}
+	return true
+}
+
+func (s *Cached) set(ctx context.Context, key string, value any) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = s.cache.Set(ctx, key, data, s.ttl)
+	}
+	if err != nil {
+		logger.Logger.Warn("CACHE :: Error while writing", zap.String("key", key), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+	}
+}
+
+func (s *Cached) delete(ctx context.Context, keys ...string) {
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		logger.Logger.Warn("CACHE :: Error while deleting", zap.Strings("keys", keys), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+	}
+}
+
+func userEmailKey(email string) string {
+	return "user:email:" + email
+}
+
+// FetchUserIdentity serves the user from the cache, loading and storing it on
+// a miss. Unknown and deactivated users are not cached.
+func (s *Cached) FetchUserIdentity(ctx context.Context, email string) models.UserSchema {
+	var userData models.UserSchema
+	if inCachedTx(ctx) {
+		return s.UserStore.FetchUserIdentity(ctx, email)
+	}
+	if s.get(ctx, userEmailKey(email), &userData) {
+		return userData
+	}
+	userData = s.UserStore.FetchUserIdentity(ctx, email)
+	if userData.Id != 0 {
+		s.set(ctx, userEmailKey(email), userData)
+	}
+	return userData
+}
+
+// InvalidateUser drops the cached identity of the user. Entries are keyed by
+// email, so the user is looked up to find it.
+func (s *Cached) InvalidateUser(ctx context.Context, uuidString string, userId int64) {
+	s.invalidate(ctx, func(ctx context.Context) {
+		userData, err := s.UserStore.FetchUserById(ctx, uuidString, userId)
+		if err != nil {
+			logger.Logger.Warn("CACHE :: Error while fetching user to invalidate", zap.Int64("userId", userId), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+			return
+		}
+		s.delete(ctx, userEmailKey(userData.Email))
+	})
+	s.UserStore.InvalidateUser(ctx, uuidString, userId)
+}
+
+func (s *Cached) UpdateUser(ctx context.Context, uuidString string, userId int64, data models.UserCreateSchema) (int64, error) {
+	id, err := s.UserStore.UpdateUser(ctx, uuidString, userId, data)
+	s.InvalidateUser(ctx, uuidString, userId)
+	return id, err
+}
+
+func (s *Cached) UpdateUserType(ctx context.Context, uuidString string, userId int64, userType int) (int64, error) {
+	id, err := s.UserStore.UpdateUserType(ctx, uuidString, userId, userType)
+	s.InvalidateUser(ctx, uuidString, userId)
+	return id, err
+}
+
+func (s *Cached) SetPasswordResetRequired(ctx context.Context, uuidString string, userId int64, required bool) (int64, error) {
+	id, err := s.UserStore.SetPasswordResetRequired(ctx, uuidString, userId, required)
+	s.InvalidateUser(ctx, uuidString, userId)
+	return id, err
+}
+
+func (s *Cached) UpdateUserPassword(ctx context.Context, uuidString string, userId int64, passwordHash string) (int64, error) {
+	id, err := s.UserStore.UpdateUserPassword(ctx, uuidString, userId, passwordHash)
+	s.InvalidateUser(ctx, uuidString, userId)
+	return id, err
+}
+
+func (s *Cached) DeactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	id, err := s.UserStore.DeactivateUser(ctx, uuidString, userId)
+	s.InvalidateUser(ctx, uuidString, userId)
+	return id, err
+}
+
+func (s *Cached) ReactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	id, err := s.UserStore.ReactivateUser(ctx, uuidString, userId)
+	s.InvalidateUser(ctx, uuidString, userId)
+	return id, err
+}
+
+func questionaryVersionKey(testId int64) string {
+	return "questionary:version:" + strconv.FormatInt(testId, 10)
+}
+
+const questionsVersionKey = "questionary:version"
+
+// version returns the current value of a version key, creating it when it is
+// missing or expired.
+func (s *Cached) version(ctx context.Context, key string) (string, bool) {
+	var version string
+	if s.get(ctx, key, &version) {
+		return version, true
+	}
+	version = utils.GetUUID()
+	data, _ := json.Marshal(version)
+	// A lost version only orphans entries, so versions may expire; keeping them
+	// longer than the entries avoids needless misses.
+	if err := s.cache.Set(ctx, key, data, 2*s.ttl); err != nil {
+		logger.Logger.Warn("CACHE :: Error while writing", zap.String("key", key), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+		return "", false
+	}
+	return version, true
+}
+
+// bumpVersion replaces a version key so entries stored under the old value
+// are never read again.
+func (s *Cached) bumpVersion(ctx context.Context, key string) {
+	s.invalidate(ctx, func(ctx context.Context) {
+		data, _ := json.Marshal(utils.GetUUID())
+		if err := s.cache.Set(ctx, key, data, 2*s.ttl); err != nil {
+			logger.Logger.Warn("CACHE :: Error while writing", zap.String("key", key), zap.Error(err), zap.String("requestId", models.RequestId(ctx)))
+			s.delete(ctx, key)
+		}
+	})
+}
+
+// questionaryKey returns the key of one page of a test's questionary, or false
+// when the versions cannot be read and the cache must be skipped.
+func (s *Cached) questionaryKey(ctx context.Context, kind string, testId int64, page models.Page) (string, bool) {
+	testVersion, ok := s.version(ctx, questionaryVersionKey(testId))
+	if !ok {
+		return "", false
+	}
+	questionsVersion, ok := s.version(ctx, questionsVersionKey)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("questionary:%d:%s:%s:%s:%d:%d:%d:%t", testId, testVersion, questionsVersion, kind, page.Limit, page.Offset, page.After, page.WithCount), true
+}
+
+func (s *Cached) FetchTestQuestionaryForTeacher(ctx context.Context, uuidString string, testId int64, page models.Page) ([]models.TestQuestionsSchema, int, error) {
+	if inCachedTx(ctx) {
+		return s.TestStore.FetchTestQuestionaryForTeacher(ctx, uuidString, testId, page)
+	}
+	key, ok := s.questionaryKey(ctx, "teacher", testId, page)
+	var questionary cachedQuestionary[models.TestQuestionsSchema]
+	if ok && s.get(ctx, key, &questionary) {
+		return questionary.Data, questionary.Count, nil
+	}
+	data, count, err := s.TestStore.FetchTestQuestionaryForTeacher(ctx, uuidString, testId, page)
+	if ok && err == nil {
+		s.set(ctx, key, cachedQuestionary[models.TestQuestionsSchema]{Data: data, Count: count})
+	}
+	return data, count, err
+}
+
+func (s *Cached) FetchTestQuestionaryForStudent(ctx context.Context, uuidString string, testId int64, page models.Page) ([]models.TestQuestionSchemaForTakeTest, int, error) {
+	if inCachedTx(ctx) {
+		return s.TestStore.FetchTestQuestionaryForStudent(ctx, uuidString, testId, page)
+	}
+	key, ok := s.questionaryKey(ctx, "student", testId, page)
+	var questionary cachedQuestionary[models.TestQuestionSchemaForTakeTest]
+	if ok && s.get(ctx, key, &questionary) {
+		return questionary.Data, questionary.Count, nil
+	}
+	data, count, err := s.TestStore.FetchTestQuestionaryForStudent(ctx, uuidString, testId, page)
+	if ok && err == nil {
+		s.set(ctx, key, cachedQuestionary[models.TestQuestionSchemaForTakeTest]{Data: data, Count: count})
+	}
+	return data, count, err
+}
+
+func (s *Cached) CreateTestQuestionary(ctx context.Context, uuidString string, testId int64, questionIds []int64) (int64, error) {
+	id, err := s.TestStore.CreateTestQuestionary(ctx, uuidString, testId, questionIds)
+	s.bumpVersion(ctx, questionaryVersionKey(testId))
+	return id, err
+}
+
+func (s *Cached) DeleteTestQuestionary(ctx context.Context, uuidString string, testId int64, questionId int64) (bool, error) {
+	status, err := s.TestStore.DeleteTestQuestionary(ctx, uuidString, testId, questionId)
+	s.bumpVersion(ctx, questionaryVersionKey(testId))
+	return status, err
+}
+
+func (s *Cached) DeleteTest(ctx context.Context, uuidString string, testId int64) (bool, error) {
+	status, err := s.TestStore.DeleteTest(ctx, uuidString, testId)
+	s.bumpVersion(ctx, questionaryVersionKey(testId))
+	return status, err
+}
+
+func (s *Cached) UpdateQuestion(ctx context.Context, uuidString string, questionId int64, data models.QuestionCreateSchema) (int64, error) {
+	id, err := s.QuestionStore.UpdateQuestion(ctx, uuidString, questionId, data)
+	s.bumpVersion(ctx, questionsVersionKey)
+	return id, err
+}
+
+func (s *Cached) DeleteQuestion(ctx context.Context, uuidString string, questionId int64) (bool, error) {
+	status, err := s.QuestionStore.DeleteQuestion(ctx, uuidString, questionId)
+	s.bumpVersion(ctx, questionsVersionKey)
+	return status, err
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/open-lms-test-functionality/cache"
+	"github.com/open-lms-test-functionality/cache/cachetest"
+	"github.com/open-lms-test-functionality/logger"
+	"github.com/open-lms-test-functionality/models"
+	"go.uber.org/zap"
+)
+
+// eachCache runs fn with cached stores over an empty memory store, once per
+// cache driver. backing is the memory store itself, for changing data behind
+// the cache's back.
+func eachCache(t *testing.T, fn func(t *testing.T, cached Stores, backing Stores)) {
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+	drivers := map[string]func(t *testing.T) cache.Cache{
+		"memory": func(t *testing.T) cache.Cache {
+			return cache.NewLRU(100)
+		},
+		"redis": func(t *testing.T) cache.Cache {
+			server := cachetest.NewServer("secret")
+			t.Cleanup(server.Close)
+			redis := cache.NewRedis(cache.RedisOptions{Addr: server.Addr(), Password: "secret"})
+			t.Cleanup(func() { redis.Close() })
+			return redis
+		},
+	}
+	for name, newCache := range drivers {
+		t.Run(name, func(t *testing.T) {
+			backing := NewMemory()
+			fn(t, NewCached(backing, newCache(t), time.Minute), backing)
+		})
+	}
+}
+
+func createTestUser(t *testing.T, stores Stores, email string) int64 {
+	t.Helper()
+	id, err := stores.Users.CreateUser(context.Background(), "", models.UserCreateSchema{
+		FirstName: "Cached",
+		LastName:  "User",
+		Email:     email,
+		Password:  "hash",
+		Type:      "student",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCachedServesUserUntilChanged(t *testing.T) {
+	eachCache(t, func(t *testing.T, cached Stores, backing Stores) {
+		ctx := context.Background()
+		userId := createTestUser(t, cached, "cached@example.com")
+		if user := cached.Users.FetchUserIdentity(ctx, "cached@example.com"); user.Id != userId || user.FirstName != "Cached" {
+			t.Fatalf("FetchUserIdentity = %+v", user)
+		}
+
+		// A change the cache does not see is not read until the entry is
+		// invalidated.
+		update := models.UserCreateSchema{FirstName: "Renamed", LastName: "User", Email: "cached@example.com", Type: "student"}
+		if _, err := backing.Users.UpdateUser(ctx, "", userId, update); err != nil {
+			t.Fatal(err)
+		}
+		if user := cached.Users.FetchUserIdentity(ctx, "cached@example.com"); user.FirstName != "Cached" {
+			t.Fatalf("FetchUserIdentity after a change behind the cache = %+v, want the cached copy", user)
+		}
+		cached.Users.InvalidateUser(ctx, "", userId)
+		if user := cached.Users.FetchUserIdentity(ctx, "cached@example.com"); user.FirstName != "Renamed" {
+			t.Fatalf("FetchUserIdentity after InvalidateUser = %+v", user)
+		}
+	})
+}
+
+func TestCachedInvalidatesUserOnWrites(t *testing.T) {
+	ctx := context.Background()
+	for name, write := range map[string]func(t *testing.T, cached Stores, userId int64){
+		"update": func(t *testing.T, cached Stores, userId int64) {
+			update := models.UserCreateSchema{FirstName: "Renamed", LastName: "User", Email: "cached@example.com", Type: "student"}
+			if _, err := cached.Users.UpdateUser(ctx, "", userId, update); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"role": func(t *testing.T, cached Stores, userId int64) {
+			if _, err := cached.Users.UpdateUserType(ctx, "", userId, models.TEACHER); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"password reset": func(t *testing.T, cached Stores, userId int64) {
+			if _, err := cached.Users.SetPasswordResetRequired(ctx, "", userId, true); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"deactivate": func(t *testing.T, cached Stores, userId int64) {
+			if _, err := cached.Users.DeactivateUser(ctx, "", userId); err != nil {
+				t.Fatal(err)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			eachCache(t, func(t *testing.T, cached Stores, backing Stores) {
+				userId := createTestUser(t, cached, "cached@example.com")
+				if user := cached.Users.FetchUserIdentity(ctx, "cached@example.com"); user.Id != userId {
+					t.Fatalf("FetchUserIdentity = %+v", user)
+				}
+
+				write(t, cached, userId)
+				want := backing.Users.FetchUserIdentity(ctx, "cached@example.com")
+				if user := cached.Users.FetchUserIdentity(ctx, "cached@example.com"); !reflect.DeepEqual(user, want) {
+					t.Fatalf("FetchUserIdentity after the write = %+v, want %+v", user, want)
+				}
+			})
+		})
+	}
+}
+
+func TestCachedDeactivatedUserIsNotServed(t *testing.T) {
+	eachCache(t, func(t *testing.T, cached Stores, backing Stores) {
+		ctx := context.Background()
+		userId := createTestUser(t, cached, "cached@example.com")
+		cached.Users.FetchUserIdentity(ctx, "cached@example.com")
+
+		if _, err := cached.Users.DeactivateUser(ctx, "", userId); err != nil {
+			t.Fatal(err)
+		}
+		if user := cached.Users.FetchUserIdentity(ctx, "cached@example.com"); user.Id != 0 {
+			t.Fatalf("FetchUserIdentity of a deactivated user = %+v", user)
+		}
+	})
+}
+
+func TestCachedQuestionaryFollowsVersions(t *testing.T) {
+	eachCache(t, func(t *testing.T, cached Stores, backing Stores) {
+		ctx := context.Background()
+		page := models.Page{Limit: 10}
+		testId, err := cached.Tests.CreateTest(ctx, "", models.TestCreateSchema{Title: "Cached"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		question := models.QuestionCreateSchema{
+			Type:         "multiple_choice",
+			QuestionData: map[string]interface{}{"question": "first"},
+			AnswerData:   map[string]interface{}{"choices": []string{"a"}},
+		}
+		questionId, err := cached.Questions.CreateQuestion(ctx, "", question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cached.Tests.CreateTestQuestionary(ctx, "", testId, []int64{questionId}); err != nil {
+			t.Fatal(err)
+		}
+
+		fetch := func() []models.TestQuestionSchemaForTakeTest {
+			t.Helper()
+			data, _, err := cached.Tests.FetchTestQuestionaryForStudent(ctx, "", testId, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return data
+		}
+		if data := fetch(); len(data) != 1 || data[0].QuestionData.QuestionData != `{"question":"first"}` {
+			t.Fatalf("questionary = %+v", data)
+		}
+
+		// Changed behind the cache: the cached page is still served.
+		question.QuestionData = map[string]interface{}{"question": "second"}
+		if _, err := backing.Questions.UpdateQuestion(ctx, "", questionId, question); err != nil {
+			t.Fatal(err)
+		}
+		if data := fetch(); data[0].QuestionData.QuestionData != `{"question":"first"}` {
+			t.Fatalf("questionary after a change behind the cache = %+v, want the cached copy", data)
+		}
+
+		// Editing a question bumps the version shared by all questionaries.
+		question.QuestionData = map[string]interface{}{"question": "third"}
+		if _, err := cached.Questions.UpdateQuestion(ctx, "", questionId, question); err != nil {
+			t.Fatal(err)
+		}
+		if data := fetch(); data[0].QuestionData.QuestionData != `{"question":"third"}` {
+			t.Fatalf("questionary after UpdateQuestion = %+v", data)
+		}
+
+		// Changing the test's questions bumps the version of the test.
+		otherId, err := cached.Questions.CreateQuestion(ctx, "", question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cached.Tests.CreateTestQuestionary(ctx, "", testId, []int64{otherId}); err != nil {
+			t.Fatal(err)
+		}
+		if data := fetch(); len(data) != 2 {
+			t.Fatalf("questionary after CreateTestQuestionary has %d questions, want 2", len(data))
+		}
+		if _, err := cached.Tests.DeleteTestQuestionary(ctx, "", testId, questionId); err != nil {
+			t.Fatal(err)
+		}
+		if data := fetch(); len(data) != 1 || data[0].QuestionData.Id != otherId {
+			t.Fatalf("questionary after DeleteTestQuestionary = %+v", data)
+		}
+	})
+}
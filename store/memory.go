@@ -0,0 +1,738 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/models"
+)
+
+var (
+	ErrDuplicateEmail = errors.New("email already registered")
+	ErrMissingRecord  = errors.New("referenced record does not exist")
+)
+
+type memoryUser struct {
+	models.UserSchema
+	DeactivatedAt *time.Time
+	CreatedAt     time.Time
+}
+
+type memoryTestQuestion struct {
+	Id         int64
+	TestId     int64
+	QuestionId int64
+}
+
+type memorySubmission struct {
+	models.TestQuestionSubmissionSchema
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// memoryTables are the records of a Memory. Every map holds values, so a
+// shallow clone of each map is a snapshot; users are copied one by one.
+type memoryTables struct {
+	lastId        int64
+	users         map[int64]*memoryUser
+	tests         map[int64]models.TestResponseSchema
+	questions     map[int64]models.QuestionResponseSchema
+	testQuestions map[int64]memoryTestQuestion
+	submissions   map[int64]memorySubmission
+
+	sessions         map[string]memorySession
+	refreshTokens    map[string]memoryRefreshToken
+	userTokens       map[string]memoryUserToken
+	mfa              map[int64]memoryMFA
+	recoveryCodes    map[int64]memoryRecoveryCode
+	roleSettings     map[int]models.RoleSettingSchema
+	apiKeys          map[int64]memoryAPIKey
+	loginThrottles   map[[2]string]memoryLoginThrottle
+	loginLockouts    map[int64]models.LoginLockoutSchema
+	identities       map[[2]string]memoryIdentity
+	ltiNonces        map[string]time.Time
+	ltiContexts      map[[3]string]memoryLTIContext
+	ltiMembers       map[[2]int64][]string
+	ltiResourceLinks map[memoryLTIResourceLinkKey]memoryLTIResourceLink
+	questionTimes    map[[3]int64]memoryQuestionTime
+	integrityEvents  map[int64]memoryIntegrityEvent
+	submissionEvents map[int64]models.SubmissionEventSchema
+}
+
+// Memory keeps everything in maps. It follows the Postgres behaviour the
+// handlers rely on (cascading deletes, pgx.ErrNoRows for missing rows) so the
+// HTTP layer, authentication included, can be exercised with httptest without
+// a database.
+type Memory struct {
+	mu sync.Mutex
+	memoryTables
+}
+
+// NewMemory returns Stores sharing one empty in-memory Memory.
+func NewMemory() Stores {
+	memory := &Memory{memoryTables: memoryTables{
+		users:         make(map[int64]*memoryUser),
+		tests:         make(map[int64]models.TestResponseSchema),
+		questions:     make(map[int64]models.QuestionResponseSchema),
+		testQuestions: make(map[int64]memoryTestQuestion),
+		submissions:   make(map[int64]memorySubmission),
+
+		sessions:         make(map[string]memorySession),
+		refreshTokens:    make(map[string]memoryRefreshToken),
+		userTokens:       make(map[string]memoryUserToken),
+		mfa:              make(map[int64]memoryMFA),
+		recoveryCodes:    make(map[int64]memoryRecoveryCode),
+		roleSettings:     make(map[int]models.RoleSettingSchema),
+		apiKeys:          make(map[int64]memoryAPIKey),
+		loginThrottles:   make(map[[2]string]memoryLoginThrottle),
+		loginLockouts:    make(map[int64]models.LoginLockoutSchema),
+		identities:       make(map[[2]string]memoryIdentity),
+		ltiNonces:        make(map[string]time.Time),
+		ltiContexts:      make(map[[3]string]memoryLTIContext),
+		ltiMembers:       make(map[[2]int64][]string),
+		ltiResourceLinks: make(map[memoryLTIResourceLinkKey]memoryLTIResourceLink),
+		questionTimes:    make(map[[3]int64]memoryQuestionTime),
+		integrityEvents:  make(map[int64]memoryIntegrityEvent),
+		submissionEvents: make(map[int64]models.SubmissionEventSchema),
+	}}
+	return Stores{
+		UnitOfWork:  memory,
+		Users:       memory,
+		Tests:       memory,
+		Questions:   memory,
+		Submissions: memory,
+
+		Sessions:       memory,
+		Tokens:         memory,
+		MFA:            memory,
+		APIKeys:        memory,
+		LoginThrottles: memory,
+		Identities:     memory,
+		Events:         memory,
+		Reports:        memory,
+	}
+}
+
+func (m *Memory) nextId() int64 {
+	m.lastId++
+	return m.lastId
+}
+
+// sortedIds returns the keys of a map newest first, matching ORDER BY id DESC.
+func sortedIds[T any](rows map[int64]T) []int64 {
+	ids := make([]int64, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	return ids
+}
+
+// page applies LIMIT and OFFSET to an already filtered list.
+func page[T any](rows []T, limit int, offset int) []T {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// pageById applies a models.Page to rows sorted newest first.
+func pageById[T any](rows []T, p models.Page, id func(T) int64) []T {
+	if p.After > 0 {
+		start := sort.Search(len(rows), func(i int) bool { return id(rows[i]) < p.After })
+		return page(rows[start:], p.Limit, 0)
+	}
+	return page(rows, p.Limit, p.Offset)
+}
+
+// total returns n when the page asks for the total count.
+func total(p models.Page, n int) int {
+	if !p.WithCount {
+		return 0
+	}
+	return n
+}
+
+// WithTx restores every record to its state before fn when fn fails or
+// panics. Unlike Postgres it does not isolate fn from concurrent calls.
+func (m *Memory) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	m.mu.Lock()
+	snapshot := m.snapshot()
+	m.mu.Unlock()
+
+	defer func() {
+		p := recover()
+		if p != nil || err != nil {
+			m.mu.Lock()
+			m.restore(snapshot)
+			m.mu.Unlock()
+		}
+		if p != nil {
+			panic(p)
+		}
+	}()
+	return fn(ctx)
+}
+
+// WithReadCommittedTx is WithTx: the memory store has no isolation levels.
+func (m *Memory) WithReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return m.WithTx(ctx, fn)
+}
+
+// snapshot copies every record; the caller holds m.mu.
+func (m *Memory) snapshot() memoryTables {
+	snapshot := m.memoryTables
+	snapshot.users = make(map[int64]*memoryUser, len(m.users))
+	for id, user := range m.users {
+		userCopy := *user
+		snapshot.users[id] = &userCopy
+	}
+	snapshot.tests = maps.Clone(m.tests)
+	snapshot.questions = maps.Clone(m.questions)
+	snapshot.testQuestions = maps.Clone(m.testQuestions)
+	snapshot.submissions = maps.Clone(m.submissions)
+
+	snapshot.sessions = maps.Clone(m.sessions)
+	snapshot.refreshTokens = maps.Clone(m.refreshTokens)
+	snapshot.userTokens = maps.Clone(m.userTokens)
+	snapshot.mfa = maps.Clone(m.mfa)
+	snapshot.recoveryCodes = maps.Clone(m.recoveryCodes)
+	snapshot.roleSettings = maps.Clone(m.roleSettings)
+	snapshot.apiKeys = maps.Clone(m.apiKeys)
+	snapshot.loginThrottles = maps.Clone(m.loginThrottles)
+	snapshot.loginLockouts = maps.Clone(m.loginLockouts)
+	snapshot.identities = maps.Clone(m.identities)
+	snapshot.ltiNonces = maps.Clone(m.ltiNonces)
+	snapshot.ltiContexts = maps.Clone(m.ltiContexts)
+	snapshot.ltiMembers = maps.Clone(m.ltiMembers)
+	snapshot.ltiResourceLinks = maps.Clone(m.ltiResourceLinks)
+	snapshot.questionTimes = maps.Clone(m.questionTimes)
+	snapshot.integrityEvents = maps.Clone(m.integrityEvents)
+	snapshot.submissionEvents = maps.Clone(m.submissionEvents)
+	return snapshot
+}
+
+// restore puts back the records of a snapshot; the caller holds m.mu. Ids are
+// not reused, as with Postgres sequences.
+func (m *Memory) restore(snapshot memoryTables) {
+	lastId := m.lastId
+	m.memoryTables = snapshot
+	m.lastId = lastId
+}
+
+func (m *Memory) CreateUser(ctx context.Context, uuidString string, data models.UserCreateSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Email == data.Email {
+			return 0, ErrDuplicateEmail
+		}
+	}
+	id := m.nextId()
+	m.users[id] = &memoryUser{
+		UserSchema: models.UserSchema{
+			Id:        id,
+			FirstName: data.FirstName,
+			LastName:  data.LastName,
+			Email:     data.Email,
+			Password:  data.Password,
+			Type:      strconv.Itoa(models.GetUserType(data.Type)),
+		},
+		CreatedAt: time.Now(),
+	}
+	return id, nil
+}
+
+// updateUser applies update to an existing user.
+func (m *Memory) updateUser(userId int64, update func(user *memoryUser)) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userId]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	update(user)
+	return userId, nil
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, uuidString string, userId int64, data models.UserCreateSchema) (int64, error) {
+	return m.updateUser(userId, func(user *memoryUser) {
+		user.FirstName = data.FirstName
+		user.LastName = data.LastName
+	})
+}
+
+func (m *Memory) FetchUserForAuth(ctx context.Context, email string) models.UserSchema {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Email == email && user.DeactivatedAt == nil {
+			return user.UserSchema
+		}
+	}
+	return models.UserSchema{}
+}
+
+func (m *Memory) FetchUserIdentity(ctx context.Context, email string) models.UserSchema {
+	userData := m.FetchUserForAuth(ctx, email)
+	userData.Password = ""
+	return userData
+}
+
+func (m *Memory) InvalidateUser(ctx context.Context, uuidString string, userId int64) {}
+
+func (user *memoryUser) adminView() models.AdminUserSchema {
+	return models.AdminUserSchema{
+		Id:                    user.Id,
+		FirstName:             user.FirstName,
+		LastName:              user.LastName,
+		Email:                 user.Email,
+		Type:                  models.ValidateUserType(user.UserType()),
+		Active:                user.DeactivatedAt == nil,
+		DeactivatedAt:         user.DeactivatedAt,
+		PasswordResetRequired: user.PasswordResetRequired,
+		CreatedAt:             user.CreatedAt,
+	}
+}
+
+func (m *Memory) FetchUserById(ctx context.Context, uuidString string, userId int64) (models.AdminUserSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userId]
+	if !ok {
+		return models.AdminUserSchema{}, nil
+	}
+	return user.adminView(), nil
+}
+
+func (m *Memory) SearchUsers(ctx context.Context, uuidString string, search string, userType int, status string, limit int, offset int) ([]models.AdminUserSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	search = strings.ToLower(search)
+	var data []models.AdminUserSchema
+	for _, id := range sortedIds(m.users) {
+		user := m.users[id]
+		if search != "" && !strings.Contains(strings.ToLower(user.Email), search) &&
+			!strings.Contains(strings.ToLower(user.FirstName), search) &&
+			!strings.Contains(strings.ToLower(user.LastName), search) {
+			continue
+		}
+		if userType != 0 && user.UserType() != userType {
+			continue
+		}
+		if (status == models.USERSTATUSACTIVE && user.DeactivatedAt != nil) ||
+			(status == models.USERSTATUSDEACTIVATED && user.DeactivatedAt == nil) {
+			continue
+		}
+		data = append(data, user.adminView())
+	}
+	count := len(data)
+	data = page(data, limit, offset)
+	if len(data) == 0 {
+		count = 0
+	}
+	return data, count, nil
+}
+
+func (m *Memory) CountActiveUsersOfType(ctx context.Context, uuidString string, userType int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, user := range m.users {
+		if user.UserType() == userType && user.DeactivatedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) UpdateUserType(ctx context.Context, uuidString string, userId int64, userType int) (int64, error) {
+	return m.updateUser(userId, func(user *memoryUser) {
+		user.Type = strconv.Itoa(userType)
+	})
+}
+
+func (m *Memory) SetPasswordResetRequired(ctx context.Context, uuidString string, userId int64, required bool) (int64, error) {
+	return m.updateUser(userId, func(user *memoryUser) {
+		user.PasswordResetRequired = required
+	})
+}
+
+func (m *Memory) DeactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	return m.updateUser(userId, func(user *memoryUser) {
+		if user.DeactivatedAt == nil {
+			now := time.Now()
+			user.DeactivatedAt = &now
+		}
+	})
+}
+
+func (m *Memory) ReactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	return m.updateUser(userId, func(user *memoryUser) {
+		user.DeactivatedAt = nil
+	})
+}
+
+func (m *Memory) UpdateUserPassword(ctx context.Context, uuidString string, userId int64, passwordHash string) (int64, error) {
+	return m.updateUser(userId, func(user *memoryUser) {
+		user.Password = passwordHash
+		user.PasswordResetRequired = false
+	})
+}
+
+func (m *Memory) CreateTest(ctx context.Context, uuidString string, data models.TestCreateSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextId()
+	m.tests[id] = models.TestResponseSchema{Id: id, Title: data.Title}
+	return id, nil
+}
+
+func (m *Memory) UpdateTest(ctx context.Context, uuidString string, testId int64, data models.TestCreateSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tests[testId]; !ok {
+		return 0, pgx.ErrNoRows
+	}
+	m.tests[testId] = models.TestResponseSchema{Id: testId, Title: data.Title}
+	return testId, nil
+}
+
+func (m *Memory) DeleteTest(ctx context.Context, uuidString string, testId int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tests, testId)
+	for id, testQuestion := range m.testQuestions {
+		if testQuestion.TestId == testId {
+			delete(m.testQuestions, id)
+		}
+	}
+	for id, submission := range m.submissions {
+		if submission.TestId == testId {
+			delete(m.submissions, id)
+		}
+	}
+	for id, event := range m.submissionEvents {
+		if event.TestId == testId {
+			delete(m.submissionEvents, id)
+		}
+	}
+	for id, event := range m.integrityEvents {
+		if event.TestId == testId {
+			delete(m.integrityEvents, id)
+		}
+	}
+	for key := range m.questionTimes {
+		if key[0] == testId {
+			delete(m.questionTimes, key)
+		}
+	}
+	for key, resourceLink := range m.ltiResourceLinks {
+		if resourceLink.TestId == testId {
+			resourceLink.TestId = 0
+			m.ltiResourceLinks[key] = resourceLink
+		}
+	}
+	return true, nil
+}
+
+func (m *Memory) FetchTest(ctx context.Context, uuidString string, testId int64) (models.TestResponseSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tests[testId], nil
+}
+
+func (m *Memory) FetchTests(ctx context.Context, uuidString string, p models.Page) ([]models.TestResponseSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []models.TestResponseSchema
+	for _, id := range sortedIds(m.tests) {
+		data = append(data, m.tests[id])
+	}
+	count := total(p, len(data))
+	data = pageById(data, p, func(test models.TestResponseSchema) int64 { return test.Id })
+	return data, count, nil
+}
+
+func (m *Memory) CreateTestQuestionary(ctx context.Context, uuidString string, testId int64, questionIds []int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tests[testId]; !ok && len(questionIds) > 0 {
+		return 0, ErrMissingRecord
+	}
+	for _, questionId := range questionIds {
+		if _, ok := m.questions[questionId]; !ok {
+			return 0, ErrMissingRecord
+		}
+	}
+	var id int64
+	for _, questionId := range questionIds {
+		id = m.nextId()
+		m.testQuestions[id] = memoryTestQuestion{Id: id, TestId: testId, QuestionId: questionId}
+	}
+	return id, nil
+}
+
+func (m *Memory) DeleteTestQuestionary(ctx context.Context, uuidString string, testId int64, questionId int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, testQuestion := range m.testQuestions {
+		if testQuestion.TestId == testId && testQuestion.QuestionId == questionId {
+			delete(m.testQuestions, id)
+		}
+	}
+	return true, nil
+}
+
+// testQuestionary returns the questions assigned to a test, newest first.
+func (m *Memory) testQuestionary(testId int64) []memoryTestQuestion {
+	var data []memoryTestQuestion
+	for _, id := range sortedIds(m.testQuestions) {
+		if testQuestion := m.testQuestions[id]; testQuestion.TestId == testId {
+			data = append(data, testQuestion)
+		}
+	}
+	return data
+}
+
+func (m *Memory) FetchTestQuestionaryForTeacher(ctx context.Context, uuidString string, testId int64, p models.Page) ([]models.TestQuestionsSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	testQuestions := m.testQuestionary(testId)
+	var data []models.TestQuestionsSchema
+	for _, testQuestion := range pageById(testQuestions, p, func(testQuestion memoryTestQuestion) int64 { return testQuestion.Id }) {
+		data = append(data, models.TestQuestionsSchema{
+			Id:           testQuestion.Id,
+			TestId:       testQuestion.TestId,
+			QuestionData: m.questions[testQuestion.QuestionId],
+		})
+	}
+	return data, total(p, len(testQuestions)), nil
+}
+
+func (m *Memory) FetchTestQuestionaryForStudent(ctx context.Context, uuidString string, testId int64, p models.Page) ([]models.TestQuestionSchemaForTakeTest, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	testQuestions := m.testQuestionary(testId)
+	var data []models.TestQuestionSchemaForTakeTest
+	for _, testQuestion := range pageById(testQuestions, p, func(testQuestion memoryTestQuestion) int64 { return testQuestion.Id }) {
+		question := m.questions[testQuestion.QuestionId]
+		data = append(data, models.TestQuestionSchemaForTakeTest{
+			Id:     testQuestion.Id,
+			TestId: testQuestion.TestId,
+			QuestionData: models.QuestionResponseSchemaForTakeTest{
+				Id:           question.Id,
+				Type:         question.Type,
+				QuestionData: question.QuestionData,
+			},
+		})
+	}
+	return data, total(p, len(testQuestions)), nil
+}
+
+func questionRow(id int64, data models.QuestionCreateSchema) (models.QuestionResponseSchema, error) {
+	questionData, err := json.Marshal(data.QuestionData)
+	if err != nil {
+		return models.QuestionResponseSchema{}, err
+	}
+	answerData, err := json.Marshal(data.AnswerData)
+	if err != nil {
+		return models.QuestionResponseSchema{}, err
+	}
+	return models.QuestionResponseSchema{
+		Id:           id,
+		Type:         strconv.Itoa(models.GetQuestionType(data.Type)),
+		QuestionData: string(questionData),
+		AnswerData:   string(answerData),
+		Tags:         data.GetTags(),
+	}, nil
+}
+
+func (m *Memory) CreateQuestion(ctx context.Context, uuidString string, data models.QuestionCreateSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, err := questionRow(m.lastId+1, data)
+	if err != nil {
+		return 0, err
+	}
+	m.questions[m.nextId()] = question
+	return question.Id, nil
+}
+
+func (m *Memory) UpdateQuestion(ctx context.Context, uuidString string, questionId int64, data models.QuestionCreateSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.questions[questionId]; !ok {
+		return 0, pgx.ErrNoRows
+	}
+	question, err := questionRow(questionId, data)
+	if err != nil {
+		return 0, err
+	}
+	m.questions[questionId] = question
+	return questionId, nil
+}
+
+func (m *Memory) DeleteQuestion(ctx context.Context, uuidString string, questionId int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.questions, questionId)
+	for id, testQuestion := range m.testQuestions {
+		if testQuestion.QuestionId == questionId {
+			delete(m.testQuestions, id)
+		}
+	}
+	for id, submission := range m.submissions {
+		if submission.QuestionData.Id == questionId {
+			delete(m.submissions, id)
+		}
+	}
+	for id, event := range m.submissionEvents {
+		if event.QuestionId == questionId {
+			delete(m.submissionEvents, id)
+		}
+	}
+	for key := range m.questionTimes {
+		if key[2] == questionId {
+			delete(m.questionTimes, key)
+		}
+	}
+	return true, nil
+}
+
+func (m *Memory) FetchQuestion(ctx context.Context, uuidString string, questionId int64) (models.QuestionResponseSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.questions[questionId], nil
+}
+
+func (m *Memory) FetchQuestions(ctx context.Context, uuidString string, p models.Page, random bool) ([]models.QuestionResponseSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []models.QuestionResponseSchema
+	for _, id := range sortedIds(m.questions) {
+		data = append(data, m.questions[id])
+	}
+	count := total(p, len(data))
+	if random {
+		rand.Shuffle(len(data), func(i, j int) { data[i], data[j] = data[j], data[i] })
+		return page(data, p.Limit, p.Offset), count, nil
+	}
+	data = pageById(data, p, func(question models.QuestionResponseSchema) int64 { return question.Id })
+	return data, count, nil
+}
+
+func (m *Memory) CreateOrUpdateTestQuestionSubmission(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, answerData map[string][]string, questionData models.QuestionResponseSchema, clientIp string, userAgent string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.questions[questionId]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	if _, ok := m.tests[testId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	if _, ok := m.users[userId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	submittedData, err := json.Marshal(answerData)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	submission := memorySubmission{
+		TestQuestionSubmissionSchema: models.TestQuestionSubmissionSchema{
+			TestId:        testId,
+			UserId:        userId,
+			SubmittedData: string(submittedData),
+			AnswerStatus:  models.GradeSubmission(answerData, question.AnswerData),
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for id, existing := range m.submissions {
+		if existing.TestId == testId && existing.UserId == userId && existing.QuestionData.Id == questionId {
+			submission.Id = id
+			submission.CreatedAt = existing.CreatedAt
+		}
+	}
+	if submission.Id == 0 {
+		submission.Id = m.nextId()
+	}
+	submission.QuestionData.Id = questionId
+	m.submissions[submission.Id] = submission
+
+	eventId := m.nextId()
+	m.submissionEvents[eventId] = models.SubmissionEventSchema{
+		Id:            eventId,
+		SubmissionId:  submission.Id,
+		TestId:        testId,
+		UserId:        userId,
+		QuestionId:    questionId,
+		SubmittedData: submission.SubmittedData,
+		AnswerStatus:  submission.AnswerStatus,
+		ClientIp:      clientIp,
+		UserAgent:     userAgent,
+		CreatedAt:     now,
+	}
+	return submission.Id, nil
+}
+
+func (m *Memory) FetchTestQuestionSubmissions(ctx context.Context, uuidString string, testId int64, userId int64, p models.Page) ([]models.TestQuestionSubmissionSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []models.TestQuestionSubmissionSchema
+	for _, id := range sortedIds(m.submissions) {
+		submission := m.submissions[id].TestQuestionSubmissionSchema
+		if submission.TestId != testId || submission.UserId != userId {
+			continue
+		}
+		submission.QuestionData = m.questions[submission.QuestionData.Id]
+		if questionTime, ok := m.questionTimes[[3]int64{testId, userId, submission.QuestionData.Id}]; ok {
+			firstSeenAt := questionTime.FirstSeenAt
+			submission.FirstSeenAt = &firstSeenAt
+			submission.LastAnsweredAt = questionTime.LastAnsweredAt
+			submission.ActiveTimeMs = questionTime.ActiveTimeMs
+		}
+		data = append(data, submission)
+	}
+	count := total(p, len(data))
+	data = pageById(data, p, func(submission models.TestQuestionSubmissionSchema) int64 { return submission.Id })
+	return data, count, nil
+}
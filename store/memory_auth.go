@@ -0,0 +1,566 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/open-lms-test-functionality/models"
+)
+
+type memorySession struct {
+	models.SessionSchema
+	UserId        int64
+	RevokedAt     *time.Time
+	RevokedReason string
+}
+
+type memoryRefreshToken struct {
+	SessionId string
+	Rotated   bool
+}
+
+type memoryUserToken struct {
+	UserId    int64
+	Purpose   string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+type memoryMFA struct {
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+}
+
+type memoryRecoveryCode struct {
+	UserId   int64
+	CodeHash string
+	Used     bool
+}
+
+type memoryAPIKey struct {
+	models.APIKeySchema
+	UserId    int64
+	KeyHash   string
+	RevokedAt *time.Time
+}
+
+type memoryLoginThrottle struct {
+	Failures      int
+	LastFailureAt time.Time
+	BlockedUntil  time.Time
+}
+
+func (session memorySession) active(now time.Time) bool {
+	return session.RevokedAt == nil && session.ExpiresAt.After(now)
+}
+
+func (m *Memory) CreateSession(ctx context.Context, uuidString string, sessionId string, userId int64, clientIp string, userAgent string, refreshTokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userId]; !ok {
+		return ErrMissingRecord
+	}
+	now := time.Now()
+	m.sessions[sessionId] = memorySession{
+		SessionSchema: models.SessionSchema{
+			Id:         sessionId,
+			ClientIp:   clientIp,
+			UserAgent:  userAgent,
+			CreatedAt:  now,
+			LastUsedAt: now,
+			ExpiresAt:  expiresAt,
+		},
+		UserId: userId,
+	}
+	m.refreshTokens[refreshTokenHash] = memoryRefreshToken{SessionId: sessionId}
+	return nil
+}
+
+func (m *Memory) IsSessionActive(ctx context.Context, uuidString string, sessionId string, userId int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionId]
+	return ok && session.UserId == userId && session.active(time.Now()), nil
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, uuidString string, refreshTokenHash string, newRefreshTokenHash string, expiresAt time.Time) (models.SessionUserSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	refreshToken, ok := m.refreshTokens[refreshTokenHash]
+	if !ok {
+		return models.SessionUserSchema{}, models.ErrSessionNotFound
+	}
+	now := time.Now()
+	session := m.sessions[refreshToken.SessionId]
+	user := m.users[session.UserId]
+
+	if refreshToken.Rotated {
+		if session.RevokedAt == nil {
+			session.RevokedAt = &now
+			session.RevokedReason = models.SESSIONREVOKEDTOKENREUSE
+			m.sessions[session.Id] = session
+		}
+		return models.SessionUserSchema{}, models.ErrRefreshTokenReused
+	}
+	if !session.active(now) || user.DeactivatedAt != nil {
+		return models.SessionUserSchema{}, models.ErrSessionNotFound
+	}
+
+	refreshToken.Rotated = true
+	m.refreshTokens[refreshTokenHash] = refreshToken
+	m.refreshTokens[newRefreshTokenHash] = memoryRefreshToken{SessionId: session.Id}
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	m.sessions[session.Id] = session
+	return models.SessionUserSchema{SessionId: session.Id, UserId: user.Id, Email: user.Email}, nil
+}
+
+func (m *Memory) FetchUserSessions(ctx context.Context, uuidString string, userId int64) ([]models.SessionSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var data []models.SessionSchema
+	for _, session := range m.sessions {
+		if session.UserId == userId && session.active(now) {
+			data = append(data, session.SessionSchema)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].LastUsedAt.After(data[j].LastUsedAt) })
+	return data, nil
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, uuidString string, userId int64, sessionId string, reason string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[sessionId]
+	if !ok || session.UserId != userId || session.RevokedAt != nil {
+		return 0, nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	session.RevokedReason = reason
+	m.sessions[sessionId] = session
+	return userId, nil
+}
+
+func (m *Memory) RevokeUserSessions(ctx context.Context, uuidString string, userId int64, reason string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	for id, session := range m.sessions {
+		if session.UserId == userId && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			session.RevokedReason = reason
+			m.sessions[id] = session
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) CreateUserToken(ctx context.Context, uuidString string, userId int64, purpose string, tokenHash string, expiresAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	for hash, token := range m.userTokens {
+		if token.UserId == userId && token.Purpose == purpose && !token.Used {
+			token.Used = true
+			m.userTokens[hash] = token
+		}
+	}
+	m.userTokens[tokenHash] = memoryUserToken{UserId: userId, Purpose: purpose, ExpiresAt: expiresAt}
+	return m.nextId(), nil
+}
+
+// consumeUserToken marks a token as used and applies update to the active
+// user it was issued to. It returns 0 when the token is unknown, already used
+// or expired.
+func (m *Memory) consumeUserToken(purpose string, tokenHash string, update func(user *memoryUser)) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.userTokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.Used || !token.ExpiresAt.After(time.Now()) {
+		return 0
+	}
+	token.Used = true
+	m.userTokens[tokenHash] = token
+
+	user, ok := m.users[token.UserId]
+	if !ok || user.DeactivatedAt != nil {
+		return 0
+	}
+	update(user)
+	return user.Id
+}
+
+func (m *Memory) VerifyEmailWithToken(ctx context.Context, uuidString string, tokenHash string) (int64, error) {
+	return m.consumeUserToken(models.TOKENPURPOSEEMAILVERIFICATION, tokenHash, func(user *memoryUser) {
+		user.EmailVerified = true
+	}), nil
+}
+
+func (m *Memory) ResetPasswordWithToken(ctx context.Context, uuidString string, tokenHash string, passwordHash string) (int64, error) {
+	return m.consumeUserToken(models.TOKENPURPOSEPASSWORDRESET, tokenHash, func(user *memoryUser) {
+		user.Password = passwordHash
+		user.PasswordResetRequired = false
+	}), nil
+}
+
+func (m *Memory) FetchMFAState(ctx context.Context, uuidString string, userId int64, userType int) (models.MFAStateSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa := m.mfa[userId]
+	data := models.MFAStateSchema{
+		Secret:   mfa.Secret,
+		Enabled:  mfa.Enabled,
+		Required: m.roleSettings[userType].MFARequired,
+	}
+	for _, code := range m.recoveryCodes {
+		if code.UserId == userId && !code.Used {
+			data.RecoveryCodesLeft++
+		}
+	}
+	return data, nil
+}
+
+func (m *Memory) StartMFAEnrollment(ctx context.Context, uuidString string, userId int64, encryptedSecret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userId]; !ok {
+		return ErrMissingRecord
+	}
+	if m.mfa[userId].Enabled {
+		return models.ErrMFAAlreadyEnabled
+	}
+	m.mfa[userId] = memoryMFA{Secret: encryptedSecret}
+	return nil
+}
+
+func (m *Memory) UseTOTPStep(ctx context.Context, uuidString string, userId int64, step int64, enable bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa, ok := m.mfa[userId]
+	if !ok || mfa.LastUsedStep >= step || (!mfa.Enabled && !enable) {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	mfa.Enabled = true
+	m.mfa[userId] = mfa
+	return true, nil
+}
+
+func (m *Memory) ReplaceRecoveryCodes(ctx context.Context, uuidString string, userId int64, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userId]; !ok {
+		return ErrMissingRecord
+	}
+	for id, code := range m.recoveryCodes {
+		if code.UserId == userId {
+			delete(m.recoveryCodes, id)
+		}
+	}
+	for _, codeHash := range codeHashes {
+		m.recoveryCodes[m.nextId()] = memoryRecoveryCode{UserId: userId, CodeHash: codeHash}
+	}
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(ctx context.Context, uuidString string, userId int64, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, code := range m.recoveryCodes {
+		if code.UserId == userId && code.CodeHash == codeHash && !code.Used {
+			code.Used = true
+			m.recoveryCodes[id] = code
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) DisableMFA(ctx context.Context, uuidString string, userId int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, code := range m.recoveryCodes {
+		if code.UserId == userId {
+			delete(m.recoveryCodes, id)
+		}
+	}
+	_, ok := m.mfa[userId]
+	delete(m.mfa, userId)
+	return ok, nil
+}
+
+func (m *Memory) FetchRoleSettings(ctx context.Context, uuidString string) ([]models.RoleSettingSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []models.RoleSettingSchema
+	for _, userType := range []int{models.STUDENT, models.TEACHER, models.ADMIN, models.TEACHINGASSISTANT} {
+		setting, ok := m.roleSettings[userType]
+		if !ok {
+			setting.UpdatedAt = time.Unix(0, 0).UTC()
+		}
+		setting.Role = models.ValidateUserType(userType)
+		data = append(data, setting)
+	}
+	return data, nil
+}
+
+func (m *Memory) UpdateRoleMFARequired(ctx context.Context, uuidString string, userType int, required bool) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roleSettings[userType] = models.RoleSettingSchema{MFARequired: required, UpdatedAt: time.Now()}
+	return int64(userType), nil
+}
+
+func (key memoryAPIKey) active(now time.Time) bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now))
+}
+
+func (m *Memory) CreateAPIKey(ctx context.Context, uuidString string, userId int64, name string, prefix string, keyHash string, scopes []models.Permission, expiresAt time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	if scopes == nil {
+		scopes = make([]models.Permission, 0)
+	}
+	id := m.nextId()
+	m.apiKeys[id] = memoryAPIKey{
+		APIKeySchema: models.APIKeySchema{
+			Id:        id,
+			Name:      name,
+			Prefix:    prefix,
+			Scopes:    slices.Clone(scopes),
+			CreatedAt: time.Now(),
+			ExpiresAt: &expiresAt,
+		},
+		UserId:  userId,
+		KeyHash: keyHash,
+	}
+	return id, nil
+}
+
+func (m *Memory) AuthenticateAPIKey(ctx context.Context, uuidString string, keyHash string, clientIp string) (models.APIKeyOwnerSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, key := range m.apiKeys {
+		if key.KeyHash != keyHash || !key.active(now) {
+			continue
+		}
+		user, ok := m.users[key.UserId]
+		if !ok || user.DeactivatedAt != nil {
+			break
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIp = clientIp
+		m.apiKeys[id] = key
+		return models.APIKeyOwnerSchema{
+			Id:     key.Id,
+			Prefix: key.Prefix,
+			UserId: key.UserId,
+			Email:  user.Email,
+			Scopes: slices.Clone(key.Scopes),
+		}, nil
+	}
+	return models.APIKeyOwnerSchema{}, pgx.ErrNoRows
+}
+
+func (m *Memory) FetchUserAPIKeys(ctx context.Context, uuidString string, userId int64) ([]models.APIKeySchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var data []models.APIKeySchema
+	for _, id := range sortedIds(m.apiKeys) {
+		if key := m.apiKeys[id]; key.UserId == userId && key.active(now) {
+			data = append(data, key.APIKeySchema)
+		}
+	}
+	return data, nil
+}
+
+func (m *Memory) RevokeAPIKey(ctx context.Context, uuidString string, userId int64, apiKeyId int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[apiKeyId]
+	if !ok || key.UserId != userId || key.RevokedAt != nil {
+		return 0, nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	m.apiKeys[apiKeyId] = key
+	return apiKeyId, nil
+}
+
+func (m *Memory) RevokeUserAPIKeys(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	for id, key := range m.apiKeys {
+		if key.UserId == userId && key.RevokedAt == nil {
+			key.RevokedAt = &now
+			m.apiKeys[id] = key
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Memory) ReserveLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string, policy models.LoginThrottlePolicy) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	keys := [][2]string{{models.LOGINTHROTTLEACCOUNT, accountKey}, {models.LOGINTHROTTLEIP, clientIp}}
+	var blockedUntil time.Time
+	for _, key := range keys {
+		if throttle, ok := m.loginThrottles[key]; ok && throttle.BlockedUntil.After(now) && throttle.BlockedUntil.After(blockedUntil) {
+			blockedUntil = throttle.BlockedUntil
+		}
+	}
+	if !blockedUntil.IsZero() {
+		return blockedUntil, false, nil
+	}
+
+	lockedOut := false
+	for _, key := range keys {
+		throttle, ok := m.loginThrottles[key]
+		if !ok || throttle.LastFailureAt.Before(now.Add(-policy.Window)) {
+			throttle.Failures = 1
+		} else {
+			throttle.Failures++
+		}
+		throttle.LastFailureAt = now
+		if !ok {
+			throttle.BlockedUntil = now
+		}
+
+		block, lockout := policy.Block(key[0], throttle.Failures)
+		if block > 0 {
+			throttle.BlockedUntil = now.Add(block)
+		}
+		m.loginThrottles[key] = throttle
+		if !lockout || block <= 0 {
+			continue
+		}
+
+		lockedOut = true
+		lockoutData := models.LoginLockoutSchema{
+			Id:          m.nextId(),
+			Scope:       key[0],
+			Key:         key[1],
+			ClientIp:    clientIp,
+			Failures:    throttle.Failures,
+			LockedAt:    now,
+			LockedUntil: now.Add(block),
+		}
+		if key[0] == models.LOGINTHROTTLEACCOUNT {
+			for id, user := range m.users {
+				if strings.ToLower(user.Email) == key[1] && (lockoutData.UserId == nil || id < *lockoutData.UserId) {
+					userId := id
+					lockoutData.UserId = &userId
+				}
+			}
+		}
+		m.loginLockouts[lockoutData.Id] = lockoutData
+	}
+	return time.Time{}, lockedOut, nil
+}
+
+func (m *Memory) ReleaseLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range [][2]string{{models.LOGINTHROTTLEACCOUNT, accountKey}, {models.LOGINTHROTTLEIP, clientIp}} {
+		if throttle, ok := m.loginThrottles[key]; ok && throttle.Failures > 0 {
+			throttle.Failures--
+			m.loginThrottles[key] = throttle
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ClearAccountLoginFailures(ctx context.Context, uuidString string, accountKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.loginThrottles, [2]string{models.LOGINTHROTTLEACCOUNT, accountKey})
+	return nil
+}
+
+func (m *Memory) UnlockAccountLogin(ctx context.Context, uuidString string, userId int64, accountKey string, unlockedBy int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	key := [2]string{models.LOGINTHROTTLEACCOUNT, accountKey}
+	throttle, ok := m.loginThrottles[key]
+	blocked := ok && throttle.BlockedUntil.After(now)
+	delete(m.loginThrottles, key)
+
+	for id, lockout := range m.loginLockouts {
+		if lockout.Scope != models.LOGINTHROTTLEACCOUNT || lockout.UnlockedAt != nil || !lockout.LockedUntil.After(now) {
+			continue
+		}
+		if lockout.Key == accountKey || (lockout.UserId != nil && *lockout.UserId == userId) {
+			unlockedAt, unlockedByUser := now, unlockedBy
+			lockout.UnlockedAt = &unlockedAt
+			lockout.UnlockedBy = &unlockedByUser
+			m.loginLockouts[id] = lockout
+		}
+	}
+	return blocked, nil
+}
+
+func (m *Memory) FetchLoginLockouts(ctx context.Context, uuidString string, userId int64, limit int, offset int) ([]models.LoginLockoutSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []models.LoginLockoutSchema
+	for _, id := range sortedIds(m.loginLockouts) {
+		lockout := m.loginLockouts[id]
+		if userId == 0 || (lockout.UserId != nil && *lockout.UserId == userId) {
+			data = append(data, lockout)
+		}
+	}
+	sort.SliceStable(data, func(i, j int) bool { return data[i].LockedAt.After(data[j].LockedAt) })
+	count := len(data)
+	data = page(data, limit, offset)
+	if len(data) == 0 {
+		count = 0
+	}
+	return data, count, nil
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/open-lms-test-functionality/models"
+)
+
+type memoryIdentity struct {
+	UserId      int64
+	Email       string
+	LastLoginAt time.Time
+}
+
+type memoryLTIContext struct {
+	Id    int64
+	Label string
+	Title string
+}
+
+type memoryLTIResourceLinkKey struct {
+	ContextId      int64
+	ResourceLinkId string
+}
+
+type memoryLTIResourceLink struct {
+	Title       string
+	TestId      int64
+	LineItemURL string
+}
+
+func (m *Memory) ProvisionIdentity(ctx context.Context, uuidString string, data models.ExternalIdentitySchema, syncRole bool) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	key := [2]string{data.Issuer, data.Subject}
+	if identity, ok := m.identities[key]; ok {
+		identity.Email = data.Email
+		identity.LastLoginAt = now
+		m.identities[key] = identity
+		if syncRole {
+			m.users[identity.UserId].Type = strconv.Itoa(data.Type)
+		}
+		return identity.UserId, nil
+	}
+
+	var user *memoryUser
+	for _, existing := range m.users {
+		if existing.Email == data.Email {
+			user = existing
+		}
+	}
+	if user == nil {
+		id := m.nextId()
+		user = &memoryUser{
+			UserSchema: models.UserSchema{
+				Id:            id,
+				FirstName:     data.FirstName,
+				LastName:      data.LastName,
+				Email:         data.Email,
+				Type:          strconv.Itoa(data.Type),
+				EmailVerified: data.EmailVerified,
+			},
+			CreatedAt: now,
+		}
+		m.users[id] = user
+	} else if !data.EmailVerified {
+		return 0, models.ErrIdentityEmailConflict
+	} else {
+		user.EmailVerified = true
+		if syncRole {
+			user.Type = strconv.Itoa(data.Type)
+		}
+	}
+	m.identities[key] = memoryIdentity{UserId: user.Id, Email: data.Email, LastLoginAt: now}
+	return user.Id, nil
+}
+
+func (m *Memory) UseLTINonce(ctx context.Context, uuidString string, nonce string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for usedNonce, usedExpiresAt := range m.ltiNonces {
+		if usedExpiresAt.Before(now) {
+			delete(m.ltiNonces, usedNonce)
+		}
+	}
+	if _, ok := m.ltiNonces[nonce]; ok {
+		return false, nil
+	}
+	m.ltiNonces[nonce] = expiresAt
+	return true, nil
+}
+
+func (m *Memory) RecordLTILaunch(ctx context.Context, uuidString string, data models.LTILaunchSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[data.UserId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	if _, ok := m.tests[data.TestId]; data.TestId != 0 && !ok {
+		return 0, ErrMissingRecord
+	}
+
+	contextKey := [3]string{data.Issuer, data.DeploymentId, data.ContextId}
+	ltiContext, ok := m.ltiContexts[contextKey]
+	if !ok {
+		ltiContext.Id = m.nextId()
+	}
+	ltiContext.Label = data.ContextLabel
+	ltiContext.Title = data.ContextTitle
+	m.ltiContexts[contextKey] = ltiContext
+
+	if data.Roles == nil {
+		data.Roles = make([]string, 0)
+	}
+	m.ltiMembers[[2]int64{ltiContext.Id, data.UserId}] = slices.Clone(data.Roles)
+
+	if data.ResourceLinkId == "" {
+		return 0, nil
+	}
+	resourceLinkKey := memoryLTIResourceLinkKey{ContextId: ltiContext.Id, ResourceLinkId: data.ResourceLinkId}
+	resourceLink := m.ltiResourceLinks[resourceLinkKey]
+	resourceLink.Title = data.ResourceLinkTitle
+	if data.TestId != 0 {
+		resourceLink.TestId = data.TestId
+	}
+	if data.LineItemURL != "" {
+		resourceLink.LineItemURL = data.LineItemURL
+	}
+	m.ltiResourceLinks[resourceLinkKey] = resourceLink
+	return resourceLink.TestId, nil
+}
+
+func (m *Memory) FetchLTIScoreTargets(ctx context.Context, uuidString string, testId int64) ([]models.LTIScoreTargetSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inTest := make(map[int64]bool)
+	for _, testQuestion := range m.testQuestionary(testId) {
+		inTest[testQuestion.QuestionId] = true
+	}
+
+	// Each (issuer, line item, subject, user) is reported once, however many
+	// resource links or courses lead to it, as with the GROUP BY in SQL.
+	type targetKey struct {
+		Issuer, LineItemURL, PlatformUserId string
+		UserId                              int64
+	}
+	seen := make(map[targetKey]bool)
+	var data []models.LTIScoreTargetSchema
+	for resourceLinkKey, resourceLink := range m.ltiResourceLinks {
+		if resourceLink.TestId != testId || resourceLink.LineItemURL == "" {
+			continue
+		}
+		for contextKey, ltiContext := range m.ltiContexts {
+			if ltiContext.Id != resourceLinkKey.ContextId {
+				continue
+			}
+			for memberKey := range m.ltiMembers {
+				if memberKey[0] != ltiContext.Id {
+					continue
+				}
+				userId := memberKey[1]
+				for identityKey, identity := range m.identities {
+					if identity.UserId != userId || identityKey[0] != contextKey[0] {
+						continue
+					}
+					key := targetKey{contextKey[0], resourceLink.LineItemURL, identityKey[1], userId}
+					if seen[key] {
+						continue
+					}
+
+					answered, correct := false, int64(0)
+					for _, submission := range m.submissions {
+						if submission.TestId != testId || submission.UserId != userId {
+							continue
+						}
+						answered = true
+						if submission.AnswerStatus && inTest[submission.QuestionData.Id] {
+							correct++
+						}
+					}
+					if !answered {
+						continue
+					}
+					seen[key] = true
+					data = append(data, models.LTIScoreTargetSchema{
+						Issuer:         key.Issuer,
+						LineItemURL:    key.LineItemURL,
+						PlatformUserId: key.PlatformUserId,
+						UserId:         userId,
+						TotalQuestions: int64(len(inTest)),
+						CorrectAnswers: correct,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].LineItemURL != data[j].LineItemURL {
+			return data[i].LineItemURL < data[j].LineItemURL
+		}
+		return data[i].UserId < data[j].UserId
+	})
+	return data, nil
+}
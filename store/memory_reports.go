@@ -0,0 +1,436 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/open-lms-test-functionality/models"
+)
+
+type memoryQuestionTime struct {
+	FirstSeenAt    time.Time
+	LastSeenAt     time.Time
+	LastAnsweredAt *time.Time
+	ActiveTimeMs   int64
+}
+
+type memoryIntegrityEvent struct {
+	TestId    int64
+	UserId    int64
+	Type      string
+	ClientIp  string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+func (m *Memory) RecordQuestionEvent(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, data models.QuestionEventCreateSchema) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tests[testId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	if _, ok := m.users[userId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	if _, ok := m.questions[questionId]; !ok {
+		return 0, ErrMissingRecord
+	}
+
+	now := time.Now()
+	key := [3]int64{testId, userId, questionId}
+	questionTime, ok := m.questionTimes[key]
+	if !ok {
+		questionTime.FirstSeenAt = now
+	}
+	questionTime.LastSeenAt = now
+	if data.Type == models.QUESTIONEVENTANSWER {
+		questionTime.LastAnsweredAt = &now
+	}
+	questionTime.ActiveTimeMs += data.GetActiveMs()
+	m.questionTimes[key] = questionTime
+	return m.nextId(), nil
+}
+
+func (m *Memory) RecordIntegrityEvent(ctx context.Context, uuidString string, testId int64, userId int64, data models.IntegrityEventCreateSchema, clientIp string, userAgent string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tests[testId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	if _, ok := m.users[userId]; !ok {
+		return 0, ErrMissingRecord
+	}
+	id := m.nextId()
+	m.integrityEvents[id] = memoryIntegrityEvent{
+		TestId:    testId,
+		UserId:    userId,
+		Type:      data.Type,
+		ClientIp:  clientIp,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+	return id, nil
+}
+
+func (m *Memory) FetchSubmissionEvents(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, limit int, offset int) ([]models.SubmissionEventSchema, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []models.SubmissionEventSchema
+	for _, event := range m.submissionEvents {
+		if event.TestId == testId && event.UserId == userId && event.QuestionId == questionId {
+			data = append(data, event)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if !data[i].CreatedAt.Equal(data[j].CreatedAt) {
+			return data[i].CreatedAt.Before(data[j].CreatedAt)
+		}
+		return data[i].Id < data[j].Id
+	})
+	count := len(data)
+	data = page(data, limit, offset)
+	if len(data) == 0 {
+		count = 0
+	}
+	return data, count, nil
+}
+
+// inTestQuestionary reports whether the question is still part of the test;
+// the caller holds m.mu.
+func (m *Memory) inTestQuestionary(testId int64, questionId int64) bool {
+	for _, testQuestion := range m.testQuestions {
+		if testQuestion.TestId == testId && testQuestion.QuestionId == questionId {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) FetchUserProgress(ctx context.Context, uuidString string, userId int64) (models.UserProgressSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	progressData := models.UserProgressSchema{
+		UserId: userId,
+		Tests:  make([]models.TestProgressSchema, 0),
+		Tags:   make([]models.TagMasterySchema, 0),
+	}
+
+	tests := make(map[int64]*models.TestProgressSchema)
+	tags := make(map[string]*models.TagMasterySchema)
+	for _, submission := range m.submissions {
+		if submission.UserId != userId {
+			continue
+		}
+		questionId := submission.QuestionData.Id
+
+		test, ok := tests[submission.TestId]
+		if !ok {
+			totalQuestions := make(map[int64]bool)
+			for _, testQuestion := range m.testQuestionary(submission.TestId) {
+				totalQuestions[testQuestion.QuestionId] = true
+			}
+			test = &models.TestProgressSchema{
+				TestId:         submission.TestId,
+				TestTitle:      m.tests[submission.TestId].Title,
+				TotalQuestions: int64(len(totalQuestions)),
+			}
+			tests[submission.TestId] = test
+		}
+		if m.inTestQuestionary(submission.TestId, questionId) {
+			test.AnsweredQuestions++
+			if submission.AnswerStatus {
+				test.CorrectAnswers++
+			}
+		}
+		if submission.UpdatedAt.After(test.LastActivityAt) {
+			test.LastActivityAt = submission.UpdatedAt
+		}
+
+		for _, name := range m.questions[questionId].Tags {
+			tag, ok := tags[name]
+			if !ok {
+				tag = &models.TagMasterySchema{Tag: name}
+				tags[name] = tag
+			}
+			tag.AnsweredQuestions++
+			if submission.AnswerStatus {
+				tag.CorrectAnswers++
+			}
+		}
+	}
+
+	for _, test := range tests {
+		test.CompletionPercent = models.Percentage(test.AnsweredQuestions, test.TotalQuestions)
+		test.ScorePercent = models.Percentage(test.CorrectAnswers, test.TotalQuestions)
+		progressData.Tests = append(progressData.Tests, *test)
+	}
+	sort.Slice(progressData.Tests, func(i, j int) bool {
+		return progressData.Tests[i].LastActivityAt.After(progressData.Tests[j].LastActivityAt)
+	})
+	for _, tag := range tags {
+		tag.Mastery = models.CorrectRate(tag.CorrectAnswers, tag.AnsweredQuestions)
+		progressData.Tags = append(progressData.Tags, *tag)
+	}
+	sort.Slice(progressData.Tags, func(i, j int) bool { return progressData.Tags[i].Tag < progressData.Tags[j].Tag })
+	return progressData, nil
+}
+
+// statsBucket truncates t like DATE_TRUNC in a UTC session; weeks start on
+// Monday.
+func statsBucket(t time.Time, bucket string) time.Time {
+	year, month, day := t.UTC().Date()
+	switch bucket {
+	case models.STATSBUCKETMONTH:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case models.STATSBUCKETWEEK:
+		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// memoryStats accumulates the attempts of a question like the aggregates over
+// the answers CTE in SQL.
+type memoryStats struct {
+	attempts, correct int64
+	seconds           float64
+	timed             int64
+}
+
+func (stats *memoryStats) add(answerStatus bool, secondsToAnswer *float64) {
+	stats.attempts++
+	if answerStatus {
+		stats.correct++
+	}
+	if secondsToAnswer != nil {
+		stats.seconds += *secondsToAnswer
+		stats.timed++
+	}
+}
+
+func (stats *memoryStats) avgSeconds() float64 {
+	if stats.timed == 0 {
+		return 0
+	}
+	return stats.seconds / float64(stats.timed)
+}
+
+func (m *Memory) FetchQuestionStats(ctx context.Context, uuidString string, questionId int64, bucket string) (models.QuestionStatsSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statsData := models.QuestionStatsSchema{
+		QuestionId: questionId,
+		Tests:      make([]models.QuestionTestStatsSchema, 0),
+		TimeSeries: make([]models.QuestionStatsBucketSchema, 0),
+	}
+	testIds := make(map[int64]bool)
+	for _, testQuestion := range m.testQuestions {
+		if testQuestion.QuestionId == questionId {
+			testIds[testQuestion.TestId] = true
+		}
+	}
+	statsData.TestCount = int64(len(testIds))
+
+	// Order every submission of a student in a test by time, so each answer
+	// can be timed against the previous one, like LAG in SQL.
+	answers := make(map[[2]int64][]memorySubmission)
+	for _, submission := range m.submissions {
+		key := [2]int64{submission.TestId, submission.UserId}
+		answers[key] = append(answers[key], submission)
+	}
+
+	var totals memoryStats
+	perTest := make(map[int64]*memoryStats)
+	perBucket := make(map[time.Time]*memoryStats)
+	for _, submissions := range answers {
+		sort.Slice(submissions, func(i, j int) bool { return submissions[i].CreatedAt.Before(submissions[j].CreatedAt) })
+		for i, submission := range submissions {
+			if submission.QuestionData.Id != questionId {
+				continue
+			}
+			var secondsToAnswer *float64
+			if i > 0 {
+				seconds := submission.CreatedAt.Sub(submissions[i-1].CreatedAt).Seconds()
+				secondsToAnswer = &seconds
+			}
+			totals.add(submission.AnswerStatus, secondsToAnswer)
+
+			if _, ok := m.tests[submission.TestId]; ok {
+				if perTest[submission.TestId] == nil {
+					perTest[submission.TestId] = &memoryStats{}
+				}
+				perTest[submission.TestId].add(submission.AnswerStatus, secondsToAnswer)
+			}
+
+			bucketAt := statsBucket(submission.CreatedAt, bucket)
+			if perBucket[bucketAt] == nil {
+				perBucket[bucketAt] = &memoryStats{}
+			}
+			perBucket[bucketAt].add(submission.AnswerStatus, secondsToAnswer)
+		}
+	}
+
+	statsData.Attempts = totals.attempts
+	statsData.CorrectAttempts = totals.correct
+	statsData.CorrectRate = models.CorrectRate(totals.correct, totals.attempts)
+	statsData.AvgSecondsToAnswer = totals.avgSeconds()
+	for testId, stats := range perTest {
+		statsData.Tests = append(statsData.Tests, models.QuestionTestStatsSchema{
+			TestId:             testId,
+			TestTitle:          m.tests[testId].Title,
+			Attempts:           stats.attempts,
+			CorrectAttempts:    stats.correct,
+			CorrectRate:        models.CorrectRate(stats.correct, stats.attempts),
+			AvgSecondsToAnswer: stats.avgSeconds(),
+		})
+	}
+	sort.Slice(statsData.Tests, func(i, j int) bool { return statsData.Tests[i].TestId > statsData.Tests[j].TestId })
+	for bucketAt, stats := range perBucket {
+		statsData.TimeSeries = append(statsData.TimeSeries, models.QuestionStatsBucketSchema{
+			Bucket:             bucketAt,
+			Attempts:           stats.attempts,
+			CorrectAttempts:    stats.correct,
+			CorrectRate:        models.CorrectRate(stats.correct, stats.attempts),
+			AvgSecondsToAnswer: stats.avgSeconds(),
+		})
+	}
+	sort.Slice(statsData.TimeSeries, func(i, j int) bool { return statsData.TimeSeries[i].Bucket.Before(statsData.TimeSeries[j].Bucket) })
+	return statsData, nil
+}
+
+func (m *Memory) FetchQuestionTimingReport(ctx context.Context, uuidString string, testId int64, thresholdMs int64, flagCount int64) ([]models.QuestionTimingReportSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reports := make(map[int64]*models.QuestionTimingReportSchema)
+	activeTimeMs := make(map[int64]int64)
+	for _, submission := range m.submissions {
+		if submission.TestId != testId {
+			continue
+		}
+		user, ok := m.users[submission.UserId]
+		if !ok {
+			continue
+		}
+		report, ok := reports[user.Id]
+		if !ok {
+			report = &models.QuestionTimingReportSchema{
+				UserId:    user.Id,
+				FirstName: user.FirstName,
+				LastName:  user.LastName,
+				Email:     user.Email,
+			}
+			reports[user.Id] = report
+		}
+		report.AnsweredCount++
+		questionTime, ok := m.questionTimes[[3]int64{testId, user.Id, submission.QuestionData.Id}]
+		if !ok {
+			report.UntrackedCount++
+			report.FastAnswerCount++
+			continue
+		}
+		report.TrackedCount++
+		activeTimeMs[user.Id] += questionTime.ActiveTimeMs
+		threshold := time.Duration(thresholdMs) * time.Millisecond
+		if questionTime.ActiveTimeMs < thresholdMs || submission.CreatedAt.Before(questionTime.FirstSeenAt.Add(threshold)) {
+			report.FastAnswerCount++
+		}
+	}
+
+	data := make([]models.QuestionTimingReportSchema, 0, len(reports))
+	for userId, report := range reports {
+		if report.TrackedCount > 0 {
+			report.AvgActiveTimeMs = float64(activeTimeMs[userId]) / float64(report.TrackedCount)
+		}
+		report.Flagged = report.FastAnswerCount >= flagCount
+		data = append(data, *report)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].FastAnswerCount != data[j].FastAnswerCount {
+			return data[i].FastAnswerCount > data[j].FastAnswerCount
+		}
+		return data[i].UserId < data[j].UserId
+	})
+	return data, nil
+}
+
+func (m *Memory) FetchIntegrityReport(ctx context.Context, uuidString string, testId int64, maxEvents int64, maxIPChanges int64) ([]models.IntegrityReportSchema, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reports := make(map[int64]*models.IntegrityReportSchema)
+	reportedIPChanges := make(map[int64]int64)
+	ips := make(map[int64]map[string]bool)
+	participant := func(userId int64) *models.IntegrityReportSchema {
+		report, ok := reports[userId]
+		if !ok {
+			report = &models.IntegrityReportSchema{UserId: userId}
+			reports[userId] = report
+			ips[userId] = make(map[string]bool)
+		}
+		return report
+	}
+
+	for _, submission := range m.submissions {
+		if submission.TestId != testId {
+			continue
+		}
+		report := participant(submission.UserId)
+		report.AnsweredQuestions++
+		if submission.AnswerStatus {
+			report.CorrectAnswers++
+		}
+	}
+	for _, event := range m.integrityEvents {
+		if event.TestId != testId {
+			continue
+		}
+		report := participant(event.UserId)
+		switch event.Type {
+		case models.INTEGRITYEVENTTABBLUR:
+			report.TabBlurCount++
+		case models.INTEGRITYEVENTCOPY:
+			report.CopyCount++
+		case models.INTEGRITYEVENTPASTE:
+			report.PasteCount++
+		case models.INTEGRITYEVENTFULLSCREENEXIT:
+			report.FullscreenExitCount++
+		case models.INTEGRITYEVENTIPCHANGE:
+			reportedIPChanges[event.UserId]++
+		}
+		if event.Type != models.INTEGRITYEVENTTABFOCUS {
+			report.SuspiciousEvents++
+		}
+		if event.ClientIp != "" {
+			ips[event.UserId][event.ClientIp] = true
+		}
+	}
+	for _, event := range m.submissionEvents {
+		if _, ok := reports[event.UserId]; ok && event.TestId == testId && event.ClientIp != "" {
+			ips[event.UserId][event.ClientIp] = true
+		}
+	}
+
+	data := make([]models.IntegrityReportSchema, 0, len(reports))
+	for userId, report := range reports {
+		user, ok := m.users[userId]
+		if !ok {
+			continue
+		}
+		report.FirstName = user.FirstName
+		report.LastName = user.LastName
+		report.Email = user.Email
+		report.DistinctIPCount = int64(len(ips[userId]))
+		report.ApplyFlags(reportedIPChanges[userId], maxEvents, maxIPChanges)
+		data = append(data, *report)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].UserId < data[j].UserId })
+	return data, nil
+}
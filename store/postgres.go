@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-lms-test-functionality/models"
+)
+
+// Postgres stores everything through the models package and its connection
+// pool.
+type Postgres struct{}
+
+// NewPostgres returns Stores backed by the application database.
+func NewPostgres() Stores {
+	postgres := Postgres{}
+	return Stores{
+		UnitOfWork:  postgres,
+		Users:       postgres,
+		Tests:       postgres,
+		Questions:   postgres,
+		Submissions: postgres,
+
+		Sessions:       postgres,
+		Tokens:         postgres,
+		MFA:            postgres,
+		APIKeys:        postgres,
+		LoginThrottles: postgres,
+		Identities:     postgres,
+		Events:         postgres,
+		Reports:        postgres,
+	}
+}
+
+// WithTx runs fn in a serializable transaction and retries it on
+// serialization failures, see models.WithTx.
+func (Postgres) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return models.WithTx(ctx, fn)
+}
+
+// WithReadCommittedTx runs fn in a read committed transaction, see
+// models.WithReadCommittedTx.
+func (Postgres) WithReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return models.WithReadCommittedTx(ctx, fn)
+}
+
+func (Postgres) CreateUser(ctx context.Context, uuidString string, data models.UserCreateSchema) (int64, error) {
+	return data.Insert(ctx, uuidString)
+}
+
+func (Postgres) UpdateUser(ctx context.Context, uuidString string, userId int64, data models.UserCreateSchema) (int64, error) {
+	return data.Update(ctx, uuidString, userId)
+}
+
+func (Postgres) FetchUserForAuth(ctx context.Context, email string) models.UserSchema {
+	return models.FetchUserForAuth(ctx, email)
+}
+
+func (Postgres) FetchUserIdentity(ctx context.Context, email string) models.UserSchema {
+	userData := models.FetchUserForAuth(ctx, email)
+	userData.Password = ""
+	return userData
+}
+
+// InvalidateUser does nothing: Postgres always returns the current rows.
+func (Postgres) InvalidateUser(ctx context.Context, uuidString string, userId int64) {}
+
+func (Postgres) FetchUserById(ctx context.Context, uuidString string, userId int64) (models.AdminUserSchema, error) {
+	return models.FetchUserById(ctx, uuidString, userId)
+}
+
+func (Postgres) SearchUsers(ctx context.Context, uuidString string, search string, userType int, status string, limit int, offset int) ([]models.AdminUserSchema, int, error) {
+	return models.SearchUsers(ctx, uuidString, search, userType, status, limit, offset)
+}
+
+func (Postgres) CountActiveUsersOfType(ctx context.Context, uuidString string, userType int) (int64, error) {
+	return models.CountActiveUsersOfType(ctx, uuidString, userType)
+}
+
+func (Postgres) UpdateUserType(ctx context.Context, uuidString string, userId int64, userType int) (int64, error) {
+	return models.UpdateUserType(ctx, uuidString, userId, userType)
+}
+
+func (Postgres) SetPasswordResetRequired(ctx context.Context, uuidString string, userId int64, required bool) (int64, error) {
+	return models.SetPasswordResetRequired(ctx, uuidString, userId, required)
+}
+
+func (Postgres) DeactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	return models.DeactivateUser(ctx, uuidString, userId)
+}
+
+func (Postgres) ReactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	return models.ReactivateUser(ctx, uuidString, userId)
+}
+
+func (Postgres) UpdateUserPassword(ctx context.Context, uuidString string, userId int64, passwordHash string) (int64, error) {
+	return models.UpdateUserPassword(ctx, uuidString, userId, passwordHash)
+}
+
+func (Postgres) CreateTest(ctx context.Context, uuidString string, data models.TestCreateSchema) (int64, error) {
+	return data.Insert(ctx, uuidString)
+}
+
+func (Postgres) UpdateTest(ctx context.Context, uuidString string, testId int64, data models.TestCreateSchema) (int64, error) {
+	return data.Update(ctx, uuidString, testId)
+}
+
+func (Postgres) DeleteTest(ctx context.Context, uuidString string, testId int64) (bool, error) {
+	return models.DeleteTest(ctx, uuidString, testId)
+}
+
+func (Postgres) FetchTest(ctx context.Context, uuidString string, testId int64) (models.TestResponseSchema, error) {
+	return models.FetchTest(ctx, uuidString, testId)
+}
+
+func (Postgres) FetchTests(ctx context.Context, uuidString string, page models.Page) ([]models.TestResponseSchema, int, error) {
+	return models.FetchTests(ctx, uuidString, page)
+}
+
+func (Postgres) CreateTestQuestionary(ctx context.Context, uuidString string, testId int64, questionIds []int64) (int64, error) {
+	return models.CreateTestQuestionary(ctx, uuidString, testId, questionIds)
+}
+
+func (Postgres) DeleteTestQuestionary(ctx context.Context, uuidString string, testId int64, questionId int64) (bool, error) {
+	return models.DeleteTestQuestionary(ctx, uuidString, testId, questionId)
+}
+
+func (Postgres) FetchTestQuestionaryForTeacher(ctx context.Context, uuidString string, testId int64, page models.Page) ([]models.TestQuestionsSchema, int, error) {
+	return models.FetchTestQuestionaryForTeacher(ctx, uuidString, testId, page)
+}
+
+func (Postgres) FetchTestQuestionaryForStudent(ctx context.Context, uuidString string, testId int64, page models.Page) ([]models.TestQuestionSchemaForTakeTest, int, error) {
+	return models.FetchTestQuestionaryForStrudent(ctx, uuidString, testId, page)
+}
+
+func (Postgres) CreateQuestion(ctx context.Context, uuidString string, data models.QuestionCreateSchema) (int64, error) {
+	return data.Insert(ctx, uuidString)
+}
+
+func (Postgres) UpdateQuestion(ctx context.Context, uuidString string, questionId int64, data models.QuestionCreateSchema) (int64, error) {
+	return data.Update(ctx, uuidString, questionId)
+}
+
+func (Postgres) DeleteQuestion(ctx context.Context, uuidString string, questionId int64) (bool, error) {
+	return models.DeleteQuestion(ctx, uuidString, questionId)
+}
+
+func (Postgres) FetchQuestion(ctx context.Context, uuidString string, questionId int64) (models.QuestionResponseSchema, error) {
+	return models.FetchQuestion(ctx, uuidString, questionId)
+}
+
+func (Postgres) FetchQuestions(ctx context.Context, uuidString string, page models.Page, random bool) ([]models.QuestionResponseSchema, int, error) {
+	return models.FetchQuestions(ctx, uuidString, page, random)
+}
+
+func (Postgres) CreateOrUpdateTestQuestionSubmission(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, answerData map[string][]string, questionData models.QuestionResponseSchema, clientIp string, userAgent string) (int64, error) {
+	return models.CreateOrUpdateTestQuestionSubmission(ctx, uuidString, testId, userId, questionId, answerData, questionData, clientIp, userAgent)
+}
+
+func (Postgres) FetchTestQuestionSubmissions(ctx context.Context, uuidString string, testId int64, userId int64, page models.Page) ([]models.TestQuestionSubmissionSchema, int, error) {
+	return models.FetchTestQuestionSubmissions(ctx, uuidString, testId, userId, page)
+}
+
+func (Postgres) CreateSession(ctx context.Context, uuidString string, sessionId string, userId int64, clientIp string, userAgent string, refreshTokenHash string, expiresAt time.Time) error {
+	return models.CreateSession(ctx, uuidString, sessionId, userId, clientIp, userAgent, refreshTokenHash, expiresAt)
+}
+
+func (Postgres) IsSessionActive(ctx context.Context, uuidString string, sessionId string, userId int64) (bool, error) {
+	return models.IsSessionActive(ctx, uuidString, sessionId, userId)
+}
+
+func (Postgres) RotateRefreshToken(ctx context.Context, uuidString string, refreshTokenHash string, newRefreshTokenHash string, expiresAt time.Time) (models.SessionUserSchema, error) {
+	return models.RotateRefreshToken(ctx, uuidString, refreshTokenHash, newRefreshTokenHash, expiresAt)
+}
+
+func (Postgres) FetchUserSessions(ctx context.Context, uuidString string, userId int64) ([]models.SessionSchema, error) {
+	return models.FetchUserSessions(ctx, uuidString, userId)
+}
+
+func (Postgres) RevokeSession(ctx context.Context, uuidString string, userId int64, sessionId string, reason string) (int64, error) {
+	return models.RevokeSession(ctx, uuidString, userId, sessionId, reason)
+}
+
+func (Postgres) RevokeUserSessions(ctx context.Context, uuidString string, userId int64, reason string) (int64, error) {
+	return models.RevokeUserSessions(ctx, uuidString, userId, reason)
+}
+
+func (Postgres) CreateUserToken(ctx context.Context, uuidString string, userId int64, purpose string, tokenHash string, expiresAt time.Time) (int64, error) {
+	return models.CreateUserToken(ctx, uuidString, userId, purpose, tokenHash, expiresAt)
+}
+
+func (Postgres) VerifyEmailWithToken(ctx context.Context, uuidString string, tokenHash string) (int64, error) {
+	return models.VerifyEmailWithToken(ctx, uuidString, tokenHash)
+}
+
+func (Postgres) ResetPasswordWithToken(ctx context.Context, uuidString string, tokenHash string, passwordHash string) (int64, error) {
+	return models.ResetPasswordWithToken(ctx, uuidString, tokenHash, passwordHash)
+}
+
+func (Postgres) FetchMFAState(ctx context.Context, uuidString string, userId int64, userType int) (models.MFAStateSchema, error) {
+	return models.FetchMFAState(ctx, uuidString, userId, userType)
+}
+
+func (Postgres) StartMFAEnrollment(ctx context.Context, uuidString string, userId int64, encryptedSecret string) error {
+	return models.StartMFAEnrollment(ctx, uuidString, userId, encryptedSecret)
+}
+
+func (Postgres) UseTOTPStep(ctx context.Context, uuidString string, userId int64, step int64, enable bool) (bool, error) {
+	return models.UseTOTPStep(ctx, uuidString, userId, step, enable)
+}
+
+func (Postgres) ReplaceRecoveryCodes(ctx context.Context, uuidString string, userId int64, codeHashes []string) error {
+	return models.ReplaceRecoveryCodes(ctx, uuidString, userId, codeHashes)
+}
+
+func (Postgres) UseRecoveryCode(ctx context.Context, uuidString string, userId int64, codeHash string) (bool, error) {
+	return models.UseRecoveryCode(ctx, uuidString, userId, codeHash)
+}
+
+func (Postgres) DisableMFA(ctx context.Context, uuidString string, userId int64) (bool, error) {
+	return models.DisableMFA(ctx, uuidString, userId)
+}
+
+func (Postgres) FetchRoleSettings(ctx context.Context, uuidString string) ([]models.RoleSettingSchema, error) {
+	return models.FetchRoleSettings(ctx, uuidString)
+}
+
+func (Postgres) UpdateRoleMFARequired(ctx context.Context, uuidString string, userType int, required bool) (int64, error) {
+	return models.UpdateRoleMFARequired(ctx, uuidString, userType, required)
+}
+
+func (Postgres) CreateAPIKey(ctx context.Context, uuidString string, userId int64, name string, prefix string, keyHash string, scopes []models.Permission, expiresAt time.Time) (int64, error) {
+	return models.CreateAPIKey(ctx, uuidString, userId, name, prefix, keyHash, scopes, expiresAt)
+}
+
+func (Postgres) AuthenticateAPIKey(ctx context.Context, uuidString string, keyHash string, clientIp string) (models.APIKeyOwnerSchema, error) {
+	return models.AuthenticateAPIKey(ctx, uuidString, keyHash, clientIp)
+}
+
+func (Postgres) FetchUserAPIKeys(ctx context.Context, uuidString string, userId int64) ([]models.APIKeySchema, error) {
+	return models.FetchUserAPIKeys(ctx, uuidString, userId)
+}
+
+func (Postgres) RevokeAPIKey(ctx context.Context, uuidString string, userId int64, apiKeyId int64) (int64, error) {
+	return models.RevokeAPIKey(ctx, uuidString, userId, apiKeyId)
+}
+
+func (Postgres) RevokeUserAPIKeys(ctx context.Context, uuidString string, userId int64) (int64, error) {
+	return models.RevokeUserAPIKeys(ctx, uuidString, userId)
+}
+
+func (Postgres) ReserveLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string, policy models.LoginThrottlePolicy) (time.Time, bool, error) {
+	return models.ReserveLoginAttempt(ctx, uuidString, accountKey, clientIp, policy)
+}
+
+func (Postgres) ReleaseLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string) error {
+	return models.ReleaseLoginAttempt(ctx, uuidString, accountKey, clientIp)
+}
+
+func (Postgres) ClearAccountLoginFailures(ctx context.Context, uuidString string, accountKey string) error {
+	return models.ClearAccountLoginFailures(ctx, uuidString, accountKey)
+}
+
+func (Postgres) UnlockAccountLogin(ctx context.Context, uuidString string, userId int64, accountKey string, unlockedBy int64) (bool, error) {
+	return models.UnlockAccountLogin(ctx, uuidString, userId, accountKey, unlockedBy)
+}
+
+func (Postgres) FetchLoginLockouts(ctx context.Context, uuidString string, userId int64, limit int, offset int) ([]models.LoginLockoutSchema, int, error) {
+	return models.FetchLoginLockouts(ctx, uuidString, userId, limit, offset)
+}
+
+func (Postgres) ProvisionIdentity(ctx context.Context, uuidString string, data models.ExternalIdentitySchema, syncRole bool) (int64, error) {
+	return data.Provision(ctx, uuidString, syncRole)
+}
+
+func (Postgres) UseLTINonce(ctx context.Context, uuidString string, nonce string, expiresAt time.Time) (bool, error) {
+	return models.UseLTINonce(ctx, uuidString, nonce, expiresAt)
+}
+
+func (Postgres) RecordLTILaunch(ctx context.Context, uuidString string, data models.LTILaunchSchema) (int64, error) {
+	return data.Record(ctx, uuidString)
+}
+
+func (Postgres) FetchLTIScoreTargets(ctx context.Context, uuidString string, testId int64) ([]models.LTIScoreTargetSchema, error) {
+	return models.FetchLTIScoreTargets(ctx, uuidString, testId)
+}
+
+func (Postgres) RecordQuestionEvent(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, data models.QuestionEventCreateSchema) (int64, error) {
+	return data.Record(ctx, uuidString, testId, userId, questionId)
+}
+
+func (Postgres) RecordIntegrityEvent(ctx context.Context, uuidString string, testId int64, userId int64, data models.IntegrityEventCreateSchema, clientIp string, userAgent string) (int64, error) {
+	return data.Insert(ctx, uuidString, testId, userId, clientIp, userAgent)
+}
+
+func (Postgres) FetchSubmissionEvents(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, limit int, offset int) ([]models.SubmissionEventSchema, int, error) {
+	return models.FetchSubmissionEvents(ctx, uuidString, testId, userId, questionId, limit, offset)
+}
+
+func (Postgres) FetchUserProgress(ctx context.Context, uuidString string, userId int64) (models.UserProgressSchema, error) {
+	return models.FetchUserProgress(ctx, uuidString, userId)
+}
+
+func (Postgres) FetchQuestionStats(ctx context.Context, uuidString string, questionId int64, bucket string) (models.QuestionStatsSchema, error) {
+	return models.FetchQuestionStats(ctx, uuidString, questionId, bucket)
+}
+
+func (Postgres) FetchQuestionTimingReport(ctx context.Context, uuidString string, testId int64, thresholdMs int64, flagCount int64) ([]models.QuestionTimingReportSchema, error) {
+	return models.FetchQuestionTimingReport(ctx, uuidString, testId, thresholdMs, flagCount)
+}
+
+func (Postgres) FetchIntegrityReport(ctx context.Context, uuidString string, testId int64, maxEvents int64, maxIPChanges int64) ([]models.IntegrityReportSchema, error) {
+	return models.FetchIntegrityReport(ctx, uuidString, testId, maxEvents, maxIPChanges)
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/open-lms-test-functionality/models"
+)
+
+// UserStore keeps user accounts.
+type UserStore interface {
+	CreateUser(ctx context.Context, uuidString string, data models.UserCreateSchema) (int64, error)
+	UpdateUser(ctx context.Context, uuidString string, userId int64, data models.UserCreateSchema) (int64, error)
+	FetchUserForAuth(ctx context.Context, email string) models.UserSchema
+	// FetchUserIdentity is FetchUserForAuth without the password hash, for
+	// authorizing requests. It may be served from a cache.
+	FetchUserIdentity(ctx context.Context, email string) models.UserSchema
+	// InvalidateUser drops cached copies of the user after it was changed
+	// outside this store, e.g. by a token or identity provider flow.
+	InvalidateUser(ctx context.Context, uuidString string, userId int64)
+	FetchUserById(ctx context.Context, uuidString string, userId int64) (models.AdminUserSchema, error)
+	SearchUsers(ctx context.Context, uuidString string, search string, userType int, status string, limit int, offset int) ([]models.AdminUserSchema, int, error)
+	CountActiveUsersOfType(ctx context.Context, uuidString string, userType int) (int64, error)
+	UpdateUserType(ctx context.Context, uuidString string, userId int64, userType int) (int64, error)
+	SetPasswordResetRequired(ctx context.Context, uuidString string, userId int64, required bool) (int64, error)
+	DeactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error)
+	ReactivateUser(ctx context.Context, uuidString string, userId int64) (int64, error)
+	UpdateUserPassword(ctx context.Context, uuidString string, userId int64, passwordHash string) (int64, error)
+}
+
+// SessionStore keeps the server side sessions behind access and refresh
+// tokens.
+type SessionStore interface {
+	CreateSession(ctx context.Context, uuidString string, sessionId string, userId int64, clientIp string, userAgent string, refreshTokenHash string, expiresAt time.Time) error
+	IsSessionActive(ctx context.Context, uuidString string, sessionId string, userId int64) (bool, error)
+	RotateRefreshToken(ctx context.Context, uuidString string, refreshTokenHash string, newRefreshTokenHash string, expiresAt time.Time) (models.SessionUserSchema, error)
+	FetchUserSessions(ctx context.Context, uuidString string, userId int64) ([]models.SessionSchema, error)
+	RevokeSession(ctx context.Context, uuidString string, userId int64, sessionId string, reason string) (int64, error)
+	RevokeUserSessions(ctx context.Context, uuidString string, userId int64, reason string) (int64, error)
+}
+
+// TokenStore keeps the single use tokens sent by email.
+type TokenStore interface {
+	CreateUserToken(ctx context.Context, uuidString string, userId int64, purpose string, tokenHash string, expiresAt time.Time) (int64, error)
+	VerifyEmailWithToken(ctx context.Context, uuidString string, tokenHash string) (int64, error)
+	ResetPasswordWithToken(ctx context.Context, uuidString string, tokenHash string, passwordHash string) (int64, error)
+}
+
+// MFAStore keeps second factors and the roles that require one.
+type MFAStore interface {
+	FetchMFAState(ctx context.Context, uuidString string, userId int64, userType int) (models.MFAStateSchema, error)
+	StartMFAEnrollment(ctx context.Context, uuidString string, userId int64, encryptedSecret string) error
+	UseTOTPStep(ctx context.Context, uuidString string, userId int64, step int64, enable bool) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, uuidString string, userId int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, uuidString string, userId int64, codeHash string) (bool, error)
+	DisableMFA(ctx context.Context, uuidString string, userId int64) (bool, error)
+	FetchRoleSettings(ctx context.Context, uuidString string) ([]models.RoleSettingSchema, error)
+	UpdateRoleMFARequired(ctx context.Context, uuidString string, userType int, required bool) (int64, error)
+}
+
+// APIKeyStore keeps the API keys users create for service access.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, uuidString string, userId int64, name string, prefix string, keyHash string, scopes []models.Permission, expiresAt time.Time) (int64, error)
+	AuthenticateAPIKey(ctx context.Context, uuidString string, keyHash string, clientIp string) (models.APIKeyOwnerSchema, error)
+	FetchUserAPIKeys(ctx context.Context, uuidString string, userId int64) ([]models.APIKeySchema, error)
+	RevokeAPIKey(ctx context.Context, uuidString string, userId int64, apiKeyId int64) (int64, error)
+	RevokeUserAPIKeys(ctx context.Context, uuidString string, userId int64) (int64, error)
+}
+
+// LoginThrottleStore counts login attempts and keeps the lockout audit trail.
+type LoginThrottleStore interface {
+	ReserveLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string, policy models.LoginThrottlePolicy) (time.Time, bool, error)
+	ReleaseLoginAttempt(ctx context.Context, uuidString string, accountKey string, clientIp string) error
+	ClearAccountLoginFailures(ctx context.Context, uuidString string, accountKey string) error
+	UnlockAccountLogin(ctx context.Context, uuidString string, userId int64, accountKey string, unlockedBy int64) (bool, error)
+	FetchLoginLockouts(ctx context.Context, uuidString string, userId int64, limit int, offset int) ([]models.LoginLockoutSchema, int, error)
+}
+
+// IdentityStore links users to external identity providers and LTI platforms.
+type IdentityStore interface {
+	ProvisionIdentity(ctx context.Context, uuidString string, data models.ExternalIdentitySchema, syncRole bool) (int64, error)
+	UseLTINonce(ctx context.Context, uuidString string, nonce string, expiresAt time.Time) (bool, error)
+	RecordLTILaunch(ctx context.Context, uuidString string, data models.LTILaunchSchema) (int64, error)
+	FetchLTIScoreTargets(ctx context.Context, uuidString string, testId int64) ([]models.LTIScoreTargetSchema, error)
+}
+
+// EventStore keeps what students do while taking a test.
+type EventStore interface {
+	RecordQuestionEvent(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, data models.QuestionEventCreateSchema) (int64, error)
+	RecordIntegrityEvent(ctx context.Context, uuidString string, testId int64, userId int64, data models.IntegrityEventCreateSchema, clientIp string, userAgent string) (int64, error)
+	FetchSubmissionEvents(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, limit int, offset int) ([]models.SubmissionEventSchema, int, error)
+}
+
+// ReportStore computes the reports over submissions and events.
+type ReportStore interface {
+	FetchUserProgress(ctx context.Context, uuidString string, userId int64) (models.UserProgressSchema, error)
+	FetchQuestionStats(ctx context.Context, uuidString string, questionId int64, bucket string) (models.QuestionStatsSchema, error)
+	FetchQuestionTimingReport(ctx context.Context, uuidString string, testId int64, thresholdMs int64, flagCount int64) ([]models.QuestionTimingReportSchema, error)
+	FetchIntegrityReport(ctx context.Context, uuidString string, testId int64, maxEvents int64, maxIPChanges int64) ([]models.IntegrityReportSchema, error)
+}
+
+// TestStore keeps tests and the questions assigned to them.
+type TestStore interface {
+	CreateTest(ctx context.Context, uuidString string, data models.TestCreateSchema) (int64, error)
+	UpdateTest(ctx context.Context, uuidString string, testId int64, data models.TestCreateSchema) (int64, error)
+	DeleteTest(ctx context.Context, uuidString string, testId int64) (bool, error)
+	FetchTest(ctx context.Context, uuidString string, testId int64) (models.TestResponseSchema, error)
+	FetchTests(ctx context.Context, uuidString string, page models.Page) ([]models.TestResponseSchema, int, error)
+	CreateTestQuestionary(ctx context.Context, uuidString string, testId int64, questionIds []int64) (int64, error)
+	DeleteTestQuestionary(ctx context.Context, uuidString string, testId int64, questionId int64) (bool, error)
+	FetchTestQuestionaryForTeacher(ctx context.Context, uuidString string, testId int64, page models.Page) ([]models.TestQuestionsSchema, int, error)
+	FetchTestQuestionaryForStudent(ctx context.Context, uuidString string, testId int64, page models.Page) ([]models.TestQuestionSchemaForTakeTest, int, error)
+}
+
+// QuestionStore keeps the question bank.
+type QuestionStore interface {
+	CreateQuestion(ctx context.Context, uuidString string, data models.QuestionCreateSchema) (int64, error)
+	UpdateQuestion(ctx context.Context, uuidString string, questionId int64, data models.QuestionCreateSchema) (int64, error)
+	DeleteQuestion(ctx context.Context, uuidString string, questionId int64) (bool, error)
+	FetchQuestion(ctx context.Context, uuidString string, questionId int64) (models.QuestionResponseSchema, error)
+	FetchQuestions(ctx context.Context, uuidString string, page models.Page, random bool) ([]models.QuestionResponseSchema, int, error)
+}
+
+// SubmissionStore keeps the answers students submit to test questions.
+type SubmissionStore interface {
+	CreateOrUpdateTestQuestionSubmission(ctx context.Context, uuidString string, testId int64, userId int64, questionId int64, answerData map[string][]string, questionData models.QuestionResponseSchema, clientIp string, userAgent string) (int64, error)
+	FetchTestQuestionSubmissions(ctx context.Context, uuidString string, testId int64, userId int64, page models.Page) ([]models.TestQuestionSubmissionSchema, int, error)
+}
+
+// UnitOfWork runs fn so that every store call made with the context fn is
+// given commits or rolls back together. WithTx isolates fn from concurrent
+// units of work; WithReadCommittedTx lets fn see their commits, for work
+// that stays consistent on its own, such as an upsert.
+type UnitOfWork interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	WithReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Stores bundles the storage used by the HTTP handlers.
+type Stores struct {
+	UnitOfWork
+	Users       UserStore
+	Tests       TestStore
+	Questions   QuestionStore
+	Submissions SubmissionStore
+
+	Sessions       SessionStore
+	Tokens         TokenStore
+	MFA            MFAStore
+	APIKeys        APIKeyStore
+	LoginThrottles LoginThrottleStore
+	Identities     IdentityStore
+	Events         EventStore
+	Reports        ReportStore
+}
+
+// Default is the storage the handlers and middleware use. It is backed by
+// Postgres unless replaced, e.g. with NewMemory() when testing handlers.
+var Default = NewPostgres()
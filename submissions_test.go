@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-lms-test-functionality/core"
+	"github.com/open-lms-test-functionality/models"
+	"github.com/open-lms-test-functionality/store"
+)
+
+// newPostgresTestServer is newTestServer backed by the database of
+// TEST_DB_STRING, migrated to the latest version. Tests that need Postgres
+// are skipped without it.
+func newPostgresTestServer(t *testing.T) *testServer {
+	t.Helper()
+	databaseString := os.Getenv("TEST_DB_STRING")
+	if databaseString == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	s := newTestServer(t)
+	core.Config.DBString = databaseString
+	core.Config.DBConnectAttempts = 1
+	core.Config.Environment = "local"
+	if err := models.CreateConnection(); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(models.CloseConnections)
+	if err := models.MigrateUp(); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	s.stores = store.NewPostgres()
+	store.Default = s.stores
+	return s
+}
+
+func TestConcurrentSubmissionsKeepOneRow(t *testing.T) {
+	s := newPostgresTestServer(t)
+	// The database outlives the test, so every run uses new accounts.
+	run := time.Now().UnixNano()
+	teacherEmail := fmt.Sprintf("teacher-%d@example.com", run)
+	studentEmail := fmt.Sprintf("student-%d@example.com", run)
+	s.register(teacherEmail, "secret-password", "teacher")
+	studentId := s.register(studentEmail, "secret-password", "student")
+	teacherToken, _ := s.login(teacherEmail, "secret-password")
+	studentToken, _ := s.login(studentEmail, "secret-password")
+
+	code, response := s.do(http.MethodPost, "/auth/test", teacherToken, map[string]string{"title": "Concurrent submissions"})
+	if code != http.StatusOK {
+		t.Fatalf("create test: status %d, %v", code, response)
+	}
+	testId := int64(response["message"].(float64))
+	code, response = s.do(http.MethodPost, "/auth/question", teacherToken, map[string]any{
+		"type":          "multiple_choice",
+		"question_data": map[string]any{"question": "1/2 + 1/4?", "options": []string{"3/4", "2/6"}},
+		"answer_data":   map[string]any{"choices": []string{"3/4"}},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create question: status %d, %v", code, response)
+	}
+	questionId := int64(response["message"].(float64))
+	if code, response := s.do(http.MethodPut, fmt.Sprintf("/auth/test/%d/question/%d/add_question", testId, questionId), teacherToken, nil); code != http.StatusCreated {
+		t.Fatalf("add question: status %d, %v", code, response)
+	}
+
+	const submitters = 50
+	questionPath := fmt.Sprintf("/auth/test/%d/question/%d", testId, questionId)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < submitters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if code, response := s.do(http.MethodPut, questionPath, studentToken, map[string][]string{"answer_data": {"3/4"}}); code != http.StatusOK {
+				t.Errorf("submit answer: status %d, %v", code, response)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	ctx := context.Background()
+	var rows, events int
+	err := models.DbPool().QueryRow(ctx, `SELECT COUNT(*) FROM test_question_submissions WHERE test_id=$1 AND user_id=$2 AND question_id=$3`, testId, studentId, questionId).Scan(&rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("submission rows = %d, want 1", rows)
+	}
+	err = models.DbPool().QueryRow(ctx, `SELECT COUNT(*) FROM submission_events WHERE test_id=$1 AND user_id=$2 AND question_id=$3`, testId, studentId, questionId).Scan(&events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events != submitters {
+		t.Errorf("submission events = %d, want %d", events, submitters)
+	}
+}
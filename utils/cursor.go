@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const cursorPrefix = "id:"
+
+// EncodeCursor returns the opaque cursor pointing after the row with the given
+// id. Clients must pass it back unchanged.
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor returns the row id of a cursor made by EncodeCursor.
+func DecodeCursor(cursor string) (int64, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	idString, ok := strings.CutPrefix(string(decoded), cursorPrefix)
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
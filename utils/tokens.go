@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+type signedTokenPayload struct {
+	Purpose   string `json:"purpose"`
+	Subject   int64  `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"nonce"`
+}
+
+// RandomString returns n random bytes encoded as unpadded base64url.
+func RandomString(n int) (string, error) {
+	randomBytes := make([]byte, n)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token. Tokens are stored
+// hashed so that a database leak does not leak usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func signPayload(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignToken creates an HMAC signed token for subject that is only valid for
+// the given purpose until ttl elapses. The random nonce makes every token
+// unique, so it can also be tracked server side for single use.
+func SignToken(secret []byte, purpose string, subject int64, ttl time.Duration) (string, time.Time, error) {
+	nonce, err := RandomString(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl)
+	payloadBytes, err := json.Marshal(signedTokenPayload{
+		Purpose:   purpose,
+		Subject:   subject,
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+	return payload + "." + signPayload(secret, payload), expiresAt, nil
+}
+
+// VerifyToken checks the signature, purpose and expiry of a token created by
+// SignToken and returns its subject.
+func VerifyToken(secret []byte, purpose string, token string) (int64, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return 0, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(signPayload(secret, payload))) {
+		return 0, ErrInvalidToken
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	var data signedTokenPayload
+	if err := json.Unmarshal(payloadBytes, &data); err != nil {
+		return 0, ErrInvalidToken
+	}
+	if data.Purpose != purpose {
+		return 0, ErrInvalidToken
+	}
+	if time.Now().Unix() >= data.ExpiresAt {
+		return 0, ErrExpiredToken
+	}
+	return data.Subject, nil
+}
+
+type signedDataPayload struct {
+	Purpose   string          `json:"purpose"`
+	ExpiresAt int64           `json:"exp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SignData is like SignToken but carries arbitrary JSON data instead of a
+// subject id. It is meant for short lived state kept on the client, such as
+// cookies used during a redirect based login.
+func SignData(secret []byte, purpose string, data any, ttl time.Duration) (string, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	payloadBytes, err := json.Marshal(signedDataPayload{
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+		Data:      dataBytes,
+	})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+	return payload + "." + signPayload(secret, payload), nil
+}
+
+// VerifyData checks a token created by SignData and decodes its data into v.
+func VerifyData(secret []byte, purpose string, token string, v any) error {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(signPayload(secret, payload))) {
+		return ErrInvalidToken
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	var data signedDataPayload
+	if err := json.Unmarshal(payloadBytes, &data); err != nil {
+		return ErrInvalidToken
+	}
+	if data.Purpose != purpose {
+		return ErrInvalidToken
+	}
+	if time.Now().Unix() >= data.ExpiresAt {
+		return ErrExpiredToken
+	}
+	if err := json.Unmarshal(data.Data, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}